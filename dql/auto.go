@@ -0,0 +1,92 @@
+/**
+ * @Author: daipengyuan
+ * @Description: 自动填充创建时间、更新时间与更新人
+ * @File:  auto
+ * @Version: 1.0.0
+ * @Date: 2026/10/20 00:30
+ */
+
+package dql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+const (
+	TagAuto       = "auto"
+	AutoCreated   = "created"    // 新增时填充当前时间,更新时保留库中的值
+	AutoUpdated   = "updated"    // 新增和更新时填充当前时间
+	AutoUpdatedBy = "updated_by" // 新增和更新时填充ctx中的操作人
+)
+
+type actorKey struct{}
+
+// WithActor 在ctx中设置操作人,配合Txn.WithContext填充auto:"updated_by"字段
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFrom 获取ctx中的操作人,未设置时返回空
+func ActorFrom(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
+
+// buildEnv 生成请求时的操作人与当前时间,用于填充auto字段与软删除时间,now为零值时使用time.Now
+type buildEnv struct {
+	actor string
+	now   time.Time
+}
+
+// clock 生成请求时的当前时间,由Txn.Clock或Config.Clock提供
+func (m *mutation) clock() time.Time {
+	if m.now.IsZero() {
+		return time.Now()
+	}
+	return m.now
+}
+
+// fillAuto 填充带auto标签的字段,insert为真时同时填充创建时间
+// 时间字段可以是time.Time或*time.Time,创建时间已有值时不覆盖,操作人为空时不填充
+func (m *mutation) fillAuto(insert bool) error {
+	now := m.clock()
+	for i := 0; i < m.Val.NumField(); i++ {
+		f := m.Val.Type().Field(i)
+		fv := m.Val.Field(i)
+		auto := f.Tag.Get(TagAuto)
+		switch auto {
+		case "":
+			continue
+		case AutoCreated, AutoUpdated:
+			switch fv.Interface().(type) {
+			case time.Time:
+				if auto == AutoCreated && (!insert || !fv.IsZero()) {
+					continue
+				}
+				fv.Set(reflect.ValueOf(now))
+			case *time.Time:
+				if auto == AutoCreated && (!insert || !fv.IsNil()) {
+					continue
+				}
+				t := now
+				fv.Set(reflect.ValueOf(&t))
+			default:
+				return errors.New(fmt.Sprintf("auto %s field %s must be time.Time or *time.Time", auto, f.Name))
+			}
+		case AutoUpdatedBy:
+			if fv.Kind() != reflect.String {
+				return errors.New(fmt.Sprintf("auto %s field %s must be string", auto, f.Name))
+			}
+			if m.actor != "" {
+				fv.SetString(m.actor)
+			}
+		default:
+			return errors.New("unrecognized auto tag " + auto)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+/**
+ * @Author: daipengyuan
+ * @Description:
+ * @File:  auto_test
+ * @Version: 1.0.0
+ * @Date: 2026/10/20 00:30
+ */
+
+package dql_test
+
+import (
+	"context"
+	"github.com/golang-common/dglib/dql"
+	"github.com/golang-common/dglib/dql/dqltest"
+	"testing"
+	"time"
+)
+
+type article struct {
+	Uid       string    `json:"uid" db:"uid,string" dtype:"Article"`
+	Title     string    `json:"title" db:"title,string"`
+	CreatedAt time.Time `json:"created_at" db:"created_at,datetime" auto:"created"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at,datetime" auto:"updated"`
+	UpdatedBy string    `json:"updated_by" db:"updated_by,string" auto:"updated_by"`
+}
+
+func TestAuto(t *testing.T) {
+	now := time.Date(2021, 8, 1, 0, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	c := dqltest.NewClient(t)
+
+	a := &article{Title: "v1"}
+	txn := c.Txn().WithContext(dql.WithActor(context.Background(), "alice"))
+	txn.Clock = clock
+	resp, err := txn.Add(a)
+	txn.CommitOrAbort(err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !a.CreatedAt.Equal(now) || a.UpdatedBy != "alice" {
+		t.Fatalf("auto fields not filled %+v", a)
+	}
+	for _, u := range resp.Uids {
+		a.Uid = u
+	}
+
+	now = now.Add(time.Hour)
+	txn = c.Txn().WithContext(dql.WithActor(context.Background(), "bob"))
+	txn.Clock = clock
+	_, err = txn.Update(article{Uid: a.Uid, Title: "v2"})
+	txn.CommitOrAbort(err)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var res struct {
+		Q []article `json:"q"`
+	}
+	if err = c.Txn(true).UnmashalQueryStr(`{ q(func: uid(`+a.Uid+`)) { uid title created_at updated_at updated_by } }`, &res); err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Q) != 1 {
+		t.Fatalf("unexpected result %+v", res.Q)
+	}
+	got := res.Q[0]
+	if !got.CreatedAt.Equal(now.Add(-time.Hour)) || !got.UpdatedAt.Equal(now) || got.UpdatedBy != "bob" || got.Title != "v2" {
+		t.Fatalf("unexpected article %+v", got)
+	}
+}
+
+type draft struct {
+	Uid       string     `json:"uid" db:"uid,string" dtype:"Draft"`
+	Title     string     `json:"title" db:"title,string"`
+	CreatedAt *time.Time `json:"created_at,omitempty" db:"created_at,datetime" auto:"created"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty" db:"updated_at,datetime" auto:"updated"`
+}
+
+// 指针类型的时间字段为空时分配新值
+func TestAuto_Pointer(t *testing.T) {
+	now := time.Date(2022, 3, 4, 0, 0, 0, 0, time.UTC)
+	c := dqltest.NewClient(t)
+	txn := c.Txn()
+	txn.Clock = func() time.Time { return now }
+	d := &draft{Title: "d"}
+	_, err := txn.Add(d)
+	txn.CommitOrAbort(err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.CreatedAt == nil || !d.CreatedAt.Equal(now) || d.UpdatedAt == nil || !d.UpdatedAt.Equal(now) {
+		t.Fatalf("auto fields not filled %+v", d)
+	}
+	if d.CreatedAt == d.UpdatedAt {
+		t.Fatal("auto fields must not share a pointer")
+	}
+	// 已有的创建时间不覆盖
+	created := now.Add(-time.Hour)
+	d = &draft{Title: "e", CreatedAt: &created}
+	if _, err = dql.BuildAdd(d); err != nil {
+		t.Fatal(err)
+	}
+	if !d.CreatedAt.Equal(created) || d.UpdatedAt == nil {
+		t.Fatalf("unexpected auto fields %+v", d)
+	}
+}
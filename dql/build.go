@@ -0,0 +1,95 @@
+/**
+ * @Author: daipengyuan
+ * @Description: 不依赖连接生成变更请求,用于预览和测试
+ * @File:  build
+ * @Version: 1.0.0
+ * @Date: 2026/10/19 22:30
+ */
+
+package dql
+
+import (
+	"fmt"
+	"github.com/dgraph-io/dgo/v200/protos/api"
+	uuid "github.com/satori/go.uuid"
+	"sync"
+)
+
+var (
+	blankMu  sync.RWMutex
+	blankGen = func() string {
+		return uuid.NewV1().String()
+	}
+)
+
+// BlankNode 生成Add变更中新节点的空节点名(不含"_:"前缀)
+// 默认使用uuid,测试中可通过SetBlankNode替换为SeqBlankNode以得到确定的请求
+func BlankNode() string {
+	blankMu.RLock()
+	gen := blankGen
+	blankMu.RUnlock()
+	return gen()
+}
+
+// SetBlankNode 替换空节点命名函数,返回恢复原函数的方法
+// 替换对整个进程生效,只能在串行的测试中使用,不能与t.Parallel同时使用
+func SetBlankNode(gen func() string) (restore func()) {
+	blankMu.Lock()
+	old := blankGen
+	blankGen = gen
+	blankMu.Unlock()
+	return func() {
+		blankMu.Lock()
+		blankGen = old
+		blankMu.Unlock()
+	}
+}
+
+// SeqBlankNode 返回按调用顺序生成b1,b2...的空节点命名函数
+func SeqBlankNode() func() string {
+	var (
+		mu sync.Mutex
+		n  int
+	)
+	return func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		n++
+		return fmt.Sprintf("b%d", n)
+	}
+}
+
+// BuildAdd 生成Txn.Add发送的请求
+func BuildAdd(obj interface{}, facets ...*Facet) (*api.Request, error) {
+	return build(obj, facets, buildEnv{}, (*mutation).MakeAdd)
+}
+
+// BuildUpdate 生成Txn.Update发送的请求
+func BuildUpdate(obj interface{}, facets ...*Facet) (*api.Request, error) {
+	return build(obj, facets, buildEnv{}, (*mutation).MakeUpd)
+}
+
+// BuildMerge 生成Txn.Merge发送的请求
+func BuildMerge(obj interface{}, facets ...*Facet) (*api.Request, error) {
+	return build(obj, facets, buildEnv{}, (*mutation).MakeMerge)
+}
+
+// BuildDelete 生成Txn.Delete发送的请求
+func BuildDelete(obj interface{}, facets ...*Facet) (*api.Request, error) {
+	return build(obj, facets, buildEnv{}, (*mutation).MakeDelVal)
+}
+
+// BuildDelNode 生成Txn.DelNode发送的请求
+func BuildDelNode(obj interface{}, facets ...*Facet) (*api.Request, error) {
+	return build(obj, facets, buildEnv{}, (*mutation).MakeDelNode)
+}
+
+// build 生成请求,env用于填充auto字段
+func build(obj interface{}, facets []*Facet, env buildEnv, mk func(*mutation) (*api.Request, error)) (*api.Request, error) {
+	muta, err := newMutation(obj, facets...)
+	if err != nil {
+		return nil, err
+	}
+	muta.actor, muta.now = env.actor, env.now
+	return mk(muta)
+}
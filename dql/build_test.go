@@ -0,0 +1,93 @@
+/**
+ * @Author: daipengyuan
+ * @Description:
+ * @File:  build_test
+ * @Version: 1.0.0
+ * @Date: 2026/10/19 22:30
+ */
+
+package dql_test
+
+import (
+	"github.com/dgraph-io/dgo/v200/protos/api"
+	"github.com/golang-common/dglib/dql"
+	"github.com/golang-common/dglib/dql/dqltest"
+	"testing"
+)
+
+type person struct {
+	Uid    string   `json:"uid" db:"uid,string" dtype:"Person"`
+	Name   string   `json:"name" db:"name,string,id" index:"index" token:"exact"`
+	Age    int      `json:"age" db:"age,int" index:"index" token:"int"`
+	Friend []string `json:"friend" db:"friend,uid" index:"reverse,count,list"`
+}
+
+func TestBuild(t *testing.T) {
+	p := person{Uid: "0x1", Name: "alice", Age: 30, Friend: []string{"0x2"}}
+	cases := []struct {
+		name  string
+		build func(interface{}, ...*dql.Facet) (*api.Request, error)
+		obj   person
+	}{
+		{"Add", dql.BuildAdd, person{Name: "alice", Age: 30, Friend: []string{"0x2"}}},
+		{"Update", dql.BuildUpdate, p},
+		{"Merge", dql.BuildMerge, p},
+		{"Delete", dql.BuildDelete, person{Uid: "0x1", Age: 30, Friend: []string{"0x2"}}},
+		{"DelNode", dql.BuildDelNode, person{Uid: "0x1"}},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			dqltest.SeqBlankNodes(t)
+			req, err := c.build(c.obj)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dqltest.GoldenRequest(t, req)
+		})
+	}
+}
+
+func TestQuery_Parse(t *testing.T) {
+	q := dql.Query{
+		Q:     `{ q(func: type(Person), $pager) { uid name } }`,
+		Pager: &dql.Pager{First: 10, Offset: 20},
+	}
+	s, err := q.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dqltest.Golden(t, []byte(s+"\n"))
+}
+
+func TestExplain(t *testing.T) {
+	c := dqltest.NewClient(t)
+	dqltest.SeqBlankNodes(t)
+	txn := c.Txn()
+	txn.DryRun = true
+	if _, err := txn.Add(person{Name: "alice", Age: 30}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := txn.Update(person{Uid: "0x1", Name: "alice", Friend: []string{"0x2"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := txn.DelNode(person{Uid: "0x1"}); err != nil {
+		t.Fatal(err)
+	}
+	txn.CommitOrAbort(nil)
+	var out []byte
+	for _, e := range txn.Explains {
+		out = append(out, e.String()...)
+	}
+	dqltest.Golden(t, out)
+
+	var res struct {
+		Q []person `json:"q"`
+	}
+	if err := c.Txn(true).UnmashalQueryStr(`{ q(func: type(Person)) { uid } }`, &res); err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Q) != 0 {
+		t.Fatalf("dry run wrote data %+v", res.Q)
+	}
+}
@@ -0,0 +1,147 @@
+/**
+ * @Author: daipengyuan
+ * @Description: 删除节点时级联删除其拥有的子节点
+ * @File:  cascade
+ * @Version: 1.0.0
+ * @Date: 2026/10/20 01:30
+ */
+
+package dql
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/dgraph-io/dgo/v200/protos/api"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// IndexOwned 设置在uid谓词的index标签中,删除父节点时同时删除该谓词指向的子节点
+	IndexOwned   = "owned"
+	cascadeBlock = "cascade_preview"
+)
+
+// MaxCascadeDepth 级联删除的最大层数
+var MaxCascadeDepth = 5
+
+var (
+	ownedMu    sync.RWMutex
+	ownedPreds = map[string]bool{}
+)
+
+// RegisterOwned 登记类型中标记为owned的谓词,使其作为子节点时也能继续级联删除
+// 谓词在dgraph中是全局的,任一类型将谓词标记为owned即视为owned
+func RegisterOwned(objs ...interface{}) {
+	ownedMu.Lock()
+	defer ownedMu.Unlock()
+	for _, obj := range objs {
+		for _, p := range ownedOf(reflect.TypeOf(obj)) {
+			ownedPreds[p] = true
+		}
+	}
+}
+
+// ownedOf 类型中标记为owned的uid谓词
+func ownedOf(tp reflect.Type) []string {
+	for tp != nil && tp.Kind() == reflect.Ptr {
+		tp = tp.Elem()
+	}
+	if tp == nil || tp.Kind() != reflect.Struct {
+		return nil
+	}
+	var r []string
+	for i := 0; i < tp.NumField(); i++ {
+		f := tp.Field(i)
+		tags := strings.Split(f.Tag.Get(TagDb), ",")
+		if len(tags) < 2 || tags[1] != TypeUid || strings.HasPrefix(tags[0], "~") {
+			continue
+		}
+		for _, idx := range strings.Split(f.Tag.Get(TagIndex), ",") {
+			if idx == IndexOwned {
+				r = append(r, tags[0])
+			}
+		}
+	}
+	return r
+}
+
+// cascade 生成逐层查询子节点的var块与删除子节点的变更
+// 第k层的变量为c{k}_{i},i为谓词序号;另生成cascade_preview块列出所有受影响的节点
+func (m *mutation) cascade() (string, []*api.Mutation) {
+	set := map[string]bool{}
+	for _, p := range ownedOf(m.Val.Type()) {
+		set[p] = true
+	}
+	ownedMu.RLock()
+	for p := range ownedPreds {
+		set[p] = true
+	}
+	ownedMu.RUnlock()
+	if len(set) == 0 || m.Subject == "" {
+		return "", nil
+	}
+	preds := sortedKeys(set)
+	var (
+		blocks []string
+		vars   []string
+		mul    []*api.Mutation
+		parent = m.Subject
+	)
+	for k := 1; k <= MaxCascadeDepth; k++ {
+		var sel, level []string
+		for i, p := range preds {
+			v := fmt.Sprintf("c%d_%d", k, i)
+			sel = append(sel, fmt.Sprintf("%s as %s", v, p))
+			level = append(level, v)
+			mul = append(mul, &api.Mutation{
+				Cond: fmt.Sprintf("@if(gt(len(%s),0))", v),
+				Del:  []*api.NQuad{{Subject: fmt.Sprintf("uid(%s)", v), Predicate: StarAll, ObjectValue: starNqVal}},
+			})
+		}
+		blocks = append(blocks, fmt.Sprintf("var(func: uid(%s)) { %s }", parent, strings.Join(sel, " ")))
+		vars = append(vars, level...)
+		parent = strings.Join(level, ", ")
+	}
+	blocks = append(blocks, fmt.Sprintf("%s(func: uid(%s, %s)) { uid }", cascadeBlock, m.Subject, strings.Join(vars, ", ")))
+	return strings.Join(blocks, " "), mul
+}
+
+// appendBlock 将查询块加入到请求的查询中
+func appendBlock(q, block string) string {
+	if q == "" {
+		return "query{ " + block + " }"
+	}
+	return strings.TrimSuffix(strings.TrimSpace(q), "}") + " " + block + " }"
+}
+
+// PreviewDelete 返回Purge(以及未开启软删除时DelNode)将删除的节点,包括级联删除的子节点,不做任何修改
+func (d *Txn) PreviewDelete(obj interface{}) ([]string, error) {
+	m, err := newMutation(obj)
+	if err != nil {
+		return nil, err
+	}
+	blocks, _ := m.cascade()
+	if blocks == "" {
+		return []string{m.Subject}, nil
+	}
+	var res map[string][]struct {
+		Uid string `json:"uid"`
+	}
+	defer d.Cancel()
+	resp, err := d.Txn.Query(d.Ctx(), appendBlock("", blocks))
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(resp.Json, &res); err != nil {
+		return nil, err
+	}
+	var r []string
+	for _, n := range res[cascadeBlock] {
+		r = append(r, n.Uid)
+	}
+	sort.Strings(r)
+	return r, nil
+}
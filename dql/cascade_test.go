@@ -0,0 +1,83 @@
+/**
+ * @Author: daipengyuan
+ * @Description:
+ * @File:  cascade_test
+ * @Version: 1.0.0
+ * @Date: 2026/10/20 01:30
+ */
+
+package dql_test
+
+import (
+	"github.com/golang-common/dglib/dql"
+	"github.com/golang-common/dglib/dql/dqltest"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+type order struct {
+	Uid      string   `json:"uid" db:"uid,string" dtype:"Order"`
+	No       string   `json:"no" db:"no,string"`
+	Items    []string `json:"items" db:"items,uid" index:"list,owned"`
+	Customer string   `json:"customer" db:"customer,uid"`
+}
+
+type lineItem struct {
+	Uid   string   `json:"uid" db:"uid,string" dtype:"LineItem"`
+	Sku   string   `json:"sku" db:"sku,string"`
+	Notes []string `json:"notes" db:"notes,uid" index:"list,owned"`
+}
+
+type note struct {
+	Uid  string `json:"uid" db:"uid,string" dtype:"Note"`
+	Text string `json:"text" db:"text,string"`
+}
+
+func TestCascade(t *testing.T) {
+	dql.RegisterOwned(lineItem{})
+	defer dql.ResetOwned()
+	c := dqltest.NewClient(t)
+	add := func(obj interface{}) string {
+		txn := c.Txn()
+		resp, err := txn.Add(obj)
+		txn.CommitOrAbort(err)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, u := range resp.Uids {
+			return u
+		}
+		return ""
+	}
+	n := add(note{Text: "fragile"})
+	i1 := add(lineItem{Sku: "a", Notes: []string{n}})
+	i2 := add(lineItem{Sku: "b"})
+	cust := add(note{Text: "customer"})
+	o := add(order{No: "1", Items: []string{i1, i2}, Customer: cust})
+
+	want := []string{o, i1, i2, n}
+	sort.Strings(want)
+	got, err := c.Txn(true).PreviewDelete(order{Uid: o})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("preview %v, want %v", got, want)
+	}
+
+	txn := c.Txn()
+	_, err = txn.DelNode(order{Uid: o})
+	txn.CommitOrAbort(err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range want {
+		if err = c.Txn(true).Get(&note{Uid: u}); err == nil {
+			t.Fatalf("node %s not deleted", u)
+		}
+	}
+	if err = c.Txn(true).Get(&note{Uid: cust}); err != nil {
+		t.Fatalf("not owned node deleted: %v", err)
+	}
+}
@@ -0,0 +1,70 @@
+/**
+ * @Author: daipengyuan
+ * @Description:
+ * @File:  cassette_test
+ * @Version: 1.0.0
+ * @Date: 2026/10/19 23:00
+ */
+
+package dql_test
+
+import (
+	"github.com/golang-common/dglib/dql"
+	"github.com/golang-common/dglib/dql/dqltest"
+	"path/filepath"
+	"testing"
+)
+
+func session(t *testing.T, c *dql.Client) string {
+	if err := c.SetPred(dql.Pred{Predicate: "name", Type: "string", Index: true, Tokenizer: []string{"exact"}}); err != nil {
+		t.Fatal(err)
+	}
+	txn := c.Txn()
+	_, err := txn.Add(person{Name: "alice", Age: 30})
+	txn.CommitOrAbort(err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res struct {
+		Q []person `json:"q"`
+	}
+	if err = c.Txn(true).UnmashalQueryStr(`{ q(func: eq(name, "alice")) { uid name age } }`, &res); err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Q) != 1 || res.Q[0].Age != 30 {
+		t.Fatalf("unexpected result %+v", res.Q)
+	}
+	return res.Q[0].Uid
+}
+
+func TestCassette(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.json")
+
+	dqltest.SeqBlankNodes(t)
+	cfg := dqltest.StartServer(t).Config()
+	cfg.Cassette, cfg.CassetteMode = path, dql.CassetteRecord
+	c, err := dql.NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorded := session(t, c)
+	if err = c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 回放时不需要服务端
+	dqltest.SeqBlankNodes(t)
+	cfg = dql.Config{Targets: []string{"127.0.0.1:1"}, HealthInterval: -1, Cassette: path, CassetteMode: dql.CassetteReplay}
+	c, err = dql.NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if replayed := session(t, c); replayed != recorded {
+		t.Fatalf("replayed uid %s, recorded %s", replayed, recorded)
+	}
+	var res interface{}
+	if err = c.Txn(true).UnmashalQueryStr(`{ q(func: eq(name, "bob")) { uid } }`, &res); err == nil {
+		t.Fatal("expected mismatch error")
+	}
+}
@@ -0,0 +1,311 @@
+/**
+ * @Author: daipengyuan
+ * @Description: 自定义类型与dgraph值之间的转换
+ * @File:  codec
+ * @Version: 1.0.0
+ * @Date: 2026/10/20 03:00
+ */
+
+package dql
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dgraph-io/dgo/v200/protos/api"
+	"github.com/twpayne/go-geom"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DgraphValueMarshaler 自定义类型写入dgraph时转换为基础类型的值
+// 返回值可以是string、bool、各类整数与浮点数、time.Time或geom.T
+type DgraphValueMarshaler interface {
+	MarshalDgraph() (interface{}, error)
+}
+
+// DgraphValueUnmarshaler 自定义类型从查询结果中读取,raw为json解析出的值
+// 即string、float64、bool、[]interface{}或map[string]interface{}
+type DgraphValueUnmarshaler interface {
+	UnmarshalDgraph(raw interface{}) error
+}
+
+// Codec 无法修改的第三方类型(如decimal.Decimal)通过RegisterCodec注册转换方法
+// Marshal 将值转换为基础类型,Unmarshal 将json解析出的值转换为该类型
+type Codec struct {
+	Marshal   func(v interface{}) (interface{}, error)
+	Unmarshal func(raw interface{}) (interface{}, error)
+}
+
+var (
+	codecMu sync.RWMutex
+	codecs  = map[reflect.Type]Codec{}
+
+	timeType = reflect.TypeOf(time.Time{})
+	geomType = reflect.TypeOf((*geom.T)(nil)).Elem()
+)
+
+// RegisterCodec 为sample的类型注册转换方法,优先于DgraphValueMarshaler与默认转换
+func RegisterCodec(sample interface{}, c Codec) {
+	codecMu.Lock()
+	defer codecMu.Unlock()
+	codecs[reflect.TypeOf(sample)] = c
+}
+
+func codecOf(tp reflect.Type) (Codec, bool) {
+	codecMu.RLock()
+	defer codecMu.RUnlock()
+	c, ok := codecs[tp]
+	return c, ok
+}
+
+// isScalar 自定义转换的类型即使是切片(如net.IP)也作为单个值写入
+func isScalar(tp reflect.Type) bool {
+	for tp.Kind() == reflect.Ptr {
+		tp = tp.Elem()
+	}
+	if _, ok := codecOf(tp); ok {
+		return true
+	}
+	pt := reflect.PtrTo(tp)
+	return tp.Implements(reflect.TypeOf((*DgraphValueMarshaler)(nil)).Elem()) ||
+		pt.Implements(reflect.TypeOf((*DgraphValueMarshaler)(nil)).Elem()) ||
+		tp.Implements(reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem())
+}
+
+// baseValue 将字段值转换为typeNqTypeMap可以处理的基础类型,nil指针返回无效值
+func baseValue(dt string, v reflect.Value) (reflect.Value, error) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Value{}, nil
+		}
+		if v.Kind() == reflect.Interface && v.Type().Implements(geomType) {
+			break
+		}
+		v = v.Elem()
+	}
+	if c, ok := codecOf(v.Type()); ok && c.Marshal != nil {
+		b, err := c.Marshal(v.Interface())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return baseValue(dt, reflect.ValueOf(b))
+	}
+	if m, ok := asMarshaler(v); ok {
+		b, err := m.MarshalDgraph()
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return baseValue(dt, reflect.ValueOf(b))
+	}
+	if v.Type() == timeType || v.Type().Implements(geomType) {
+		return v, nil
+	}
+	if tm, ok := v.Interface().(encoding.TextMarshaler); ok {
+		bs, err := tm.MarshalText()
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(string(bs)), nil
+	}
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return reflect.ValueOf(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if dt == TypeUid {
+			return reflect.ValueOf(v.Uint()), nil
+		}
+		if v.Uint() > math.MaxInt64 {
+			return reflect.Value{}, errors.New(fmt.Sprintf("value %d overflows dgraph int", v.Uint()))
+		}
+		return reflect.ValueOf(int64(v.Uint())), nil
+	case reflect.Float32, reflect.Float64:
+		return reflect.ValueOf(v.Float()), nil
+	case reflect.String:
+		return reflect.ValueOf(v.String()), nil
+	case reflect.Bool:
+		return reflect.ValueOf(v.Bool()), nil
+	}
+	return v, nil
+}
+
+func asMarshaler(v reflect.Value) (DgraphValueMarshaler, bool) {
+	if m, ok := v.Interface().(DgraphValueMarshaler); ok {
+		return m, true
+	}
+	if v.CanAddr() {
+		m, ok := v.Addr().Interface().(DgraphValueMarshaler)
+		return m, ok
+	}
+	pv := reflect.New(v.Type())
+	pv.Elem().Set(v)
+	m, ok := pv.Interface().(DgraphValueMarshaler)
+	return m, ok
+}
+
+// toNqValue 将字段值转换为nquad的对象,nil指针时返回的值与uid均为空
+func toNqValue(dt string, v reflect.Value) (*api.Value, string, bool, error) {
+	fc, ok := typeNqTypeMap[dt]
+	if !ok {
+		return nil, "", false, errors.New("error datatype " + dt)
+	}
+	b, err := baseValue(dt, v)
+	if err != nil || !b.IsValid() {
+		return nil, "", false, err
+	}
+	// float类型允许写入整数
+	if dt == TypeFloat && b.Kind() == reflect.Int64 {
+		b = reflect.ValueOf(float64(b.Int()))
+	}
+	nqVal, nqObj, err := fc(b)
+	return nqVal, nqObj, true, err
+}
+
+// idString id字段的值转换为查询条件中使用的字符串
+func idString(dt string, fv reflect.Value) (string, error) {
+	b, err := baseValue(dt, fv)
+	if err != nil || !b.IsValid() {
+		return "", err
+	}
+	switch b.Kind() {
+	case reflect.String:
+		return b.String(), nil
+	case reflect.Int64:
+		return fmt.Sprintf("%d", b.Int()), nil
+	case reflect.Float64:
+		return strconv.FormatFloat(b.Float(), 'g', -1, 64), nil
+	}
+	return "", errors.New("unsupport id datatype")
+}
+
+// Unmarshal 将查询返回的json解析到obj,支持Codec、DgraphValueUnmarshaler与指针字段
+// 其余类型按encoding/json的规则解析,数字保留为json.Number,超过2^53的整数不丢失精度
+func Unmarshal(data []byte, obj interface{}) error {
+	var raw interface{}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	if err := d.Decode(&raw); err != nil {
+		return err
+	}
+	v := reflect.ValueOf(obj)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return errors.New("obj must be non-nil pointer")
+	}
+	return assign(v.Elem(), raw)
+}
+
+var unmarshalerType = reflect.TypeOf((*DgraphValueUnmarshaler)(nil)).Elem()
+
+func assign(v reflect.Value, raw interface{}) error {
+	if raw == nil {
+		return nil
+	}
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return assign(v.Elem(), raw)
+	}
+	if c, ok := codecOf(v.Type()); ok && c.Unmarshal != nil {
+		r, err := c.Unmarshal(plainNumbers(raw))
+		if err != nil {
+			return err
+		}
+		rv := reflect.ValueOf(r)
+		if !rv.Type().AssignableTo(v.Type()) {
+			return errors.New("codec of " + v.Type().String() + " returned " + rv.Type().String())
+		}
+		v.Set(rv)
+		return nil
+	}
+	if reflect.PtrTo(v.Type()).Implements(unmarshalerType) {
+		return v.Addr().Interface().(DgraphValueUnmarshaler).UnmarshalDgraph(plainNumbers(raw))
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		m, ok := raw.(map[string]interface{})
+		if !ok || v.Type() == timeType || reflect.PtrTo(v.Type()).Implements(reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()) {
+			break
+		}
+		return assignStruct(v, m)
+	case reflect.Slice:
+		l, ok := raw.([]interface{})
+		if !ok || v.Type().Elem().Kind() == reflect.Uint8 {
+			break
+		}
+		s := reflect.MakeSlice(v.Type(), len(l), len(l))
+		for i, e := range l {
+			if err := assign(s.Index(i), e); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+		return nil
+	}
+	bs, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(bs, v.Addr().Interface())
+}
+
+// plainNumbers 将json.Number转换为float64,Codec与DgraphValueUnmarshaler收到的值与json.Unmarshal的结果一致
+func plainNumbers(raw interface{}) interface{} {
+	switch x := raw.(type) {
+	case json.Number:
+		f, _ := x.Float64()
+		return f
+	case []interface{}:
+		r := make([]interface{}, len(x))
+		for i, e := range x {
+			r[i] = plainNumbers(e)
+		}
+		return r
+	case map[string]interface{}:
+		r := make(map[string]interface{}, len(x))
+		for k, e := range x {
+			r[k] = plainNumbers(e)
+		}
+		return r
+	}
+	return raw
+}
+
+func assignStruct(v reflect.Value, m map[string]interface{}) error {
+	tp := v.Type()
+	for i := 0; i < tp.NumField(); i++ {
+		f := tp.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+		if f.Anonymous && f.Type.Kind() == reflect.Struct && f.Tag.Get("json") == "" {
+			if err := assignStruct(v.Field(i), m); err != nil {
+				return err
+			}
+			continue
+		}
+		if f.Tag.Get("json") == "-" {
+			continue
+		}
+		raw, ok := m[jsonName(f)]
+		if !ok {
+			// 与encoding/json一致,字段名不区分大小写
+			for k, r := range m {
+				if strings.EqualFold(k, jsonName(f)) {
+					raw = r
+					break
+				}
+			}
+		}
+		if err := assign(v.Field(i), raw); err != nil {
+			return errors.New(fmt.Sprintf("field %s: %s", f.Name, err.Error()))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+/**
+ * @Author: daipengyuan
+ * @Description:
+ * @File:  codec_test
+ * @Version: 1.0.0
+ * @Date: 2026/10/20 03:00
+ */
+
+package dql_test
+
+import (
+	"errors"
+	"fmt"
+	"github.com/golang-common/dglib/dql"
+	"github.com/golang-common/dglib/dql/dqltest"
+	uuid "github.com/satori/go.uuid"
+	"net"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// money 以分为单位,在库中存为"12.50"形式的字符串
+type money int64
+
+func (m money) MarshalDgraph() (interface{}, error) {
+	return fmt.Sprintf("%d.%02d", m/100, m%100), nil
+}
+
+func (m *money) UnmarshalDgraph(raw interface{}) error {
+	s, ok := raw.(string)
+	if !ok {
+		return errors.New("money must be string")
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return err
+	}
+	*m = money(f*100 + 0.5)
+	return nil
+}
+
+// celsius 模拟无法修改的第三方类型,通过RegisterCodec存为float
+type celsius struct{ V float64 }
+
+type level string
+
+type gadget struct {
+	Uid     string        `json:"uid" db:"uid,string" dtype:"Gadget"`
+	Price   money         `json:"price" db:"price,string"`
+	Addr    net.IP        `json:"addr" db:"addr,string"`
+	Serial  uuid.UUID     `json:"serial" db:"serial,string"`
+	Level   level         `json:"level" db:"level,string" validate:"oneof=low high"`
+	Timeout time.Duration `json:"timeout" db:"timeout,int"`
+	Temp    celsius       `json:"temp" db:"temp,float"`
+	Rank    *int          `json:"rank" db:"rank,int" validate:"max=10"`
+	Note    *string       `json:"note" db:"note,string"`
+	Small   int8          `json:"small" db:"small,int"`
+	Size    uint32        `json:"size" db:"size,int"`
+	Ratio   float32       `json:"ratio" db:"ratio,float"`
+}
+
+func TestCodec(t *testing.T) {
+	dql.RegisterCodec(celsius{}, dql.Codec{
+		Marshal: func(v interface{}) (interface{}, error) { return v.(celsius).V, nil },
+		Unmarshal: func(raw interface{}) (interface{}, error) {
+			f, ok := raw.(float64)
+			if !ok {
+				return nil, errors.New("celsius must be float")
+			}
+			return celsius{V: f}, nil
+		},
+	})
+	c := dqltest.NewClient(t)
+	zero := 0
+	in := gadget{
+		Price:   1250,
+		Addr:    net.ParseIP("10.0.0.1"),
+		Serial:  uuid.NewV4(),
+		Level:   "high",
+		Timeout: 3 * time.Second,
+		Temp:    celsius{V: 21.5},
+		Rank:    &zero,
+		Small:   -3,
+		Size:    70000,
+		Ratio:   0.5,
+	}
+	txn := c.Txn()
+	resp, err := txn.Add(in)
+	txn.CommitOrAbort(err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range resp.Uids {
+		in.Uid = u
+	}
+
+	out := gadget{Uid: in.Uid}
+	if err = c.Txn(true).Get(&out); err != nil {
+		t.Fatal(err)
+	}
+	if !out.Addr.Equal(in.Addr) {
+		t.Fatalf("addr: got %v", out.Addr)
+	}
+	out.Addr = in.Addr
+	if !reflect.DeepEqual(out, in) {
+		t.Fatalf("round trip mismatch:\n got %+v\nwant %+v", out, in)
+	}
+
+	// 指针指向零值时写入零值,nil指针不写入
+	var price struct {
+		Q []map[string]interface{} `json:"q"`
+	}
+	if err = c.Txn(true).UnmashalQueryStr(fmt.Sprintf(`{ q(func: uid(%s)) { price rank note } }`, in.Uid), &price); err != nil {
+		t.Fatal(err)
+	}
+	if p := price.Q[0]; p["price"] != "12.50" || p["rank"] != float64(0) || p["note"] != nil {
+		t.Fatalf("unexpected stored values %v", p)
+	}
+
+	big := 11
+	if _, err = dql.BuildAdd(gadget{Rank: &big}); err == nil {
+		t.Fatal("validate rule not applied to pointer value")
+	}
+	if _, err = dql.BuildAdd(gadget{Level: "mid"}); err == nil {
+		t.Fatal("validate rule not applied to enum")
+	}
+}
+
+func TestUnmarshal_BigInt(t *testing.T) {
+	var out struct {
+		Q []struct {
+			Id  int64   `json:"id"`
+			Ptr *uint64 `json:"ptr"`
+			Any interface{}
+		} `json:"q"`
+	}
+	data := `{"q":[{"id":9007199254740993,"ptr":18446744073709551615,"any":1.5}]}`
+	if err := dql.Unmarshal([]byte(data), &out); err != nil {
+		t.Fatal(err)
+	}
+	if n := out.Q[0]; n.Id != 9007199254740993 || *n.Ptr != 18446744073709551615 || n.Any != 1.5 {
+		t.Fatalf("unexpected %+v", n)
+	}
+}
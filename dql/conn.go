@@ -18,7 +18,10 @@ import (
 	"github.com/dgraph-io/dgo/v200"
 	"github.com/dgraph-io/dgo/v200/protos/api"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 	"io/ioutil"
 	"strings"
 	"time"
@@ -30,7 +33,48 @@ type Config struct {
 	Password    string        `json:"password,omitempty"`
 	DialTimeout time.Duration `json:"dial_timeout,omitempty"`
 	OptTimeout  time.Duration `json:"opt_timeout,omitempty"`
+	AdminToken  string        `json:"admin_token,omitempty"` // 对应alpha的security.token配置
 	Tls         Tls           `json:"tls"`
+	// HealthInterval 健康检查间隔,为0时使用默认值,小于0时关闭定时检查
+	HealthInterval time.Duration `json:"health_interval,omitempty"`
+	// HttpTargets 与Targets按顺序一一对应的http地址,如192.168.1.100:8080,设置后健康检查同时探测/health
+	HttpTargets []string `json:"http_targets,omitempty"`
+	// ReadTargets Targets中优先处理只读查询的节点,如部署在报表机房的alpha;不健康时回退到其他节点
+	ReadTargets []string `json:"read_targets,omitempty"`
+	// Cassette 录制或回放的调用记录文件,CassetteMode为空时不生效
+	Cassette     string       `json:"cassette,omitempty"`
+	CassetteMode CassetteMode `json:"cassette_mode,omitempty"`
+	// Clock 填充auto时间字段与软删除时间使用的时钟,为空时使用time.Now
+	Clock func() time.Time `json:"-"`
+}
+
+const (
+	AdminTokenMd     = "auth-token"         // grpc请求中携带admin令牌的metadata键
+	AdminTokenHeader = "X-Dgraph-AuthToken" // http请求中携带admin令牌的头
+)
+
+// AdminTokenError 服务端拒绝admin令牌(未携带或不匹配)时返回
+type AdminTokenError struct {
+	Err error
+}
+
+func (e *AdminTokenError) Error() string {
+	return "admin token rejected: " + e.Err.Error()
+}
+
+func (e *AdminTokenError) Unwrap() error {
+	return e.Err
+}
+
+// adminCred admin令牌的单次调用凭证,对应grpc中的"auth-token"字段
+type adminCred string
+
+func (a adminCred) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{AdminTokenMd: string(a)}, nil
+}
+
+func (a adminCred) RequireTransportSecurity() bool {
+	return false
 }
 
 type Tls struct {
@@ -45,14 +89,16 @@ type Tls struct {
 // 第一个返回值为dgraph操作对象
 func NewClient(config Config) (*Client, error) {
 	var (
-		clients []api.DgraphClient
-		ctx     = context.Background()
-		cancel  context.CancelFunc
-		opts    []grpc.DialOption
+		ctx    = context.Background()
+		cancel context.CancelFunc
+		opts   []grpc.DialOption
 	)
 	if len(config.Targets) == 0 {
 		return nil, errors.New("no target given")
 	}
+	if len(config.HttpTargets) > 0 && len(config.HttpTargets) != len(config.Targets) {
+		return nil, errors.New("http targets must correspond to targets one by one")
+	}
 	if config.DialTimeout != 0 {
 		ctx, cancel = context.WithTimeout(ctx, config.DialTimeout)
 		defer cancel()
@@ -66,25 +112,49 @@ func NewClient(config Config) (*Client, error) {
 		}
 		opts = append(opts, grpc.WithTransportCredentials(cred))
 	}
+	var cst *cassette
+	if config.CassetteMode != "" {
+		var err error
+		if cst, err = newCassette(config.Cassette, config.CassetteMode); err != nil {
+			return nil, err
+		}
+		opts = append(opts, grpc.WithUnaryInterceptor(cst.intercept))
+	}
+	pl, err := newPool(config)
+	if err != nil {
+		return nil, err
+	}
 	for _, target := range config.Targets {
 		grpcConn, err := grpc.DialContext(ctx, target, opts...)
 		if err != nil {
+			pl.close()
 			return nil, err
 		}
-		client := api.NewDgraphClient(grpcConn)
-		clients = append(clients, client)
+		pl.add(target, grpcConn)
 	}
-	dgraph := dgo.NewDgraphClient(clients...)
+	// 所有请求经由pool分发到健康节点
+	dgraph := dgo.NewDgraphClient(pl)
 	if config.Username != "" && config.Password != "" {
 		err := dgraph.Login(ctx, config.Username, config.Password)
 		if err != nil {
+			pl.close()
 			return nil, err
 		}
 	}
-	return &Client{client: dgraph, optTimeout: config.OptTimeout}, nil
+	pl.start()
+	return &Client{client: dgraph, pool: pl, cassette: cst, optTimeout: config.OptTimeout, adminCred: adminCred(config.AdminToken), clock: config.Clock}, nil
 }
 
 func newTlsCred(ts Tls) (credentials.TransportCredentials, error) {
+	conf, err := ts.Config()
+	if err != nil {
+		return nil, err
+	}
+	return credentials.NewTLS(conf), nil
+}
+
+// Config 加载证书生成tls配置,grpc与http连接共用
+func (ts Tls) Config() (*tls.Config, error) {
 	cert, err := tls.LoadX509KeyPair(ts.ClientCert, ts.ClientKey)
 	if err != nil {
 		return nil, err
@@ -97,12 +167,12 @@ func newTlsCred(ts Tls) (credentials.TransportCredentials, error) {
 	if ok := certPool.AppendCertsFromPEM(ca); !ok {
 		return nil, errors.New("failed to append ca certs")
 	}
-	creds := credentials.NewTLS(&tls.Config{
+	conf := &tls.Config{
 		ServerName:   ts.ServeName,
 		Certificates: []tls.Certificate{cert},
 		RootCAs:      certPool,
-	})
-	return creds, nil
+	}
+	return conf, nil
 }
 
 type Schema struct {
@@ -136,7 +206,11 @@ func (s *Schema) SkipSysSchema() Schema {
 
 type Client struct {
 	client     *dgo.Dgraph
+	pool       *pool
+	cassette   *cassette
 	optTimeout time.Duration
+	adminCred  adminCred
+	clock      func() time.Time
 	cancel     context.CancelFunc
 }
 
@@ -147,6 +221,24 @@ func (d *Client) Cancel() {
 	return
 }
 
+// Close 停止健康检查并关闭所有grpc连接,关闭后Client不可再使用
+// 录制模式下同时将录制的调用写入Cassette文件
+func (d *Client) Close() error {
+	d.Cancel()
+	err := d.pool.close()
+	if d.cassette != nil {
+		if serr := d.cassette.save(); err == nil {
+			err = serr
+		}
+	}
+	return err
+}
+
+// Health 立即探测所有节点并返回各节点的健康状态
+func (d *Client) Health(ctx context.Context) []TargetHealth {
+	return d.pool.probeAll(ctx)
+}
+
 func (d *Client) Ctx() context.Context {
 	var (
 		r = context.Background()
@@ -165,72 +257,132 @@ func (d *Client) Ctx() context.Context {
 
 func (d *Client) Txn(ReadOnly ...bool) *Txn {
 	if len(ReadOnly) > 0 && ReadOnly[0] == true {
-		return &Txn{Txn: d.client.NewReadOnlyTxn(), Readonly: ReadOnly[0], Timeout: d.optTimeout}
+		return &Txn{Txn: d.client.NewReadOnlyTxn(), Readonly: ReadOnly[0], Timeout: d.optTimeout, Clock: d.clock}
 	}
-	return &Txn{Txn: d.client.NewTxn(), Timeout: d.optTimeout}
+	return &Txn{Txn: d.client.NewTxn(), Timeout: d.optTimeout, Clock: d.clock}
+}
+
+func (d *Client) now() time.Time {
+	if d.clock != nil {
+		return d.clock()
+	}
+	return time.Now()
 }
 
 func (d *Client) SetPred(pred Pred) error {
-	defer d.Cancel()
-	err := d.client.Alter(d.Ctx(), &api.Operation{
+	return d.alter(&api.Operation{
 		Schema: pred.Rdf(),
 	})
-	return err
 }
 
 func (d *Client) DropPred(name string) error {
-	defer d.Cancel()
-	err := d.client.Alter(d.Ctx(), &api.Operation{
+	return d.alter(&api.Operation{
 		DropValue: name,
 		DropOp:    api.Operation_ATTR,
 	})
-	return err
 }
 
 func (d *Client) SetType(tp Type) error {
-	defer d.Cancel()
-	err := d.client.Alter(d.Ctx(), &api.Operation{
+	return d.alter(&api.Operation{
 		Schema: tp.Schema(),
 	})
-	return err
 }
 
 func (d *Client) DropType(name string) error {
-	defer d.Cancel()
-	err := d.client.Alter(d.Ctx(), &api.Operation{
+	return d.alter(&api.Operation{
 		DropValue:       name,
 		DropOp:          api.Operation_TYPE,
 		RunInBackground: false,
 	})
-	return err
 }
 
 func (d *Client) DropAllData() error {
-	defer d.Cancel()
-	err := d.client.Alter(d.Ctx(), &api.Operation{
+	return d.alter(&api.Operation{
 		DropOp: api.Operation_DATA,
 	})
-	return err
 }
 
 func (d *Client) DropAllDataAndSchema() error {
-	defer d.Cancel()
-	err := d.client.Alter(d.Ctx(), &api.Operation{
+	return d.alter(&api.Operation{
 		DropAll: true,
 	})
+}
+
+// alter 执行Alter操作,配置了admin令牌时附加到本次调用
+// dgo不透传grpc.CallOption,因此将凭证的metadata写入本次调用的ctx
+func (d *Client) alter(op *api.Operation) error {
+	defer d.Cancel()
+	ctx := d.Ctx()
+	if d.adminCred != "" {
+		md, _ := d.adminCred.GetRequestMetadata(ctx)
+		for k, v := range md {
+			ctx = metadata.AppendToOutgoingContext(ctx, k, v)
+		}
+	}
+	err := d.client.Alter(ctx, op)
+	if isAdminTokenErr(err) {
+		return &AdminTokenError{Err: err}
+	}
 	return err
 }
 
+// isAdminTokenErr 判断错误是否为服务端拒绝admin令牌
+// 未携带令牌时返回"No Auth Token found",令牌不匹配时返回"Provided auth token ... does not match"
+func isAdminTokenErr(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	if !strings.Contains(msg, "auth token") {
+		return false
+	}
+	st, ok := status.FromError(err)
+	if !ok {
+		return true
+	}
+	return st.Code() == codes.PermissionDenied || st.Code() == codes.Unauthenticated || st.Code() == codes.Unknown
+}
+
 type Txn struct {
 	Txn      *dgo.Txn
 	Timeout  time.Duration
 	Readonly bool
-	cancel   context.CancelFunc
+	// DryRun 为真时Add/Update/Merge/Delete/DelNode不发送请求,只将解析结果追加到Explains
+	DryRun   bool
+	Explains []*Explain
+	// JSON 为真时变更转换为SetJson/DeleteJson发送,见ToJSONRequest
+	JSON bool
+	// Clock 填充auto时间字段与软删除时间使用的时钟,默认为Config.Clock
+	Clock  func() time.Time
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// WithContext 设置事务中请求的父ctx,如通过WithActor设置的操作人
+func (d *Txn) WithContext(ctx context.Context) *Txn {
+	d.ctx = ctx
+	return d
+}
+
+// env 生成请求时使用ctx中的操作人与事务的时钟
+func (d *Txn) env() buildEnv {
+	env := buildEnv{actor: ActorFrom(d.base()), now: time.Now()}
+	if d.Clock != nil {
+		env.now = d.Clock()
+	}
+	return env
+}
+
+func (d *Txn) base() context.Context {
+	if d.ctx != nil {
+		return d.ctx
+	}
+	return context.Background()
 }
 
 func (d *Txn) Ctx() context.Context {
 	var (
-		r = context.Background()
+		r = d.base()
 		c context.CancelFunc
 	)
 	// 清除之前的ctx资源
@@ -9,7 +9,10 @@
 package dql
 
 import (
+	"errors"
 	"fmt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"strings"
 	"testing"
 	"time"
@@ -152,3 +155,21 @@ func TestSetType2(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestIsAdminTokenErr(t *testing.T) {
+	var cases = []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{errors.New("some other error"), false},
+		{status.Error(codes.Unknown, "No Auth Token found. Token needed for Admin operations."), true},
+		{status.Error(codes.PermissionDenied, "Provided auth token [x] does not match. Permission denied."), true},
+		{status.Error(codes.Unavailable, "connection refused"), false},
+	}
+	for _, c := range cases {
+		if got := isAdminTokenErr(c.err); got != c.want {
+			t.Errorf("isAdminTokenErr(%v) = %t, want %t", c.err, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+/**
+ * @Author: daipengyuan
+ * @Description: 将生成的请求与testdata下的golden文件比对
+ * @File:  golden
+ * @Version: 1.0.0
+ * @Date: 2026/10/19 22:30
+ */
+
+package dqltest
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/dgraph-io/dgo/v200/protos/api"
+	"github.com/golang-common/dglib/dql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// UpdateEnv 环境变量为1时用当前结果重写golden文件: DQLTEST_UPDATE=1 go test ./...
+// 不注册命令行参数,避免与引用本包的测试中的-update参数冲突
+const UpdateEnv = "DQLTEST_UPDATE"
+
+// SeqBlankNodes 在当前测试中使用确定的空节点名b1,b2...,测试结束后恢复
+// 命名函数是全局的,使用它的测试不能调用t.Parallel
+func SeqBlankNodes(t testing.TB) {
+	t.Cleanup(dql.SetBlankNode(dql.SeqBlankNode()))
+}
+
+// GoldenRequest 比对请求与testdata/<测试名>.golden,不一致时测试失败
+func GoldenRequest(t testing.TB, req *api.Request) {
+	t.Helper()
+	bs, err := json.MarshalIndent(req, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	Golden(t, append(bs, '\n'))
+}
+
+// Golden 比对内容与testdata/<测试名>.golden,不一致时测试失败
+func Golden(t testing.TB, got []byte) {
+	t.Helper()
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	path := filepath.Join("testdata", name+".golden")
+	if os.Getenv(UpdateEnv) == "1" {
+		if err := os.MkdirAll("testdata", 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read golden file: %v (run with %s=1 to create it)", err, UpdateEnv)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s mismatch (run with %s=1 to accept)\n--- got\n%s\n--- want\n%s", path, UpdateEnv, got, want)
+	}
+}
@@ -0,0 +1,202 @@
+/**
+ * @Author: daipengyuan
+ * @Description: 将json变更解析为nquad,与dgraph的json变更规则一致
+ * @File:  jsonmut
+ * @Version: 1.0.0
+ * @Date: 2026/10/20 05:30
+ */
+
+package dqltest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dgraph-io/dgo/v200/protos/api"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var starVal = &api.Value{Val: &api.Value_DefaultVal{DefaultVal: starAll}}
+
+// jsonParser 没有uid的对象分配_:dqltest.json.N空白节点
+type jsonParser struct {
+	del   bool
+	blank int
+	nqs   []*api.NQuad
+}
+
+// jsonNquads 解析SetJson或DeleteJson,顶层可以是对象或对象数组
+func jsonNquads(data []byte, del bool) ([]*api.NQuad, error) {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+	p := &jsonParser{del: del}
+	var objs []interface{}
+	switch x := v.(type) {
+	case []interface{}:
+		objs = x
+	case map[string]interface{}:
+		objs = []interface{}{x}
+	default:
+		return nil, errors.New("json mutation must be object or array")
+	}
+	for _, o := range objs {
+		m, ok := o.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("json mutation must be object or array")
+		}
+		if _, err := p.object(m, ""); err != nil {
+			return nil, err
+		}
+	}
+	return p.nqs, nil
+}
+
+// object 解析一个节点,parent为指向该节点的谓词,其facet写在该节点中
+func (p *jsonParser) object(m map[string]interface{}, parent string) (string, error) {
+	uid, _ := m["uid"].(string)
+	if uid == "" {
+		if p.del {
+			return "", errors.New("object in delete json must have uid")
+		}
+		p.blank++
+		uid = fmt.Sprintf("_:dqltest.json.%d", p.blank)
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	if p.del && len(m) == 1 && parent == "" {
+		p.nqs = append(p.nqs, &api.NQuad{Subject: uid, Predicate: starAll, ObjectValue: starVal})
+		return uid, nil
+	}
+	for _, k := range keys {
+		if k == "uid" || strings.Contains(k, "|") {
+			continue
+		}
+		pred, lang := splitLang(k)
+		if err := p.values(uid, pred, lang, m[k], facetMaps(m, k)); err != nil {
+			return "", err
+		}
+	}
+	return uid, nil
+}
+
+// facetMaps 取出key的facet,值为单个值或{"序号":值}
+func facetMaps(m map[string]interface{}, key string) map[string]interface{} {
+	r := map[string]interface{}{}
+	for k, v := range m {
+		if strings.HasPrefix(k, key+"|") {
+			r[k[len(key)+1:]] = v
+		}
+	}
+	return r
+}
+
+func (p *jsonParser) values(uid, pred, lang string, v interface{}, facets map[string]interface{}) error {
+	list, isList := v.([]interface{})
+	if !isList {
+		list = []interface{}{v}
+	}
+	for i, e := range list {
+		nq := &api.NQuad{Subject: uid, Predicate: pred, Lang: lang}
+		switch x := e.(type) {
+		case nil:
+			if !p.del {
+				continue
+			}
+			nq.ObjectId, nq.ObjectValue = starAll, starVal
+		case map[string]interface{}:
+			if isGeo(x) {
+				bs, err := json.Marshal(x)
+				if err != nil {
+					return err
+				}
+				nq.ObjectValue = &api.Value{Val: &api.Value_GeoVal{GeoVal: bs}}
+				break
+			}
+			child, err := p.object(x, pred)
+			if err != nil {
+				return err
+			}
+			nq.ObjectId = child
+			// 边的facet写在子节点中
+			for k, fv := range facetMaps(x, pred) {
+				f, err := jsonFacet(k, fv)
+				if err != nil {
+					return err
+				}
+				nq.Facets = append(nq.Facets, f)
+			}
+		case json.Number:
+			if n, err := x.Int64(); err == nil {
+				nq.ObjectValue = &api.Value{Val: &api.Value_IntVal{IntVal: n}}
+			} else {
+				f, err := x.Float64()
+				if err != nil {
+					return err
+				}
+				nq.ObjectValue = &api.Value{Val: &api.Value_DoubleVal{DoubleVal: f}}
+			}
+		case bool:
+			nq.ObjectValue = &api.Value{Val: &api.Value_BoolVal{BoolVal: x}}
+		case string:
+			nq.ObjectValue = &api.Value{Val: &api.Value_DefaultVal{DefaultVal: x}}
+		default:
+			return errors.New(fmt.Sprintf("unsupported json value %v", e))
+		}
+		for k, fv := range facets {
+			if fm, ok := fv.(map[string]interface{}); ok {
+				if fv, ok = fm[strconv.Itoa(i)]; !ok {
+					continue
+				}
+			}
+			f, err := jsonFacet(k, fv)
+			if err != nil {
+				return err
+			}
+			nq.Facets = append(nq.Facets, f)
+		}
+		p.nqs = append(p.nqs, nq)
+	}
+	return nil
+}
+
+func isGeo(m map[string]interface{}) bool {
+	_, t := m["type"]
+	_, c := m["coordinates"]
+	return t && c && len(m) == 2
+}
+
+// jsonFacet 与dql.Facet的编码一致,能解析为时间的字符串视为datetime
+func jsonFacet(key string, v interface{}) (*api.Facet, error) {
+	f := &api.Facet{Key: key}
+	switch x := v.(type) {
+	case json.Number:
+		if _, err := x.Int64(); err == nil {
+			f.ValType = api.Facet_INT
+		} else {
+			f.ValType = api.Facet_FLOAT
+		}
+		f.Value = []byte(x.String())
+	case bool:
+		f.ValType = api.Facet_BOOL
+		f.Value = []byte(strconv.FormatBool(x))
+	case string:
+		f.ValType = api.Facet_STRING
+		if _, err := parseTime(x); err == nil {
+			f.ValType = api.Facet_DATETIME
+		}
+		f.Value = []byte(x)
+	default:
+		return nil, errors.New(fmt.Sprintf("unsupported facet value %v", v))
+	}
+	return f, nil
+}
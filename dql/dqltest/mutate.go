@@ -0,0 +1,192 @@
+/**
+ * @Author: daipengyuan
+ * @Description: 变更执行,解析nquad中的空节点、uid(v)与val(v)引用
+ * @File:  mutate
+ * @Version: 1.0.0
+ * @Date: 2026/10/19 21:30
+ */
+
+package dqltest
+
+import (
+	"errors"
+	"fmt"
+	"github.com/dgraph-io/dgo/v200/protos/api"
+	"strings"
+)
+
+// mutate 在事务中执行一个变更,新建的空节点写入uids
+func (s *Server) mutate(t *txn, e *env, mu *api.Mutation, uids map[string]string) error {
+	if len(mu.SetNquads) > 0 || len(mu.DelNquads) > 0 {
+		return errors.New("dqltest only supports mutations with Set/Del nquads or json")
+	}
+	set, del := mu.Set, mu.Del
+	if len(mu.SetJson) > 0 {
+		nqs, err := jsonNquads(mu.SetJson, false)
+		if err != nil {
+			return err
+		}
+		set = append(append([]*api.NQuad{}, set...), nqs...)
+	}
+	if len(mu.DeleteJson) > 0 {
+		nqs, err := jsonNquads(mu.DeleteJson, true)
+		if err != nil {
+			return err
+		}
+		del = append(append([]*api.NQuad{}, del...), nqs...)
+	}
+	// 与dgraph一致,同一变更内先删除后写入
+	for _, nq := range del {
+		if err := s.delNquad(t, e, nq); err != nil {
+			return err
+		}
+	}
+	for _, nq := range set {
+		if err := s.setNquad(t, e, nq, uids); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolve 将nquad中的subject或objectId解析为uid列表
+// set为真时,空节点与为空的uid(v)会分配新的uid
+func (s *Server) resolve(e *env, id string, set bool, uids map[string]string) ([]uint64, error) {
+	switch {
+	case strings.HasPrefix(id, "_:"):
+		name := id[2:]
+		if u, ok := uids[name]; ok {
+			uid, _ := parseUid(u)
+			return []uint64{uid}, nil
+		}
+		if !set {
+			return nil, errors.New("blank node in delete " + id)
+		}
+		return []uint64{s.newUid(name, uids)}, nil
+	case strings.HasPrefix(id, "uid(") && strings.HasSuffix(id, ")"):
+		name := id[4 : len(id)-1]
+		us, err := e.uidArgs([]arg{{fn: "uid", ref: name}})
+		if err != nil {
+			return nil, err
+		}
+		if len(us) == 0 && set {
+			if u, ok := uids[id]; ok {
+				uid, _ := parseUid(u)
+				return []uint64{uid}, nil
+			}
+			return []uint64{s.newUid(id, uids)}, nil
+		}
+		return us, nil
+	}
+	uid, err := parseUid(id)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("invalid uid %q", id))
+	}
+	if uid > s.maxUid {
+		s.maxUid = uid
+	}
+	return []uint64{uid}, nil
+}
+
+func (s *Server) newUid(name string, uids map[string]string) uint64 {
+	s.maxUid++
+	uids[name] = fmt.Sprintf("0x%x", s.maxUid)
+	return s.maxUid
+}
+
+func (s *Server) setNquad(t *txn, e *env, nq *api.NQuad, uids map[string]string) error {
+	if nq.Predicate == starAll {
+		return errors.New("cannot set * predicate")
+	}
+	subs, err := s.resolve(e, nq.Subject, true, uids)
+	if err != nil {
+		return err
+	}
+	facets := facetsOf(nq.Facets)
+	for _, sub := range subs {
+		var vals []value
+		switch {
+		case strings.HasPrefix(nq.ObjectId, "val(") && strings.HasSuffix(nq.ObjectId, ")"):
+			v, ok := e.valVars[nq.ObjectId[4:len(nq.ObjectId)-1]][sub]
+			if !ok {
+				continue
+			}
+			vals = append(vals, v)
+		case nq.ObjectId != "":
+			objs, err := s.resolve(e, nq.ObjectId, true, uids)
+			if err != nil {
+				return err
+			}
+			for _, o := range objs {
+				vals = append(vals, value{uid: o})
+			}
+		default:
+			if nq.ObjectValue == nil {
+				return errors.New("nquad has neither object id nor object value")
+			}
+			v, err := t.g.convert(nq.Predicate, nq.ObjectValue)
+			if err != nil {
+				return err
+			}
+			vals = append(vals, v)
+		}
+		for _, v := range vals {
+			v.lang = nq.Lang
+			v.facets = facets
+			vv := v
+			t.g.set(sub, nq.Predicate, vv)
+			t.ops = append(t.ops, op{uid: sub, pred: nq.Predicate, v: &vv})
+			t.keys[key(sub, nq.Predicate)] = true
+		}
+	}
+	return nil
+}
+
+func (s *Server) delNquad(t *txn, e *env, nq *api.NQuad) error {
+	subs, err := s.resolve(e, nq.Subject, false, nil)
+	if err != nil {
+		return err
+	}
+	star := nq.ObjectId == starAll || isStar(nq.ObjectValue)
+	for _, sub := range subs {
+		var v *value
+		switch {
+		case nq.Predicate == starAll || star:
+		case nq.ObjectId != "":
+			objs, err := s.resolve(e, nq.ObjectId, false, nil)
+			if err != nil {
+				return err
+			}
+			for _, o := range objs {
+				ov := value{uid: o}
+				t.g.del(sub, nq.Predicate, "", &ov)
+				t.ops = append(t.ops, op{del: true, uid: sub, pred: nq.Predicate, v: &ov})
+			}
+			t.keys[key(sub, nq.Predicate)] = true
+			continue
+		default:
+			cv, err := t.g.convert(nq.Predicate, nq.ObjectValue)
+			if err != nil {
+				return err
+			}
+			cv.lang = nq.Lang
+			v = &cv
+		}
+		t.g.del(sub, nq.Predicate, nq.Lang, v)
+		t.ops = append(t.ops, op{del: true, uid: sub, pred: nq.Predicate, lang: nq.Lang, v: v})
+		t.keys[key(sub, nq.Predicate)] = true
+	}
+	return nil
+}
+
+func isStar(v *api.Value) bool {
+	if v == nil {
+		return false
+	}
+	d, ok := v.Val.(*api.Value_DefaultVal)
+	return ok && d.DefaultVal == starAll
+}
+
+func key(uid uint64, pred string) string {
+	return fmt.Sprintf("0x%x|%s", uid, pred)
+}
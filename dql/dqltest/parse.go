@@ -0,0 +1,742 @@
+/**
+ * @Author: daipengyuan
+ * @Description: dql子集的词法与语法解析,覆盖本库生成的查询、过滤器与upsert条件
+ * @File:  parse
+ * @Version: 1.0.0
+ * @Date: 2026/10/19 19:10
+ */
+
+package dqltest
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+const (
+	tkEOF = iota
+	tkIdent
+	tkString
+	tkNumber
+	tkRegex
+	tkPunct
+)
+
+type token struct {
+	kind int
+	val  string
+}
+
+// lex 将dql文本切分为token,$变量在此阶段替换为vars中的值
+func lex(s string, vars map[string]string) ([]token, error) {
+	var (
+		r  = []rune(s)
+		ts []token
+	)
+	for i := 0; i < len(r); {
+		c := r[i]
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '#':
+			for i < len(r) && r[i] != '\n' {
+				i++
+			}
+		case c == '"':
+			var sb strings.Builder
+			i++
+			for ; i < len(r) && r[i] != '"'; i++ {
+				if r[i] == '\\' && i+1 < len(r) {
+					i++
+					switch r[i] {
+					case 'n':
+						sb.WriteRune('\n')
+					case 't':
+						sb.WriteRune('\t')
+					default:
+						sb.WriteRune(r[i])
+					}
+					continue
+				}
+				sb.WriteRune(r[i])
+			}
+			if i >= len(r) {
+				return nil, errors.New("unterminated string")
+			}
+			i++
+			ts = append(ts, token{tkString, sb.String()})
+		case c == '/':
+			j := i + 1
+			for ; j < len(r) && r[j] != '/'; j++ {
+				if r[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(r) {
+				return nil, errors.New("unterminated regexp")
+			}
+			re := string(r[i+1 : j])
+			j++
+			flags := ""
+			for ; j < len(r) && unicode.IsLetter(r[j]); j++ {
+				flags += string(r[j])
+			}
+			if flags != "" {
+				re = "(?" + flags + ")" + re
+			}
+			ts = append(ts, token{tkRegex, re})
+			i = j
+		case c == '<':
+			j := i + 1
+			for ; j < len(r) && r[j] != '>'; j++ {
+			}
+			if j >= len(r) {
+				return nil, errors.New("unterminated iri")
+			}
+			ts = append(ts, token{tkIdent, string(r[i+1 : j])})
+			i = j + 1
+		case c == '$':
+			j := i + 1
+			for ; j < len(r) && isIdentRune(r[j]); j++ {
+			}
+			name := string(r[i:j])
+			v, ok := vars[name]
+			if !ok {
+				// 查询头中的变量声明,原样保留
+				ts = append(ts, token{tkIdent, name})
+			} else {
+				ts = append(ts, token{tkString, v})
+			}
+			i = j
+		case (c >= '0' && c <= '9') || ((c == '-' || c == '+') && i+1 < len(r) && r[i+1] >= '0' && r[i+1] <= '9'):
+			j := i + 1
+			for ; j < len(r) && (isIdentRune(r[j]) || r[j] == '.' || r[j] == '-' || r[j] == '+' || r[j] == ':'); j++ {
+			}
+			v := string(r[i:j])
+			if strings.HasPrefix(v, "0x") {
+				ts = append(ts, token{tkIdent, v})
+			} else {
+				ts = append(ts, token{tkNumber, v})
+			}
+			i = j
+		case isIdentRune(c) || c == '~':
+			j := i + 1
+			for ; j < len(r) && (isIdentRune(r[j]) || r[j] == '.'); j++ {
+			}
+			ts = append(ts, token{tkIdent, string(r[i:j])})
+			i = j
+		default:
+			ts = append(ts, token{tkPunct, string(c)})
+			i++
+		}
+	}
+	ts = append(ts, token{kind: tkEOF})
+	return ts, nil
+}
+
+func isIdentRune(c rune) bool {
+	return unicode.IsLetter(c) || unicode.IsDigit(c) || c == '_' || c == '~'
+}
+
+// arg 函数参数,可能是字面量、列表或变量引用uid(v)/val(v)/len(v)
+type arg struct {
+	val    string
+	quoted bool
+	regex  bool
+	list   []arg
+	fn     string // uid/val/len/count,参数为变量或谓词引用时使用
+	ref    string
+}
+
+func (a arg) isList() bool {
+	return a.list != nil
+}
+
+// fnode 函数调用,如eq(name,"x")、type(Person)、uid(a)
+type fnode struct {
+	name string
+	args []arg
+}
+
+// attr 第一个参数,通常为谓词名
+func (f *fnode) attr() arg {
+	if len(f.args) == 0 {
+		return arg{}
+	}
+	return f.args[0]
+}
+
+// expr 过滤或条件表达式
+type expr struct {
+	op   string // and/or/not/fn
+	subs []*expr
+	fn   *fnode
+}
+
+// block 查询块
+type block struct {
+	varName  string
+	name     string
+	root     *fnode
+	args     map[string]string
+	filter   *expr
+	sels     []*selection
+	recurse  int
+	loop     bool
+	cascade  bool
+	normal   bool
+	isSchema bool
+	schema   map[string][]string
+}
+
+// selection 查询块中的选择项
+type selection struct {
+	varName  string
+	alias    string
+	pred     string
+	lang     string
+	fn       string // uid/count/val/expand
+	ref      string // count/val/expand的参数
+	args     map[string]string
+	filter   *expr
+	facets   bool
+	fkeys    []string
+	ffilter  *expr
+	children []*selection
+}
+
+type parser struct {
+	ts  []token
+	pos int
+}
+
+func (p *parser) peek() token {
+	return p.ts[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.ts[p.pos]
+	if t.kind != tkEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) is(val string) bool {
+	t := p.peek()
+	return (t.kind == tkPunct || t.kind == tkIdent) && t.val == val
+}
+
+func (p *parser) expect(val string) error {
+	t := p.next()
+	if t.val != val || (t.kind != tkPunct && t.kind != tkIdent) {
+		return errors.New(fmt.Sprintf("expect %q, got %q", val, t.val))
+	}
+	return nil
+}
+
+func (p *parser) ident() (string, error) {
+	t := p.next()
+	if t.kind != tkIdent {
+		return "", errors.New(fmt.Sprintf("expect name, got %q", t.val))
+	}
+	return t.val, nil
+}
+
+// parseQuery 解析完整查询文本
+func parseQuery(q string, vars map[string]string) ([]*block, error) {
+	ts, err := lex(q, vars)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{ts: ts}
+	if p.peek().kind == tkEOF {
+		return nil, nil
+	}
+	if p.is("query") {
+		p.next()
+		if p.peek().kind == tkIdent {
+			p.next()
+		}
+		if p.is("(") {
+			// 跳过变量声明,变量值已在词法阶段替换
+			for !p.is(")") && p.peek().kind != tkEOF {
+				p.next()
+			}
+			p.next()
+		}
+	}
+	if p.is("schema") {
+		// schema查询可以不带外层大括号
+		b, err := p.parseBlock()
+		if err != nil {
+			return nil, err
+		}
+		return []*block{b}, nil
+	}
+	if err = p.expect("{"); err != nil {
+		return nil, err
+	}
+	var bs []*block
+	for !p.is("}") {
+		if p.peek().kind == tkEOF {
+			return nil, errors.New("unexpected end of query")
+		}
+		b, err := p.parseBlock()
+		if err != nil {
+			return nil, err
+		}
+		bs = append(bs, b)
+	}
+	return bs, nil
+}
+
+func (p *parser) parseBlock() (*block, error) {
+	b := &block{args: map[string]string{}}
+	name, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	if p.is("as") {
+		p.next()
+		b.varName = name
+		if name, err = p.ident(); err != nil {
+			return nil, err
+		}
+	}
+	b.name = name
+	if name == "schema" {
+		return p.parseSchemaBlock(b)
+	}
+	if err = p.expect("("); err != nil {
+		return nil, err
+	}
+	for !p.is(")") {
+		key, err := p.ident()
+		if err != nil {
+			return nil, err
+		}
+		if err = p.expect(":"); err != nil {
+			return nil, err
+		}
+		if key == "func" {
+			if b.root, err = p.parseFunc(); err != nil {
+				return nil, err
+			}
+		} else {
+			b.args[key] = p.next().val
+		}
+		if p.is(",") {
+			p.next()
+		}
+	}
+	p.next()
+	if b.root == nil {
+		return nil, errors.New(fmt.Sprintf("block %s has no root func", name))
+	}
+	for p.is("@") {
+		p.next()
+		d, err := p.ident()
+		if err != nil {
+			return nil, err
+		}
+		switch d {
+		case "filter":
+			if b.filter, err = p.parseParenExpr(); err != nil {
+				return nil, err
+			}
+		case "recurse":
+			b.recurse = -1
+			if p.is("(") {
+				p.next()
+				for !p.is(")") {
+					k, _ := p.ident()
+					p.expect(":")
+					v := p.next().val
+					if k == "depth" {
+						fmt.Sscanf(v, "%d", &b.recurse)
+					}
+					if k == "loop" {
+						b.loop = v == "true"
+					}
+					if p.is(",") {
+						p.next()
+					}
+				}
+				p.next()
+			}
+		case "cascade":
+			b.cascade = true
+			p.skipParen()
+		case "normalize":
+			b.normal = true
+		default:
+			p.skipParen()
+		}
+	}
+	if b.sels, err = p.parseSelections(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// parseSchemaBlock 解析schema{}、schema(pred: x){}、schema(type: [A,B]){}
+func (p *parser) parseSchemaBlock(b *block) (*block, error) {
+	b.isSchema = true
+	b.schema = map[string][]string{}
+	if p.is("(") {
+		p.next()
+		for !p.is(")") {
+			key, err := p.ident()
+			if err != nil {
+				return nil, err
+			}
+			if err = p.expect(":"); err != nil {
+				return nil, err
+			}
+			if p.is("[") {
+				p.next()
+				for !p.is("]") {
+					b.schema[key] = append(b.schema[key], p.next().val)
+					if p.is(",") {
+						p.next()
+					}
+				}
+				p.next()
+			} else {
+				b.schema[key] = append(b.schema[key], p.next().val)
+			}
+			if p.is(",") {
+				p.next()
+			}
+		}
+		p.next()
+	}
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	for !p.is("}") && p.peek().kind != tkEOF {
+		p.next()
+	}
+	return b, p.expect("}")
+}
+
+func (p *parser) skipParen() {
+	if !p.is("(") {
+		return
+	}
+	depth := 0
+	for {
+		t := p.next()
+		if t.kind == tkEOF {
+			return
+		}
+		if t.kind == tkPunct && t.val == "(" {
+			depth++
+		}
+		if t.kind == tkPunct && t.val == ")" {
+			if depth--; depth == 0 {
+				return
+			}
+		}
+	}
+}
+
+func (p *parser) parseSelections() ([]*selection, error) {
+	if !p.is("{") {
+		return nil, nil
+	}
+	p.next()
+	var sels []*selection
+	for !p.is("}") {
+		if p.peek().kind == tkEOF {
+			return nil, errors.New("unexpected end of selection")
+		}
+		s, err := p.parseSelection()
+		if err != nil {
+			return nil, err
+		}
+		sels = append(sels, s)
+		if p.is(",") {
+			p.next()
+		}
+	}
+	p.next()
+	return sels, nil
+}
+
+func (p *parser) parseSelection() (*selection, error) {
+	s := &selection{args: map[string]string{}}
+	name, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	if p.is("as") {
+		p.next()
+		s.varName = name
+		if name, err = p.ident(); err != nil {
+			return nil, err
+		}
+	}
+	if p.is(":") {
+		p.next()
+		s.alias = name
+		if name, err = p.ident(); err != nil {
+			return nil, err
+		}
+	}
+	switch name {
+	case "count", "val", "expand":
+		if p.is("(") {
+			p.next()
+			s.fn = name
+			if s.ref, err = p.ident(); err != nil {
+				return nil, err
+			}
+			if err = p.expect(")"); err != nil {
+				return nil, err
+			}
+		} else {
+			s.pred = name
+		}
+	case "uid":
+		s.fn = "uid"
+	default:
+		s.pred = name
+	}
+	if p.is("@") && p.ts[p.pos+1].kind == tkIdent && !isDirective(p.ts[p.pos+1].val) {
+		p.next()
+		s.lang = p.next().val
+	} else if p.is("@") && p.ts[p.pos+1].val == "*" {
+		// pred@*返回所有语言的值
+		p.next()
+		s.lang = p.next().val
+	}
+	if p.is("(") {
+		p.next()
+		for !p.is(")") {
+			k, err := p.ident()
+			if err != nil {
+				return nil, err
+			}
+			p.expect(":")
+			s.args[k] = p.next().val
+			if p.is(",") {
+				p.next()
+			}
+		}
+		p.next()
+	}
+	for p.is("@") {
+		p.next()
+		d, err := p.ident()
+		if err != nil {
+			return nil, err
+		}
+		switch d {
+		case "filter":
+			if s.filter, err = p.parseParenExpr(); err != nil {
+				return nil, err
+			}
+		case "facets":
+			s.facets = true
+			if p.is("(") {
+				// @facets(key1,key2) 或 @facets(eq(key,val))
+				if p.ts[p.pos+2].kind == tkPunct && p.ts[p.pos+2].val == "(" || isBoolOp(p.ts[p.pos+1].val) {
+					if s.ffilter, err = p.parseParenExpr(); err != nil {
+						return nil, err
+					}
+				} else {
+					p.next()
+					for !p.is(")") {
+						s.fkeys = append(s.fkeys, p.next().val)
+						if p.is(",") {
+							p.next()
+						}
+					}
+					p.next()
+				}
+			}
+		default:
+			p.skipParen()
+		}
+	}
+	if s.children, err = p.parseSelections(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func isDirective(s string) bool {
+	switch s {
+	case "filter", "facets", "cascade", "normalize", "recurse":
+		return true
+	}
+	return false
+}
+
+func isBoolOp(s string) bool {
+	switch strings.ToUpper(s) {
+	case "AND", "OR", "NOT":
+		return true
+	}
+	return false
+}
+
+func (p *parser) parseParenExpr() (*expr, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	return e, p.expect(")")
+}
+
+func (p *parser) parseOr() (*expr, error) {
+	l, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tkIdent && strings.ToUpper(p.peek().val) == "OR" {
+		p.next()
+		r, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l = &expr{op: "or", subs: []*expr{l, r}}
+	}
+	return l, nil
+}
+
+func (p *parser) parseAnd() (*expr, error) {
+	l, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tkIdent && strings.ToUpper(p.peek().val) == "AND" {
+		p.next()
+		r, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l = &expr{op: "and", subs: []*expr{l, r}}
+	}
+	return l, nil
+}
+
+func (p *parser) parseUnary() (*expr, error) {
+	if p.peek().kind == tkIdent && strings.ToUpper(p.peek().val) == "NOT" {
+		p.next()
+		e, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &expr{op: "not", subs: []*expr{e}}, nil
+	}
+	if p.is("(") {
+		return p.parseParenExpr()
+	}
+	f, err := p.parseFunc()
+	if err != nil {
+		return nil, err
+	}
+	return &expr{op: "fn", fn: f}, nil
+}
+
+func (p *parser) parseFunc() (*fnode, error) {
+	name, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	f := &fnode{name: strings.ToLower(name)}
+	if err = p.expect("("); err != nil {
+		return nil, err
+	}
+	for !p.is(")") {
+		a, err := p.parseArg()
+		if err != nil {
+			return nil, err
+		}
+		f.args = append(f.args, a)
+		if p.is(",") {
+			p.next()
+		}
+	}
+	p.next()
+	return f, nil
+}
+
+func (p *parser) parseArg() (arg, error) {
+	t := p.peek()
+	if p.is("[") {
+		p.next()
+		a := arg{list: []arg{}}
+		for !p.is("]") {
+			if p.peek().kind == tkEOF {
+				return arg{}, errors.New("unterminated list")
+			}
+			v, err := p.parseArg()
+			if err != nil {
+				return arg{}, err
+			}
+			a.list = append(a.list, v)
+			if p.is(",") {
+				p.next()
+			}
+		}
+		p.next()
+		return a, nil
+	}
+	p.next()
+	switch t.kind {
+	case tkString:
+		return arg{val: t.val, quoted: true}, nil
+	case tkRegex:
+		return arg{val: t.val, regex: true}, nil
+	case tkNumber:
+		return arg{val: t.val}, nil
+	case tkIdent:
+		switch t.val {
+		case "uid", "val", "len", "count":
+			if p.is("(") {
+				p.next()
+				var refs []string
+				for !p.is(")") {
+					refs = append(refs, p.next().val)
+					if p.is(",") {
+						p.next()
+					}
+				}
+				p.next()
+				return arg{fn: t.val, ref: strings.Join(refs, ",")}, nil
+			}
+		}
+		if p.is("@") {
+			p.next()
+			return arg{val: t.val + "@" + p.next().val}, nil
+		}
+		return arg{val: t.val}, nil
+	}
+	return arg{}, errors.New(fmt.Sprintf("unexpected %q in function args", t.val))
+}
+
+// parseCond 解析@if(...)条件
+func parseCond(cond string) (*expr, error) {
+	cond = strings.TrimSpace(cond)
+	if cond == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(cond, "@if") {
+		return nil, errors.New("cond must start with @if")
+	}
+	ts, err := lex(strings.TrimPrefix(cond, "@if"), nil)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{ts: ts}
+	return p.parseParenExpr()
+}
@@ -0,0 +1,970 @@
+/**
+ * @Author: daipengyuan
+ * @Description: 查询执行,根函数、过滤器、变量、分页排序与结果拼装
+ * @File:  query
+ * @Version: 1.0.0
+ * @Date: 2026/10/19 20:15
+ */
+
+package dqltest
+
+import (
+	"errors"
+	"fmt"
+	"github.com/golang-common/dglib/dql"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const maxRecurse = 32
+
+// env 一次请求的执行环境,变量在查询与变更之间共享
+type env struct {
+	g       *graph
+	uidVars map[string][]uint64
+	valVars map[string]map[uint64]value
+	block   *block
+}
+
+func newEnv(g *graph) *env {
+	return &env{g: g, uidVars: map[string][]uint64{}, valVars: map[string]map[uint64]value{}}
+}
+
+// run 执行查询块,定义变量的块先于引用变量的块执行
+func (e *env) run(blocks []*block) (map[string]interface{}, error) {
+	var (
+		res     = map[string]interface{}{}
+		pending = blocks
+	)
+	for len(pending) > 0 {
+		var rest []*block
+		for _, b := range pending {
+			if !e.ready(b) {
+				rest = append(rest, b)
+				continue
+			}
+			if err := e.runBlock(b, res); err != nil {
+				return nil, err
+			}
+			e.define(b)
+		}
+		if len(rest) == len(pending) {
+			return nil, errors.New("query uses variables that are not defined")
+		}
+		pending = rest
+	}
+	return res, nil
+}
+
+// ready 块引用的变量都已经定义,自身定义的变量除外
+func (e *env) ready(b *block) bool {
+	own := map[string]bool{}
+	var collectOwn func(sels []*selection)
+	collectOwn = func(sels []*selection) {
+		for _, s := range sels {
+			if s.varName != "" {
+				own[s.varName] = true
+			}
+			collectOwn(s.children)
+		}
+	}
+	collectOwn(b.sels)
+	for _, ref := range blockRefs(b) {
+		if own[ref] {
+			continue
+		}
+		_, ok1 := e.uidVars[ref]
+		_, ok2 := e.valVars[ref]
+		if !ok1 && !ok2 {
+			return false
+		}
+	}
+	return true
+}
+
+// define 登记块中定义但没有赋值的变量(所在层没有匹配的节点),引用时视为空
+func (e *env) define(b *block) {
+	var walk func(sels []*selection)
+	walk = func(sels []*selection) {
+		for _, s := range sels {
+			if s.varName != "" && !e.isVar(s.varName) {
+				e.uidVars[s.varName] = nil
+			}
+			walk(s.children)
+		}
+	}
+	walk(b.sels)
+}
+
+func blockRefs(b *block) []string {
+	var refs []string
+	var fromArgs func(as []arg)
+	fromArgs = func(as []arg) {
+		for _, a := range as {
+			if a.fn != "" && a.fn != "count" {
+				refs = append(refs, strings.Split(a.ref, ",")...)
+			}
+			fromArgs(a.list)
+		}
+	}
+	var fromExpr func(x *expr)
+	fromExpr = func(x *expr) {
+		if x == nil {
+			return
+		}
+		if x.fn != nil {
+			fromArgs(x.fn.args)
+		}
+		for _, s := range x.subs {
+			fromExpr(s)
+		}
+	}
+	var fromSels func(sels []*selection)
+	fromSels = func(sels []*selection) {
+		for _, s := range sels {
+			if s.fn == "val" {
+				refs = append(refs, s.ref)
+			}
+			fromExpr(s.filter)
+			fromSels(s.children)
+		}
+	}
+	if b.root != nil {
+		fromArgs(b.root.args)
+	}
+	fromExpr(b.filter)
+	fromSels(b.sels)
+	return refs
+}
+
+func (e *env) runBlock(b *block, res map[string]interface{}) error {
+	if b.isSchema {
+		e.schema(b, res)
+		return nil
+	}
+	uids, err := e.rootUids(b.root)
+	if err != nil {
+		return err
+	}
+	if uids, err = e.filterUids(uids, b.filter); err != nil {
+		return err
+	}
+	uids = e.page(uids, b.args)
+	if b.varName != "" {
+		e.uidVars[b.varName] = uids
+	}
+	e.block = b
+	depth := b.recurse
+	if depth < 0 {
+		// 未指定深度时限制递归层数,避免环路
+		depth = maxRecurse
+	}
+	var objs = []interface{}{}
+	for _, uid := range uids {
+		obj, err := e.object(uid, b.sels, depth)
+		if err != nil {
+			return err
+		}
+		if len(obj) > 0 {
+			objs = append(objs, obj)
+		}
+	}
+	if b.name != "var" {
+		res[b.name] = objs
+	}
+	return nil
+}
+
+func (e *env) schema(b *block, res map[string]interface{}) {
+	var (
+		preds []interface{}
+		types []interface{}
+	)
+	_, onlyType := b.schema["type"]
+	_, onlyPred := b.schema["pred"]
+	if !onlyType {
+		var names []string
+		for k := range e.g.preds {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		for _, n := range names {
+			if onlyPred && !contains(b.schema["pred"], n) {
+				continue
+			}
+			preds = append(preds, e.g.preds[n])
+		}
+		res["schema"] = preds
+	}
+	if !onlyPred {
+		var names []string
+		for k := range e.g.types {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		for _, n := range names {
+			if onlyType && !contains(b.schema["type"], n) {
+				continue
+			}
+			types = append(types, e.g.types[n])
+		}
+		res["types"] = types
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// rootUids 执行根函数
+func (e *env) rootUids(f *fnode) ([]uint64, error) {
+	if f.name == dql.FuncUid {
+		return e.uidArgs(f.args)
+	}
+	var r []uint64
+	for _, uid := range e.g.uids() {
+		ok, err := e.match(uid, f)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			r = append(r, uid)
+		}
+	}
+	return r, nil
+}
+
+// uidArgs 解析uid(0x1,0x2)、uid([...])、uid(a)中的uid列表
+func (e *env) uidArgs(args []arg) ([]uint64, error) {
+	var (
+		r    []uint64
+		seen = map[uint64]bool{}
+	)
+	add := func(u uint64) {
+		if !seen[u] {
+			seen[u] = true
+			r = append(r, u)
+		}
+	}
+	for _, a := range args {
+		if a.isList() {
+			sub, err := e.uidArgs(a.list)
+			if err != nil {
+				return nil, err
+			}
+			for _, u := range sub {
+				add(u)
+			}
+			continue
+		}
+		// 没有匹配节点的变量已由define登记为空,从未定义的变量与dgraph一样报错
+		if a.fn == "uid" || (a.fn == "" && !a.quoted && (e.isVar(a.val) || !isUidLiteral(a.val))) {
+			ref := a.ref
+			if a.fn == "" {
+				ref = a.val
+			}
+			for _, name := range strings.Split(ref, ",") {
+				name = strings.TrimSpace(name)
+				if !e.isVar(name) {
+					return nil, errors.New(fmt.Sprintf("variable %s is used but not defined", name))
+				}
+				if vs, ok := e.uidVars[name]; ok {
+					for _, u := range vs {
+						add(u)
+					}
+					continue
+				}
+				for u := range e.valVars[name] {
+					add(u)
+				}
+			}
+			continue
+		}
+		u, err := parseUid(a.val)
+		if err != nil {
+			return nil, err
+		}
+		add(u)
+	}
+	sort.Slice(r, func(i, j int) bool { return r[i] < r[j] })
+	return r, nil
+}
+
+func (e *env) isVar(name string) bool {
+	_, ok1 := e.uidVars[name]
+	_, ok2 := e.valVars[name]
+	return ok1 || ok2
+}
+
+func isUidLiteral(s string) bool {
+	s = strings.TrimSpace(s)
+	return s != "" && (strings.HasPrefix(s, "0x") || s[0] >= '0' && s[0] <= '9')
+}
+
+func parseUid(s string) (uint64, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "0x") {
+		return strconv.ParseUint(s[2:], 16, 64)
+	}
+	return strconv.ParseUint(s, 10, 64)
+}
+
+func (e *env) filterUids(uids []uint64, f *expr) ([]uint64, error) {
+	if f == nil {
+		return uids, nil
+	}
+	var r []uint64
+	for _, uid := range uids {
+		ok, err := e.eval(uid, f)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			r = append(r, uid)
+		}
+	}
+	return r, nil
+}
+
+func (e *env) eval(uid uint64, x *expr) (bool, error) {
+	switch x.op {
+	case "and", "or":
+		l, err := e.eval(uid, x.subs[0])
+		if err != nil {
+			return false, err
+		}
+		if x.op == "and" && !l {
+			return false, nil
+		}
+		if x.op == "or" && l {
+			return true, nil
+		}
+		return e.eval(uid, x.subs[1])
+	case "not":
+		r, err := e.eval(uid, x.subs[0])
+		return !r, err
+	}
+	return e.match(uid, x.fn)
+}
+
+// match 判断节点是否满足函数
+func (e *env) match(uid uint64, f *fnode) (bool, error) {
+	attr := f.attr()
+	switch f.name {
+	case dql.FuncType:
+		return contains(e.g.nodeTypes(uid), attr.val), nil
+	case dql.FuncHas:
+		return len(e.g.get(uid, attr.val)) > 0, nil
+	case dql.FuncUid:
+		us, err := e.uidArgs(f.args)
+		if err != nil {
+			return false, err
+		}
+		return containsUid(us, uid), nil
+	case dql.FuncUidIn:
+		us, err := e.uidArgs(f.args[1:])
+		if err != nil {
+			return false, err
+		}
+		for _, v := range e.g.get(uid, attr.val) {
+			if containsUid(us, v.uid) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case dql.FuncEq, dql.FuncGe, dql.FuncGt, dql.FuncLe, dql.FuncLt:
+		if len(f.args) < 2 {
+			return false, errors.New(f.name + " needs two arguments")
+		}
+		var rhs = []arg{f.args[1]}
+		if f.args[1].isList() {
+			rhs = f.args[1].list
+		}
+		for _, v := range e.lhs(uid, attr) {
+			for _, a := range rhs {
+				c, ok := compare(v, a)
+				if ok && cmpResult(f.name, c) {
+					return true, nil
+				}
+			}
+		}
+		return false, nil
+	case dql.FuncBetween:
+		if len(f.args) < 3 {
+			return false, errors.New("between needs three arguments")
+		}
+		for _, v := range e.lhs(uid, attr) {
+			lo, ok1 := compare(v, f.args[1])
+			hi, ok2 := compare(v, f.args[2])
+			if ok1 && ok2 && lo >= 0 && hi <= 0 {
+				return true, nil
+			}
+		}
+		return false, nil
+	case dql.FuncTermAll, dql.FuncTermAny, dql.FuncTextAll, dql.FuncTextAny:
+		if len(f.args) < 2 {
+			return false, errors.New(f.name + " needs two arguments")
+		}
+		want := terms(f.args[1].val)
+		for _, v := range e.lhs(uid, attr) {
+			s, ok := v.val.(string)
+			if !ok {
+				continue
+			}
+			have := map[string]bool{}
+			for _, t := range terms(s) {
+				have[t] = true
+			}
+			n := 0
+			for _, t := range want {
+				if have[t] {
+					n++
+				}
+			}
+			all := f.name == dql.FuncTermAll || f.name == dql.FuncTextAll
+			if (all && n == len(want) && n > 0) || (!all && n > 0) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case dql.FuncRegexp:
+		if len(f.args) < 2 {
+			return false, errors.New("regexp needs two arguments")
+		}
+		re, err := regexp.Compile(f.args[1].val)
+		if err != nil {
+			return false, err
+		}
+		for _, v := range e.lhs(uid, attr) {
+			if s, ok := v.val.(string); ok && re.MatchString(s) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case dql.FuncMatch:
+		if len(f.args) < 3 {
+			return false, errors.New("match needs three arguments")
+		}
+		dist, _ := strconv.Atoi(f.args[2].val)
+		for _, v := range e.lhs(uid, attr) {
+			if s, ok := v.val.(string); ok && levenshtein(s, f.args[1].val) <= dist {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return false, errors.New(fmt.Sprintf("function %s is not supported by dqltest", f.name))
+}
+
+// lhs 比较函数左侧的值,支持谓词、谓词@语言、count(pred)与val(v)
+func (e *env) lhs(uid uint64, a arg) []value {
+	switch a.fn {
+	case "count":
+		return []value{{val: int64(len(e.g.get(uid, a.ref))), tp: dql.TypeInt}}
+	case "val":
+		if v, ok := e.valVars[a.ref][uid]; ok {
+			return []value{v}
+		}
+		return nil
+	}
+	pred, lang := splitLang(a.val)
+	var r []value
+	for _, v := range e.g.get(uid, pred) {
+		if lang == "" || v.lang == lang {
+			r = append(r, v)
+		}
+	}
+	return r
+}
+
+func splitLang(s string) (string, string) {
+	if i := strings.Index(s, "@"); i >= 0 {
+		return s[:i], s[i+1:]
+	}
+	return s, ""
+}
+
+func containsUid(us []uint64, u uint64) bool {
+	for _, v := range us {
+		if v == u {
+			return true
+		}
+	}
+	return false
+}
+
+func cmpResult(fn string, c int) bool {
+	switch fn {
+	case dql.FuncEq:
+		return c == 0
+	case dql.FuncGe:
+		return c >= 0
+	case dql.FuncGt:
+		return c > 0
+	case dql.FuncLe:
+		return c <= 0
+	case dql.FuncLt:
+		return c < 0
+	}
+	return false
+}
+
+// compare 按存储值的类型解析参数并比较,无法比较时第二个返回值为false
+func compare(v value, a arg) (int, bool) {
+	switch x := v.val.(type) {
+	case int64:
+		if i, err := strconv.ParseInt(a.val, 10, 64); err == nil {
+			return cmpFloat(float64(x), float64(i)), true
+		}
+		if f, err := strconv.ParseFloat(a.val, 64); err == nil {
+			return cmpFloat(float64(x), f), true
+		}
+	case float64:
+		if f, err := strconv.ParseFloat(a.val, 64); err == nil {
+			return cmpFloat(x, f), true
+		}
+	case bool:
+		if b, err := strconv.ParseBool(a.val); err == nil {
+			if x == b {
+				return 0, true
+			}
+			return 1, true
+		}
+	case time.Time:
+		if t, err := parseTime(a.val); err == nil {
+			switch {
+			case x.Before(t):
+				return -1, true
+			case x.After(t):
+				return 1, true
+			}
+			return 0, true
+		}
+	case string:
+		return strings.Compare(x, a.val), true
+	}
+	return 0, false
+}
+
+func cmpFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func terms(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur := make([]int, len(rb)+1)
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(rb)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// page 排序与分页 orderasc/orderdesc、after、offset、first
+func (e *env) page(uids []uint64, args map[string]string) []uint64 {
+	if len(args) == 0 {
+		return uids
+	}
+	if pred, ok := args["orderasc"]; ok {
+		e.sortBy(uids, pred, false)
+	} else if pred, ok := args["orderdesc"]; ok {
+		e.sortBy(uids, pred, true)
+	}
+	if s, ok := args["after"]; ok {
+		after, _ := parseUid(s)
+		var r []uint64
+		for _, u := range uids {
+			if u > after {
+				r = append(r, u)
+			}
+		}
+		uids = r
+	}
+	if s, ok := args["offset"]; ok {
+		off, _ := strconv.Atoi(s)
+		if off >= len(uids) {
+			return nil
+		}
+		uids = uids[off:]
+	}
+	if s, ok := args["first"]; ok {
+		first, _ := strconv.Atoi(s)
+		if first >= 0 && first < len(uids) {
+			uids = uids[:first]
+		}
+		if first < 0 && -first < len(uids) {
+			uids = uids[len(uids)+first:]
+		}
+	}
+	return uids
+}
+
+func (e *env) sortBy(uids []uint64, pred string, desc bool) {
+	key := func(u uint64) (value, bool) {
+		vs := e.lhs(u, arg{val: pred})
+		if strings.HasPrefix(pred, "val(") {
+			vs = e.lhs(u, arg{fn: "val", ref: strings.TrimSuffix(strings.TrimPrefix(pred, "val("), ")")})
+		}
+		if len(vs) == 0 {
+			return value{}, false
+		}
+		return vs[0], true
+	}
+	sort.SliceStable(uids, func(i, j int) bool {
+		a, oka := key(uids[i])
+		b, okb := key(uids[j])
+		if !oka || !okb {
+			// 无值的节点排在最后
+			return oka && !okb
+		}
+		c, _ := compare(a, arg{val: fmt.Sprintf("%v", jsonValue(b))})
+		if desc {
+			return c > 0
+		}
+		return c < 0
+	})
+}
+
+// object 按选择项输出节点,depth用于@recurse
+func (e *env) object(uid uint64, sels []*selection, depth int) (map[string]interface{}, error) {
+	obj := map[string]interface{}{}
+	for _, s := range sels {
+		key := s.alias
+		switch s.fn {
+		case "uid":
+			if key == "" {
+				key = "uid"
+			}
+			obj[key] = fmt.Sprintf("0x%x", uid)
+			continue
+		case "count":
+			if key == "" {
+				key = "count(" + s.ref + ")"
+			}
+			obj[key] = len(e.g.get(uid, s.ref))
+			continue
+		case "val":
+			if key == "" {
+				key = "val(" + s.ref + ")"
+			}
+			if v, ok := e.valVars[s.ref][uid]; ok {
+				obj[key] = jsonValue(v)
+			}
+			continue
+		case "expand":
+			for _, pred := range e.expandPreds(uid) {
+				if err := e.predicate(obj, uid, &selection{pred: pred, children: s.children, args: map[string]string{}}, depth); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+		if err := e.predicate(obj, uid, s, depth); err != nil {
+			return nil, err
+		}
+	}
+	if e.block != nil && e.block.cascade {
+		for _, s := range sels {
+			if s.fn == "" && s.pred != "" {
+				if _, ok := obj[outKey(s)]; !ok {
+					return nil, nil
+				}
+			}
+		}
+	}
+	return obj, nil
+}
+
+func outKey(s *selection) string {
+	if s.alias != "" {
+		return s.alias
+	}
+	if s.lang != "" {
+		return s.pred + "@" + s.lang
+	}
+	return s.pred
+}
+
+// expandPreds expand(_all_)展开的谓词,取节点类型定义中的字段
+func (e *env) expandPreds(uid uint64) []string {
+	var (
+		r    []string
+		seen = map[string]bool{}
+	)
+	for _, tn := range e.g.nodeTypes(uid) {
+		for _, f := range e.g.types[tn].Fields {
+			if !seen[f.Name] {
+				seen[f.Name] = true
+				r = append(r, f.Name)
+			}
+		}
+	}
+	if len(r) == 0 {
+		for pred := range e.g.nodes[uid] {
+			r = append(r, pred)
+		}
+		sort.Strings(r)
+	}
+	return r
+}
+
+func (e *env) isUidPred(pred string, vals []value) bool {
+	if strings.HasPrefix(pred, "~") {
+		return true
+	}
+	if p, ok := e.g.preds[pred]; ok {
+		return p.Type == dql.TypeUid
+	}
+	return len(vals) > 0 && vals[0].uid != 0
+}
+
+func (e *env) predicate(obj map[string]interface{}, uid uint64, s *selection, depth int) error {
+	key := outKey(s)
+	vals := e.g.get(uid, s.pred)
+	if e.isUidPred(s.pred, vals) {
+		var targets []uint64
+		facets := map[uint64]map[string]interface{}{}
+		for _, v := range vals {
+			if s.ffilter != nil && !facetMatch(v.facets, s.ffilter) {
+				continue
+			}
+			targets = append(targets, v.uid)
+			facets[v.uid] = v.facets
+		}
+		targets, err := e.filterUids(targets, s.filter)
+		if err != nil {
+			return err
+		}
+		targets = e.page(targets, s.args)
+		if s.varName != "" {
+			e.uidVars[s.varName] = appendUids(e.uidVars[s.varName], targets)
+		}
+		children := s.children
+		if len(children) == 0 && depth != 0 && e.block != nil {
+			// @recurse时沿用块的选择项
+			children = e.block.sels
+		}
+		if depth > 0 {
+			depth--
+			if depth == 0 {
+				return nil
+			}
+		}
+		var objs []interface{}
+		for _, t := range targets {
+			var child map[string]interface{}
+			if len(children) == 0 {
+				child = map[string]interface{}{"uid": fmt.Sprintf("0x%x", t)}
+			} else if child, err = e.object(t, children, depth); err != nil {
+				return err
+			}
+			if s.facets {
+				for k, fv := range facets[t] {
+					if len(s.fkeys) == 0 || contains(s.fkeys, k) {
+						child[s.pred+"|"+k] = jsonValue(value{val: fv})
+					}
+				}
+			}
+			if len(child) > 0 {
+				objs = append(objs, child)
+			}
+		}
+		if len(objs) == 0 {
+			return nil
+		}
+		if p, ok := e.g.preds[s.pred]; ok && !p.List && !strings.HasPrefix(s.pred, "~") {
+			obj[key] = objs[0]
+			return nil
+		}
+		obj[key] = objs
+		return nil
+	}
+	if s.lang == "*" {
+		e.allLangs(obj, s.pred, vals)
+		return nil
+	}
+	var out []value
+	for _, v := range vals {
+		if v.tp == dql.TypePassword {
+			continue
+		}
+		if v.lang == s.lang {
+			out = append(out, v)
+		}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	if s.varName != "" {
+		if e.valVars[s.varName] == nil {
+			e.valVars[s.varName] = map[uint64]value{}
+		}
+		e.valVars[s.varName][uid] = out[0]
+	}
+	if s.facets {
+		for k, fv := range out[0].facets {
+			if len(s.fkeys) == 0 || contains(s.fkeys, k) {
+				obj[s.pred+"|"+k] = jsonValue(value{val: fv})
+			}
+		}
+	}
+	if p, ok := e.g.preds[s.pred]; ok && p.List {
+		var list []interface{}
+		for _, v := range out {
+			list = append(list, jsonValue(v))
+		}
+		obj[key] = list
+		return nil
+	}
+	obj[key] = jsonValue(out[0])
+	return nil
+}
+
+// allLangs pred@*按语言输出,无语言的值键为pred,其余为pred@lang
+func (e *env) allLangs(obj map[string]interface{}, pred string, vals []value) {
+	list := e.g.preds[pred].List
+	for _, v := range vals {
+		if v.tp == dql.TypePassword {
+			continue
+		}
+		key := pred
+		if v.lang != "" {
+			key += "@" + v.lang
+		}
+		if !list {
+			obj[key] = jsonValue(v)
+			continue
+		}
+		old, _ := obj[key].([]interface{})
+		obj[key] = append(old, jsonValue(v))
+	}
+}
+
+func appendUids(list []uint64, us []uint64) []uint64 {
+	for _, u := range us {
+		if !containsUid(list, u) {
+			list = append(list, u)
+		}
+	}
+	return list
+}
+
+// facetMatch 面过滤,支持eq/ge/gt/le/lt/allofterms/anyofterms及布尔组合
+func facetMatch(fs map[string]interface{}, x *expr) bool {
+	switch x.op {
+	case "and":
+		return facetMatch(fs, x.subs[0]) && facetMatch(fs, x.subs[1])
+	case "or":
+		return facetMatch(fs, x.subs[0]) || facetMatch(fs, x.subs[1])
+	case "not":
+		return !facetMatch(fs, x.subs[0])
+	}
+	if len(x.fn.args) < 2 {
+		return false
+	}
+	fv, ok := fs[x.fn.attr().val]
+	if !ok {
+		return false
+	}
+	v := value{val: fv}
+	if i, ok := fv.(int64); ok {
+		v.val = i
+	}
+	switch x.fn.name {
+	case dql.FuncTermAll, dql.FuncTermAny:
+		s, _ := fv.(string)
+		have := strings.Join(terms(s), " ")
+		for _, t := range terms(x.fn.args[1].val) {
+			in := strings.Contains(" "+have+" ", " "+t+" ")
+			if x.fn.name == dql.FuncTermAny && in {
+				return true
+			}
+			if x.fn.name == dql.FuncTermAll && !in {
+				return false
+			}
+		}
+		return x.fn.name == dql.FuncTermAll
+	}
+	c, ok := compare(v, x.fn.args[1])
+	return ok && cmpResult(x.fn.name, c)
+}
+
+// cond 计算upsert的@if条件,支持len(v)与常量比较及布尔组合
+func (e *env) cond(x *expr) (bool, error) {
+	if x == nil {
+		return true, nil
+	}
+	switch x.op {
+	case "and", "or":
+		l, err := e.cond(x.subs[0])
+		if err != nil {
+			return false, err
+		}
+		r, err := e.cond(x.subs[1])
+		if err != nil {
+			return false, err
+		}
+		if x.op == "and" {
+			return l && r, nil
+		}
+		return l || r, nil
+	case "not":
+		r, err := e.cond(x.subs[0])
+		return !r, err
+	}
+	f := x.fn
+	if len(f.args) != 2 || f.args[0].fn != "len" {
+		return false, errors.New(fmt.Sprintf("unsupported condition %s, need len(var) comparison", f.name))
+	}
+	n := len(e.uidVars[f.args[0].ref])
+	if vs, ok := e.valVars[f.args[0].ref]; ok && n == 0 {
+		n = len(vs)
+	}
+	c, ok := compare(value{val: int64(n)}, f.args[1])
+	if !ok {
+		return false, errors.New("condition needs integer operand")
+	}
+	return cmpResult(f.name, c), nil
+}
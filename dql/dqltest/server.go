@@ -0,0 +1,300 @@
+/**
+ * @Author: daipengyuan
+ * @Description: 进程内模拟的dgraph grpc服务,用于在没有dgraph的环境中测试
+ * @File:  server
+ * @Version: 1.0.0
+ * @Date: 2026/10/19 21:00
+ */
+
+package dqltest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/dgraph-io/dgo/v200/protos/api"
+	"github.com/golang-common/dglib/dql"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"net"
+	"sync"
+	"testing"
+)
+
+const Version = "v21.03.0-dqltest"
+
+// commit 一次提交后的数据版本,keys为本次提交写入的键
+type commit struct {
+	ts   uint64
+	g    *graph
+	keys map[string]bool
+}
+
+// txn 未提交的事务,g为事务内可见的数据,ops在提交时重放到最新版本
+type txn struct {
+	startTs uint64
+	g       *graph
+	ops     []op
+	keys    map[string]bool
+}
+
+// op 已解析uid的单个写操作
+type op struct {
+	del  bool
+	uid  uint64
+	pred string
+	lang string
+	v    *value
+}
+
+// Server 实现api.DgraphServer,数据保存在内存中
+// 支持本库生成的查询、upsert条件与删除,不支持的语法返回错误
+type Server struct {
+	AdminToken string // 设置后Alter请求必须携带相同的auth-token
+
+	mu      sync.Mutex
+	ts      uint64
+	maxUid  uint64
+	commits []*commit
+	txns    map[uint64]*txn
+	grpc    *grpc.Server
+	lis     net.Listener
+}
+
+// NewServer 新建空的模拟服务,调用Start后开始监听
+func NewServer() *Server {
+	return &Server{
+		commits: []*commit{{g: newGraph()}},
+		txns:    map[uint64]*txn{},
+	}
+}
+
+// Start 在本地随机端口启动grpc服务
+func (s *Server) Start() error {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	s.lis = lis
+	s.grpc = grpc.NewServer()
+	api.RegisterDgraphServer(s.grpc, s)
+	go s.grpc.Serve(lis)
+	return nil
+}
+
+// Addr 服务监听地址,可直接作为dql.Config.Targets使用
+func (s *Server) Addr() string {
+	return s.lis.Addr().String()
+}
+
+func (s *Server) Stop() {
+	s.grpc.Stop()
+}
+
+// Config 连接到本服务的配置
+func (s *Server) Config() dql.Config {
+	return dql.Config{Targets: []string{s.Addr()}, AdminToken: s.AdminToken, HealthInterval: -1}
+}
+
+// StartServer 启动模拟服务,测试结束时自动停止
+func StartServer(t testing.TB) *Server {
+	s := NewServer()
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Stop)
+	return s
+}
+
+// NewClient 启动模拟服务并返回连接到它的dql.Client
+func NewClient(t testing.TB) *dql.Client {
+	return StartServer(t).Client(t)
+}
+
+// Client 返回连接到本服务的dql.Client,测试结束时自动关闭
+func (s *Server) Client(t testing.TB) *dql.Client {
+	c, err := dql.NewClient(s.Config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func (s *Server) latest() *commit {
+	return s.commits[len(s.commits)-1]
+}
+
+// snapshot 读时间戳ts时可见的数据
+func (s *Server) snapshot(ts uint64) *graph {
+	for i := len(s.commits) - 1; i >= 0; i-- {
+		if s.commits[i].ts <= ts {
+			return s.commits[i].g
+		}
+	}
+	return s.commits[0].g
+}
+
+func (s *Server) Login(ctx context.Context, req *api.LoginRequest) (*api.Response, error) {
+	jwt := &api.Jwt{AccessJwt: "dqltest-access", RefreshJwt: "dqltest-refresh"}
+	bs, err := jwt.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	return &api.Response{Json: bs}, nil
+}
+
+func (s *Server) CheckVersion(ctx context.Context, req *api.Check) (*api.Version, error) {
+	return &api.Version{Tag: Version}, nil
+}
+
+func (s *Server) Alter(ctx context.Context, op *api.Operation) (*api.Payload, error) {
+	if s.AdminToken != "" {
+		md, _ := metadata.FromIncomingContext(ctx)
+		tokens := md.Get(dql.AdminTokenMd)
+		if len(tokens) == 0 {
+			return nil, status.Error(codes.PermissionDenied, "No Auth Token found. Token needed for Admin operations.")
+		}
+		if tokens[0] != s.AdminToken {
+			return nil, status.Error(codes.PermissionDenied, fmt.Sprintf("Provided auth token [%s] does not match. Permission denied.", tokens[0]))
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	g := s.latest().g.clone()
+	switch {
+	case op.DropAll:
+		g = newGraph()
+	case op.DropOp == api.Operation_DATA:
+		g.nodes = map[uint64]map[string][]value{}
+	case op.DropOp == api.Operation_ATTR || op.DropAttr != "":
+		pred := op.DropValue
+		if pred == "" {
+			pred = op.DropAttr
+		}
+		delete(g.preds, pred)
+		for uid, n := range g.nodes {
+			delete(n, pred)
+			if len(n) == 0 {
+				delete(g.nodes, uid)
+			}
+		}
+	case op.DropOp == api.Operation_TYPE:
+		delete(g.types, op.DropValue)
+	default:
+		if err := g.applySchema(op.Schema); err != nil {
+			return nil, err
+		}
+	}
+	s.ts++
+	s.commits = append(s.commits, &commit{ts: s.ts, g: g, keys: map[string]bool{}})
+	return &api.Payload{}, nil
+}
+
+func (s *Server) Query(ctx context.Context, req *api.Request) (*api.Response, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	startTs := req.StartTs
+	if startTs == 0 {
+		s.ts++
+		startTs = s.ts
+	}
+	t := s.txns[startTs]
+	if t == nil && len(req.Mutations) > 0 {
+		t = &txn{startTs: startTs, g: s.snapshot(startTs).clone(), keys: map[string]bool{}}
+		s.txns[startTs] = t
+	}
+	g := s.snapshot(startTs)
+	if t != nil {
+		g = t.g
+	}
+	blocks, err := parseQuery(req.Query, req.Vars)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	e := newEnv(g)
+	res, err := e.run(blocks)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	uids := map[string]string{}
+	for _, mu := range req.Mutations {
+		cond, err := parseCond(mu.Cond)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		ok, err := e.cond(cond)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		if !ok {
+			continue
+		}
+		if err = s.mutate(t, e, mu, uids); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+	resp := &api.Response{Uids: uids, Txn: &api.TxnContext{StartTs: startTs}}
+	if resp.Json, err = json.Marshal(res); err != nil {
+		return nil, err
+	}
+	if t != nil {
+		for k := range t.keys {
+			resp.Txn.Keys = append(resp.Txn.Keys, k)
+		}
+	}
+	if req.CommitNow && t != nil {
+		cts, err := s.commit(t)
+		if err != nil {
+			return nil, err
+		}
+		resp.Txn.CommitTs = cts
+	}
+	return resp, nil
+}
+
+func (s *Server) CommitOrAbort(ctx context.Context, tc *api.TxnContext) (*api.TxnContext, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.txns[tc.StartTs]
+	if !ok {
+		return &api.TxnContext{StartTs: tc.StartTs, Aborted: tc.Aborted}, nil
+	}
+	if tc.Aborted {
+		delete(s.txns, tc.StartTs)
+		return &api.TxnContext{StartTs: tc.StartTs, Aborted: true}, nil
+	}
+	cts, err := s.commit(t)
+	if err != nil {
+		return nil, err
+	}
+	return &api.TxnContext{StartTs: tc.StartTs, CommitTs: cts}, nil
+}
+
+// commit 检查写冲突并将事务重放到最新版本
+func (s *Server) commit(t *txn) (uint64, error) {
+	delete(s.txns, t.startTs)
+	for _, c := range s.commits {
+		if c.ts <= t.startTs {
+			continue
+		}
+		for k := range t.keys {
+			if c.keys[k] {
+				return 0, status.Error(codes.Aborted, "Transaction has been aborted. Please retry")
+			}
+		}
+	}
+	g := s.latest().g.clone()
+	for _, o := range t.ops {
+		if o.del {
+			g.del(o.uid, o.pred, o.lang, o.v)
+		} else {
+			g.set(o.uid, o.pred, *o.v)
+		}
+	}
+	s.ts++
+	s.commits = append(s.commits, &commit{ts: s.ts, g: g, keys: t.keys})
+	return s.ts, nil
+}
@@ -0,0 +1,233 @@
+/**
+ * @Author: daipengyuan
+ * @Description:
+ * @File:  server_test
+ * @Version: 1.0.0
+ * @Date: 2026/10/19 22:00
+ */
+
+package dqltest
+
+import (
+	"errors"
+	"github.com/dgraph-io/dgo/v200/protos/api"
+	"github.com/golang-common/dglib/dql"
+	"strings"
+	"testing"
+)
+
+type Person struct {
+	Uid      string   `json:"uid" db:"uid,string" dtype:"Person"`
+	Name     string   `json:"name" db:"name,string,id" index:"index" token:"exact"`
+	Age      int      `json:"age" db:"age,int" index:"index" token:"int"`
+	Friend   []string `json:"friend" db:"friend,uid" index:"reverse,count,list"`
+	FriendOf []string `json:"friend_of" db:"~friend,uid"`
+}
+
+func setSchema(t *testing.T, c *dql.Client) {
+	preds := []dql.Pred{
+		{Predicate: "name", Type: "string", Index: true, Tokenizer: []string{"exact"}},
+		{Predicate: "age", Type: "int", Index: true, Tokenizer: []string{"int"}},
+		{Predicate: "friend", Type: "uid", Reverse: true, Count: true, List: true},
+	}
+	for _, p := range preds {
+		if err := c.SetPred(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := c.SetType(dql.Type{Name: "Person", Fields: []dql.Field{{Name: "name"}, {Name: "age"}, {Name: "friend"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func add(t *testing.T, c *dql.Client, p Person) string {
+	txn := c.Txn()
+	resp, err := txn.Add(p)
+	txn.CommitOrAbort(err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, uid := range resp.Uids {
+		return uid
+	}
+	return ""
+}
+
+type node struct {
+	Uid string `json:"uid"`
+}
+
+type personView struct {
+	Uid      string `json:"uid"`
+	Name     string `json:"name"`
+	Age      int    `json:"age"`
+	Friend   []node `json:"friend"`
+	FriendOf []node `json:"friend_of"`
+}
+
+func get(t *testing.T, c *dql.Client, name string) []personView {
+	var res struct {
+		Q []personView `json:"q"`
+	}
+	q := `{ q(func: eq(name, "` + name + `")) { uid name age friend { uid } friend_of: ~friend { uid } } }`
+	if err := c.Txn(true).UnmashalQueryStr(q, &res); err != nil {
+		t.Fatal(err)
+	}
+	return res.Q
+}
+
+func TestServer(t *testing.T) {
+	c := NewClient(t)
+	setSchema(t, c)
+
+	a := add(t, c, Person{Name: "alice", Age: 30})
+	if a == "" {
+		t.Fatal("no uid assigned")
+	}
+	// id谓词重复时upsert条件不满足,不会新建节点
+	if uid := add(t, c, Person{Name: "alice", Age: 31}); uid != "" {
+		t.Fatalf("duplicate id created node %s", uid)
+	}
+	b := add(t, c, Person{Name: "bob", Age: 20, Friend: []string{a}})
+
+	ps := get(t, c, "alice")
+	if len(ps) != 1 || ps[0].Age != 30 {
+		t.Fatalf("unexpected alice %+v", ps)
+	}
+	if len(ps[0].FriendOf) != 1 || ps[0].FriendOf[0].Uid != b {
+		t.Fatalf("unexpected reverse edge %+v", ps[0].FriendOf)
+	}
+
+	txn := c.Txn()
+	_, err := txn.Update(Person{Uid: a, Name: "alice", Age: 40})
+	txn.CommitOrAbort(err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ps = get(t, c, "alice"); len(ps) != 1 || ps[0].Age != 40 {
+		t.Fatalf("update not applied %+v", ps)
+	}
+
+	txn = c.Txn()
+	_, err = txn.DelNode(Person{Uid: a})
+	txn.CommitOrAbort(err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ps = get(t, c, "alice"); len(ps) != 0 {
+		t.Fatalf("node not deleted %+v", ps)
+	}
+
+	schema, err := c.Txn(true).GetSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(schema.Types) != 1 || schema.Types[0].Name != "Person" {
+		t.Fatalf("unexpected types %+v", schema.Types)
+	}
+}
+
+func TestServer_Conflict(t *testing.T) {
+	c := NewClient(t)
+	setSchema(t, c)
+	a := add(t, c, Person{Name: "alice", Age: 30})
+
+	t1, t2 := c.Txn(), c.Txn()
+	if _, err := t1.Merge(Person{Uid: a, Name: "alice", Age: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := t2.Merge(Person{Uid: a, Name: "alice", Age: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := t1.Txn.Commit(t1.Ctx()); err != nil {
+		t.Fatal(err)
+	}
+	if err := t2.Txn.Commit(t2.Ctx()); err == nil {
+		t.Fatal("expected conflict")
+	}
+}
+
+func TestServer_AdminToken(t *testing.T) {
+	s := StartServer(t)
+	s.AdminToken = "secret"
+	cfg := s.Config()
+	cfg.AdminToken = "wrong"
+	c, err := dql.NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	err = c.SetPred(dql.Pred{Predicate: "name", Type: "string"})
+	var te *dql.AdminTokenError
+	if !errors.As(err, &te) {
+		t.Fatalf("expected AdminTokenError, got %v", err)
+	}
+	if err = s.Client(t).SetPred(dql.Pred{Predicate: "name", Type: "string"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// S * *只删除节点所属类型中的谓词,无类型节点不受影响
+func TestServer_DelStar(t *testing.T) {
+	c := NewClient(t)
+	setSchema(t, c)
+	txn := c.Txn()
+	str := func(s string) *api.Value { return &api.Value{Val: &api.Value_StrVal{StrVal: s}} }
+	resp, err := txn.Txn.Mutate(txn.Ctx(), &api.Mutation{CommitNow: true, Set: []*api.NQuad{
+		{Subject: "_:a", Predicate: "dgraph.type", ObjectValue: str("Person")},
+		{Subject: "_:a", Predicate: "name", ObjectValue: str("alice")},
+		{Subject: "_:a", Predicate: "nick", ObjectValue: str("ali")},
+		{Subject: "_:b", Predicate: "name", ObjectValue: str("bob")},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	star := &api.Value{Val: &api.Value_DefaultVal{DefaultVal: starAll}}
+	txn = c.Txn()
+	_, err = txn.Txn.Mutate(txn.Ctx(), &api.Mutation{CommitNow: true, Del: []*api.NQuad{
+		{Subject: resp.Uids["a"], Predicate: starAll, ObjectValue: star},
+		{Subject: resp.Uids["b"], Predicate: starAll, ObjectValue: star},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res struct {
+		A []map[string]interface{} `json:"a"`
+		B []map[string]interface{} `json:"b"`
+	}
+	q := `{ a(func: uid(` + resp.Uids["a"] + `)) { name nick dgraph.type } b(func: uid(` + resp.Uids["b"] + `)) { name } }`
+	if err = c.Txn(true).UnmashalQueryStr(q, &res); err != nil {
+		t.Fatal(err)
+	}
+	if len(res.A) != 1 || len(res.A[0]) != 1 || res.A[0]["nick"] != "ali" {
+		t.Fatalf("typed node %+v", res.A)
+	}
+	if len(res.B) != 1 || res.B[0]["name"] != "bob" {
+		t.Fatalf("untyped node %+v", res.B)
+	}
+}
+
+// 没有匹配节点的块中定义的变量为空,从未定义的变量报错
+func TestServer_UndefinedVar(t *testing.T) {
+	c := NewClient(t)
+	setSchema(t, c)
+	add(t, c, Person{Name: "alice"})
+	txn := c.Txn(true)
+	q := `{ var(func: eq(name, "nobody")) { f as friend } q(func: uid(f)) { uid } r(func: eq(name, "alice")) @filter(NOT uid(f)) { uid } }`
+	resp, err := txn.Txn.Query(txn.Ctx(), q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(resp.Json) != `{"q":[],"r":[{"uid":"0x1"}]}` {
+		t.Fatalf("unexpected result %s", resp.Json)
+	}
+	for _, q := range []string{
+		`{ q(func: uid(ownr)) { uid } }`,
+		`{ o as var(func: has(name)) q(func: has(name)) @filter(uid(o, ownr)) { uid } }`,
+	} {
+		if _, err = txn.Txn.Query(txn.Ctx(), q); err == nil || !strings.Contains(err.Error(), "ownr") {
+			t.Fatalf("%s: undefined variable accepted, got %v", q, err)
+		}
+	}
+}
@@ -0,0 +1,438 @@
+/**
+ * @Author: daipengyuan
+ * @Description: 内存三元组存储,按提交版本保存快照
+ * @File:  store
+ * @Version: 1.0.0
+ * @Date: 2026/10/19 19:40
+ */
+
+package dqltest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dgraph-io/dgo/v200/protos/api"
+	"github.com/golang-common/dglib/dql"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const starAll = "_STAR_ALL"
+
+// value 谓词的一个值,uid不为0时为边
+type value struct {
+	uid    uint64
+	val    interface{} // string/int64/float64/bool/time.Time/json.RawMessage(geo)/password
+	tp     string
+	lang   string
+	facets map[string]interface{}
+}
+
+func (v value) equal(o value) bool {
+	if v.uid != 0 || o.uid != 0 {
+		return v.uid == o.uid
+	}
+	if v.lang != o.lang {
+		return false
+	}
+	if t, ok := v.val.(time.Time); ok {
+		ot, ok := o.val.(time.Time)
+		return ok && t.Equal(ot)
+	}
+	if b, ok := v.val.(json.RawMessage); ok {
+		ob, ok := o.val.(json.RawMessage)
+		return ok && string(b) == string(ob)
+	}
+	return v.val == o.val
+}
+
+// graph 某一版本的全部数据与schema
+type graph struct {
+	nodes map[uint64]map[string][]value
+	preds map[string]dql.Pred
+	types map[string]dql.Type
+}
+
+func newGraph() *graph {
+	g := &graph{
+		nodes: map[uint64]map[string][]value{},
+		preds: map[string]dql.Pred{},
+		types: map[string]dql.Type{},
+	}
+	g.preds["dgraph.type"] = dql.Pred{Predicate: "dgraph.type", Type: dql.TypeString, Index: true, Tokenizer: []string{dql.TokenExact}, List: true}
+	return g
+}
+
+func (g *graph) clone() *graph {
+	c := &graph{
+		nodes: make(map[uint64]map[string][]value, len(g.nodes)),
+		preds: make(map[string]dql.Pred, len(g.preds)),
+		types: make(map[string]dql.Type, len(g.types)),
+	}
+	for uid, n := range g.nodes {
+		cn := make(map[string][]value, len(n))
+		for p, vs := range n {
+			cn[p] = append([]value(nil), vs...)
+		}
+		c.nodes[uid] = cn
+	}
+	for k, v := range g.preds {
+		c.preds[k] = v
+	}
+	for k, v := range g.types {
+		c.types[k] = v
+	}
+	return c
+}
+
+// uids 返回所有存在谓词的节点,按uid升序
+func (g *graph) uids() []uint64 {
+	r := make([]uint64, 0, len(g.nodes))
+	for uid := range g.nodes {
+		r = append(r, uid)
+	}
+	sort.Slice(r, func(i, j int) bool { return r[i] < r[j] })
+	return r
+}
+
+func (g *graph) get(uid uint64, pred string) []value {
+	if strings.HasPrefix(pred, "~") {
+		return g.reverse(uid, pred[1:])
+	}
+	n, ok := g.nodes[uid]
+	if !ok {
+		return nil
+	}
+	return n[pred]
+}
+
+// reverse 查找通过pred指向uid的所有节点
+func (g *graph) reverse(uid uint64, pred string) []value {
+	var r []value
+	for _, s := range g.uids() {
+		for _, v := range g.nodes[s][pred] {
+			if v.uid == uid {
+				r = append(r, value{uid: s, facets: v.facets})
+			}
+		}
+	}
+	return r
+}
+
+func (g *graph) set(uid uint64, pred string, v value) {
+	n, ok := g.nodes[uid]
+	if !ok {
+		n = map[string][]value{}
+		g.nodes[uid] = n
+	}
+	p, ok := g.preds[pred]
+	if !ok {
+		p = dql.Pred{Predicate: pred, Type: v.tp}
+		if v.uid != 0 {
+			p.Type, p.List = dql.TypeUid, true
+		}
+		g.preds[pred] = p
+	}
+	if p.List {
+		for i, o := range n[pred] {
+			if o.equal(v) {
+				n[pred][i] = v
+				return
+			}
+		}
+		n[pred] = append(n[pred], v)
+		return
+	}
+	// 非列表谓词按语言替换
+	var kept []value
+	for _, o := range n[pred] {
+		if o.lang != v.lang {
+			kept = append(kept, o)
+		}
+	}
+	n[pred] = append(kept, v)
+}
+
+// del 删除值,v为nil时删除谓词的全部值
+func (g *graph) del(uid uint64, pred, lang string, v *value) {
+	n, ok := g.nodes[uid]
+	if !ok {
+		return
+	}
+	if pred == starAll {
+		// 与dgraph一致,只删除节点所属类型中的谓词与dgraph.type,其他谓词保留
+		for _, p := range g.typePreds(uid) {
+			delete(n, p)
+		}
+		delete(n, "dgraph.type")
+		if len(n) == 0 {
+			delete(g.nodes, uid)
+		}
+		return
+	}
+	var kept []value
+	for _, o := range n[pred] {
+		if v == nil && (lang == "" || o.lang == lang) {
+			continue
+		}
+		if v != nil && o.equal(*v) {
+			continue
+		}
+		kept = append(kept, o)
+	}
+	if len(kept) == 0 {
+		delete(n, pred)
+	} else {
+		n[pred] = kept
+	}
+	if len(n) == 0 {
+		delete(g.nodes, uid)
+	}
+}
+
+// types 节点的dgraph.type
+func (g *graph) nodeTypes(uid uint64) []string {
+	var r []string
+	for _, v := range g.get(uid, "dgraph.type") {
+		if s, ok := v.val.(string); ok {
+			r = append(r, s)
+		}
+	}
+	return r
+}
+
+// typePreds 节点所属类型在schema中定义的谓词
+func (g *graph) typePreds(uid uint64) []string {
+	var r []string
+	for _, name := range g.nodeTypes(uid) {
+		for _, f := range g.types[name].Fields {
+			r = append(r, f.Name)
+		}
+	}
+	return r
+}
+
+// convert 将api.Value转换为存储值,DefaultVal按schema类型解析
+func (g *graph) convert(pred string, av *api.Value) (value, error) {
+	var v value
+	switch x := av.Val.(type) {
+	case *api.Value_StrVal:
+		v.val, v.tp = x.StrVal, dql.TypeString
+	case *api.Value_DefaultVal:
+		v.val, v.tp = x.DefaultVal, dql.TypeDefault
+		if p, ok := g.preds[pred]; ok && p.Type != dql.TypeDefault {
+			return parseScalar(p.Type, x.DefaultVal)
+		}
+	case *api.Value_IntVal:
+		v.val, v.tp = x.IntVal, dql.TypeInt
+	case *api.Value_DoubleVal:
+		v.val, v.tp = x.DoubleVal, dql.TypeFloat
+	case *api.Value_BoolVal:
+		v.val, v.tp = x.BoolVal, dql.TypeBool
+	case *api.Value_DatetimeVal:
+		var t time.Time
+		if err := t.UnmarshalBinary(x.DatetimeVal); err != nil {
+			return v, err
+		}
+		v.val, v.tp = t, dql.TypeDateTime
+	case *api.Value_DateVal:
+		var t time.Time
+		if err := t.UnmarshalBinary(x.DateVal); err != nil {
+			return v, err
+		}
+		v.val, v.tp = t, dql.TypeDateTime
+	case *api.Value_GeoVal:
+		v.val, v.tp = json.RawMessage(x.GeoVal), dql.TypeGeo
+	case *api.Value_PasswordVal:
+		v.val, v.tp = x.PasswordVal, dql.TypePassword
+	case *api.Value_BytesVal:
+		v.val, v.tp = string(x.BytesVal), dql.TypeString
+	default:
+		return v, errors.New(fmt.Sprintf("unsupported value %v", av))
+	}
+	if p, ok := g.preds[pred]; ok && p.Type != v.tp && p.Type != dql.TypeDefault && v.tp != dql.TypeDefault {
+		// 与schema类型不一致时尝试转换,和dgraph一样拒绝无法转换的值
+		cv, err := parseScalar(p.Type, fmt.Sprintf("%v", v.val))
+		if err != nil {
+			return v, errors.New(fmt.Sprintf("value for predicate %s must be %s", pred, p.Type))
+		}
+		return cv, nil
+	}
+	return v, nil
+}
+
+// parseScalar 将文本解析为tp类型的值
+func parseScalar(tp, s string) (value, error) {
+	v := value{tp: tp}
+	switch tp {
+	case dql.TypeInt:
+		i, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return v, err
+		}
+		v.val = i
+	case dql.TypeFloat:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return v, err
+		}
+		v.val = f
+	case dql.TypeBool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return v, err
+		}
+		v.val = b
+	case dql.TypeDateTime:
+		t, err := parseTime(s)
+		if err != nil {
+			return v, err
+		}
+		v.val = t
+	case dql.TypeGeo:
+		v.val = json.RawMessage(s)
+	default:
+		v.val = s
+	}
+	return v, nil
+}
+
+func parseTime(s string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02", "2006-01", "2006"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.New("invalid datetime " + s)
+}
+
+// facetsOf 解析nquad上的面,本库以文本形式写入,同时兼容dgraph的二进制编码
+func facetsOf(fs []*api.Facet) map[string]interface{} {
+	if len(fs) == 0 {
+		return nil
+	}
+	r := map[string]interface{}{}
+	for _, f := range fs {
+		s := string(f.Value)
+		switch f.ValType {
+		case api.Facet_INT:
+			if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+				r[f.Key] = i
+			} else if len(f.Value) == 8 {
+				var i int64
+				for k := 7; k >= 0; k-- {
+					i = i<<8 | int64(f.Value[k])
+				}
+				r[f.Key] = i
+			}
+		case api.Facet_FLOAT:
+			fv, _ := strconv.ParseFloat(s, 64)
+			r[f.Key] = fv
+		case api.Facet_BOOL:
+			r[f.Key] = s == "true" || (len(f.Value) == 1 && f.Value[0] == 1)
+		case api.Facet_DATETIME:
+			var t time.Time
+			if err := t.UnmarshalBinary(f.Value); err == nil {
+				r[f.Key] = t
+			} else if t, err = parseTime(s); err == nil {
+				r[f.Key] = t
+			}
+		default:
+			r[f.Key] = s
+		}
+	}
+	return r
+}
+
+// jsonValue 值在查询结果中的表示
+func jsonValue(v value) interface{} {
+	if t, ok := v.val.(time.Time); ok {
+		return t.Format(time.RFC3339Nano)
+	}
+	return v.val
+}
+
+// applySchema 解析并应用Alter中的schema文本
+func (g *graph) applySchema(s string) error {
+	for len(strings.TrimSpace(s)) > 0 {
+		s = strings.TrimSpace(s)
+		if strings.HasPrefix(s, "type ") || strings.HasPrefix(s, "type\t") {
+			end := strings.Index(s, "}")
+			if end < 0 {
+				return errors.New("unterminated type definition")
+			}
+			tp, err := dql.UnmarshalTypeString(s[:end+1])
+			if err != nil {
+				return err
+			}
+			tp.Name = strings.TrimSpace(tp.Name)
+			for i, f := range tp.Fields {
+				f.Name = strings.Trim(f.Name, "<> \t")
+				tp.Fields[i] = f
+			}
+			g.types[tp.Name] = *tp
+			s = s[end+1:]
+			continue
+		}
+		end := strings.Index(s, " .")
+		if end < 0 {
+			end = strings.Index(s, ".\n")
+			if end < 0 && strings.HasSuffix(s, ".") {
+				end = len(s) - 1
+			}
+			if end < 0 {
+				return errors.New("predicate definition must end with '.'")
+			}
+		}
+		p, err := parsePred(s[:end])
+		if err != nil {
+			return err
+		}
+		g.preds[p.Predicate] = p
+		s = strings.TrimPrefix(s[end:], " ")
+		s = strings.TrimPrefix(s, ".")
+	}
+	return nil
+}
+
+// parsePred 解析 name: [type] @index(a,b) @reverse ...
+func parsePred(s string) (dql.Pred, error) {
+	var p dql.Pred
+	i := strings.Index(s, ":")
+	if i < 0 {
+		return p, errors.New("invalid predicate definition " + s)
+	}
+	p.Predicate = strings.Trim(strings.TrimSpace(s[:i]), "<>")
+	rest := strings.Fields(s[i+1:])
+	if len(rest) == 0 {
+		return p, errors.New("predicate type missing " + s)
+	}
+	tp := rest[0]
+	if strings.HasPrefix(tp, "[") {
+		p.List = true
+		tp = strings.Trim(tp, "[]")
+	}
+	if _, ok := dql.TypeAttrMap[tp]; !ok {
+		return p, errors.New("unsupported predicate type " + tp)
+	}
+	p.Type = tp
+	for _, d := range rest[1:] {
+		switch {
+		case strings.HasPrefix(d, "@index("):
+			p.Index = true
+			p.Tokenizer = strings.Split(strings.TrimSuffix(strings.TrimPrefix(d, "@index("), ")"), ",")
+		case d == "@reverse":
+			p.Reverse = true
+		case d == "@count":
+			p.Count = true
+		case d == "@upsert":
+			p.Upsert = true
+		case d == "@lang":
+			p.Lang = true
+		}
+	}
+	return p, nil
+}
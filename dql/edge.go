@@ -0,0 +1,128 @@
+/**
+ * @Author: daipengyuan
+ * @Description: 单条关系的增加、删除、替换与移动
+ * @File:  edge
+ * @Version: 1.0.0
+ * @Date: 2026/10/20 04:00
+ */
+
+package dql
+
+import (
+	"errors"
+	"fmt"
+	"github.com/dgraph-io/dgo/v200/protos/api"
+	"strings"
+)
+
+// edgePred 检查谓词在schema中为uid类型,反向谓词(~pred)要求正向谓词开启reverse
+// 返回正向谓词名以及是否为反向谓词
+func (d *Txn) edgePred(pred string) (string, bool, error) {
+	name := strings.TrimPrefix(pred, "~")
+	reversed := name != pred
+	if name == "" {
+		return "", false, errors.New("predicate is empty")
+	}
+	p, err := d.FindPred(name)
+	if err != nil {
+		return "", false, errors.New(fmt.Sprintf("find predicate %s in schema: %s", name, err.Error()))
+	}
+	if p.Type != TypeUid {
+		return "", false, errors.New("predicate " + name + " is not uid type")
+	}
+	if reversed && !p.Reverse {
+		return "", false, errors.New("predicate " + name + " has no reverse index")
+	}
+	return name, reversed, nil
+}
+
+// edge 生成from通过pred指向to的nquad,反向谓词时交换主语与宾语
+func edge(from, pred, to string, reversed bool, facets []*Facet) (*api.NQuad, error) {
+	if from == "" || to == "" {
+		return nil, errors.New("edge endpoint must not be empty")
+	}
+	if reversed {
+		from, to = to, from
+	}
+	nq := &api.NQuad{Subject: from, Predicate: pred, ObjectId: to}
+	for _, fc := range facets {
+		if err := fc.Combine(nq); err != nil {
+			return nil, err
+		}
+	}
+	return nq, nil
+}
+
+// Link 增加from通过pred指向to的边,facets设置在边上(忽略其Seq与PredWithLang)
+// pred为~name时增加to通过name指向from的边
+func (d *Txn) Link(from, pred, to string, facets ...*Facet) (*api.Response, error) {
+	name, reversed, err := d.edgePred(pred)
+	if err != nil {
+		return nil, err
+	}
+	nq, err := edge(from, name, to, reversed, facets)
+	if err != nil {
+		return nil, err
+	}
+	return d.do(&api.Request{Mutations: []*api.Mutation{{Set: []*api.NQuad{nq}}}})
+}
+
+// Unlink 删除from通过pred指向to的边
+func (d *Txn) Unlink(from, pred, to string) (*api.Response, error) {
+	name, reversed, err := d.edgePred(pred)
+	if err != nil {
+		return nil, err
+	}
+	nq, err := edge(from, name, to, reversed, nil)
+	if err != nil {
+		return nil, err
+	}
+	return d.do(&api.Request{Mutations: []*api.Mutation{{Del: []*api.NQuad{nq}}}})
+}
+
+// ReplaceEdges 删除from在pred上的所有边,再增加指向to的边,to为空时只删除
+func (d *Txn) ReplaceEdges(from, pred string, to []string, facets ...*Facet) (*api.Response, error) {
+	name, reversed, err := d.edgePred(pred)
+	if err != nil {
+		return nil, err
+	}
+	if from == "" {
+		return nil, errors.New("edge endpoint must not be empty")
+	}
+	var (
+		q  string
+		mu = &api.Mutation{}
+	)
+	if reversed {
+		// 反向边无法用通配符删除,先查出指向from的节点
+		q = fmt.Sprintf("query{ var(func: uid(%s)) { r as ~%s } }", from, name)
+		mu.Del = append(mu.Del, &api.NQuad{Subject: "uid(r)", Predicate: name, ObjectId: from})
+	} else {
+		mu.Del = append(mu.Del, &api.NQuad{Subject: from, Predicate: name, ObjectId: StarAll, ObjectValue: starNqVal})
+	}
+	for _, t := range to {
+		nq, err := edge(from, name, t, reversed, facets)
+		if err != nil {
+			return nil, err
+		}
+		mu.Set = append(mu.Set, nq)
+	}
+	return d.do(&api.Request{Query: q, Mutations: []*api.Mutation{mu}})
+}
+
+// MoveEdge 将from通过pred指向to的边移动到newFrom,如将条目从一个列表移到另一个列表
+func (d *Txn) MoveEdge(from, pred, to, newFrom string, facets ...*Facet) (*api.Response, error) {
+	name, reversed, err := d.edgePred(pred)
+	if err != nil {
+		return nil, err
+	}
+	del, err := edge(from, name, to, reversed, nil)
+	if err != nil {
+		return nil, err
+	}
+	set, err := edge(newFrom, name, to, reversed, facets)
+	if err != nil {
+		return nil, err
+	}
+	return d.do(&api.Request{Mutations: []*api.Mutation{{Set: []*api.NQuad{set}, Del: []*api.NQuad{del}}}})
+}
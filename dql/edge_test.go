@@ -0,0 +1,132 @@
+/**
+ * @Author: daipengyuan
+ * @Description:
+ * @File:  edge_test
+ * @Version: 1.0.0
+ * @Date: 2026/10/20 04:00
+ */
+
+package dql_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/dgraph-io/dgo/v200/protos/api"
+	"github.com/golang-common/dglib/dql"
+	"github.com/golang-common/dglib/dql/dqltest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestEdge(t *testing.T) {
+	c := dqltest.NewClient(t)
+	for _, p := range []dql.Pred{
+		{Predicate: "name", Type: "string"},
+		{Predicate: "follows", Type: "uid", Reverse: true, List: true},
+		{Predicate: "manager", Type: "uid"},
+	} {
+		if err := c.SetPred(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	uids := map[string]string{}
+	txn := c.Txn()
+	var set []*api.NQuad
+	for _, n := range []string{"a", "b", "c", "d"} {
+		set = append(set, nq("_:"+n, "name", n))
+	}
+	resp, err := txn.Txn.Mutate(txn.Ctx(), &api.Mutation{Set: set, CommitNow: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range resp.Uids {
+		uids[k] = v
+	}
+	write := func(f func(txn *dql.Txn) (*api.Response, error)) {
+		t.Helper()
+		txn := c.Txn()
+		_, err := f(txn)
+		txn.CommitOrAbort(err)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	follows := func(who string) []string {
+		t.Helper()
+		var res struct {
+			Q []struct {
+				Follows []struct {
+					Name string `json:"name"`
+				} `json:"follows"`
+			} `json:"q"`
+		}
+		q := fmt.Sprintf(`{ q(func: uid(%s)) { follows { name } } }`, uids[who])
+		if err := c.Txn(true).UnmashalQueryStr(q, &res); err != nil {
+			t.Fatal(err)
+		}
+		var r []string
+		for _, n := range res.Q {
+			for _, f := range n.Follows {
+				r = append(r, f.Name)
+			}
+		}
+		sort.Strings(r)
+		return r
+	}
+
+	write(func(txn *dql.Txn) (*api.Response, error) {
+		return txn.Link(uids["a"], "follows", uids["b"], &dql.Facet{Key: "since", Value: 2020})
+	})
+	// 反向谓词:c被a关注
+	write(func(txn *dql.Txn) (*api.Response, error) { return txn.Link(uids["c"], "~follows", uids["a"]) })
+	if got := follows("a"); !reflect.DeepEqual(got, []string{"b", "c"}) {
+		t.Fatalf("link: %v", got)
+	}
+	write(func(txn *dql.Txn) (*api.Response, error) { return txn.Unlink(uids["a"], "follows", uids["b"]) })
+	if got := follows("a"); !reflect.DeepEqual(got, []string{"c"}) {
+		t.Fatalf("unlink: %v", got)
+	}
+	write(func(txn *dql.Txn) (*api.Response, error) {
+		return txn.ReplaceEdges(uids["a"], "follows", []string{uids["b"], uids["d"]})
+	})
+	if got := follows("a"); !reflect.DeepEqual(got, []string{"b", "d"}) {
+		t.Fatalf("replace: %v", got)
+	}
+	// d的关注者只保留c
+	write(func(txn *dql.Txn) (*api.Response, error) {
+		return txn.ReplaceEdges(uids["d"], "~follows", []string{uids["c"]})
+	})
+	if got := follows("a"); !reflect.DeepEqual(got, []string{"b"}) {
+		t.Fatalf("replace reverse: %v", got)
+	}
+	write(func(txn *dql.Txn) (*api.Response, error) {
+		return txn.MoveEdge(uids["a"], "follows", uids["b"], uids["c"])
+	})
+	if got := follows("a"); len(got) != 0 {
+		t.Fatalf("move left edge: %v", got)
+	}
+	if got := follows("c"); !reflect.DeepEqual(got, []string{"b", "d"}) {
+		t.Fatalf("move: %v", got)
+	}
+
+	txn = c.Txn()
+	if _, err = txn.Link(uids["a"], "name", uids["b"]); err == nil {
+		t.Fatal("non uid predicate accepted")
+	}
+	if _, err = txn.Link(uids["a"], "~manager", uids["b"]); err == nil {
+		t.Fatal("reverse without index accepted")
+	}
+	if _, err = txn.Link(uids["a"], "missing", uids["b"]); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("unknown predicate: %v", err)
+	}
+	txn.Txn.Discard(txn.Ctx())
+
+	// 查询schema失败时返回原始错误
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err = c.Txn().WithContext(ctx).Link(uids["a"], "follows", uids["b"]); err == nil || strings.Contains(err.Error(), "not found") {
+		t.Fatalf("schema error hidden: %v", err)
+	}
+}
@@ -0,0 +1,228 @@
+/**
+ * @Author: daipengyuan
+ * @Description: 将变更请求渲染为可读的RDF,用于dry-run时审阅
+ * @File:  explain
+ * @Version: 1.0.0
+ * @Date: 2026/10/19 23:30
+ */
+
+package dql
+
+import (
+	"fmt"
+	"github.com/dgraph-io/dgo/v200/protos/api"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Explain 一个请求将要执行的内容
+type Explain struct {
+	Query     string            `json:"query,omitempty"`
+	Mutations []ExplainMutation `json:"mutations"`
+}
+
+// ExplainMutation 单个变更的内容
+// SetPreds 将写入的谓词,WipePreds 将被*清空的谓词,DelNodes 将被整体删除的节点
+type ExplainMutation struct {
+	Cond      string   `json:"cond,omitempty"`
+	Set       string   `json:"set,omitempty"`
+	Del       string   `json:"del,omitempty"`
+	SetPreds  []string `json:"set_preds,omitempty"`
+	WipePreds []string `json:"wipe_preds,omitempty"`
+	DelNodes  []string `json:"del_nodes,omitempty"`
+	SetJson   string   `json:"set_json,omitempty"`
+	DelJson   string   `json:"del_json,omitempty"`
+}
+
+// ExplainRequest 解析请求,不发送到服务端
+func ExplainRequest(req *api.Request) *Explain {
+	r := &Explain{Query: req.Query}
+	for _, mu := range req.Mutations {
+		em := ExplainMutation{Cond: mu.Cond, Set: RDF(mu.Set), Del: RDF(mu.Del), SetJson: string(mu.SetJson), DelJson: string(mu.DeleteJson)}
+		set, wipe, nodes := map[string]bool{}, map[string]bool{}, map[string]bool{}
+		for _, nq := range mu.Set {
+			set[nq.Predicate] = true
+		}
+		for _, nq := range mu.Del {
+			switch {
+			case nq.Predicate == StarAll:
+				nodes[rdfNode(nq.Subject)] = true
+			case nq.ObjectId == StarAll || isStarVal(nq.ObjectValue):
+				wipe[nq.Predicate] = true
+			}
+		}
+		em.SetPreds, em.WipePreds, em.DelNodes = sortedKeys(set), sortedKeys(wipe), sortedKeys(nodes)
+		r.Mutations = append(r.Mutations, em)
+	}
+	return r
+}
+
+func (e *Explain) String() string {
+	var b strings.Builder
+	if e.Query != "" {
+		fmt.Fprintf(&b, "query:\n  %s\n", e.Query)
+	}
+	for i, mu := range e.Mutations {
+		fmt.Fprintf(&b, "mutation %d:\n", i)
+		if mu.Cond != "" {
+			fmt.Fprintf(&b, "  cond: %s\n", mu.Cond)
+		}
+		if len(mu.DelNodes) > 0 {
+			fmt.Fprintf(&b, "  delete nodes: %s\n", strings.Join(mu.DelNodes, ", "))
+		}
+		if len(mu.WipePreds) > 0 {
+			fmt.Fprintf(&b, "  wipe: %s\n", strings.Join(mu.WipePreds, ", "))
+		}
+		if len(mu.SetPreds) > 0 {
+			fmt.Fprintf(&b, "  set: %s\n", strings.Join(mu.SetPreds, ", "))
+		}
+		if mu.Del != "" {
+			fmt.Fprintf(&b, "  delete {\n%s  }\n", indent(mu.Del))
+		}
+		if mu.Set != "" {
+			fmt.Fprintf(&b, "  set {\n%s  }\n", indent(mu.Set))
+		}
+		if mu.DelJson != "" {
+			fmt.Fprintf(&b, "  delete json: %s\n", mu.DelJson)
+		}
+		if mu.SetJson != "" {
+			fmt.Fprintf(&b, "  set json: %s\n", mu.SetJson)
+		}
+	}
+	return b.String()
+}
+
+// RDF 将nquad渲染为RDF文本,每行一个三元组,密码值以******代替
+func RDF(nqs []*api.NQuad) string {
+	var b strings.Builder
+	for _, nq := range nqs {
+		pred := "<" + nq.Predicate + ">"
+		if nq.Predicate == StarAll {
+			pred = "*"
+		}
+		obj := rdfNode(nq.ObjectId)
+		if nq.ObjectId == "" {
+			obj = rdfValue(nq.ObjectValue, nq.Lang)
+		}
+		b.WriteString(rdfNode(nq.Subject) + " " + pred + " " + obj)
+		if len(nq.Facets) > 0 {
+			var fs []string
+			for _, f := range nq.Facets {
+				fs = append(fs, f.Key+"="+rdfFacet(f))
+			}
+			b.WriteString(" (" + strings.Join(fs, ", ") + ")")
+		}
+		b.WriteString(" .\n")
+	}
+	return b.String()
+}
+
+func rdfNode(id string) string {
+	switch {
+	case id == StarAll:
+		return "*"
+	case strings.HasPrefix(id, "_:"), strings.HasPrefix(id, "uid("), strings.HasPrefix(id, "val("):
+		return id
+	}
+	return "<" + id + ">"
+}
+
+func rdfValue(v *api.Value, lang string) string {
+	if v == nil {
+		return `""`
+	}
+	var s, tp string
+	switch val := v.Val.(type) {
+	case *api.Value_DefaultVal:
+		if val.DefaultVal == StarAll {
+			return "*"
+		}
+		s = val.DefaultVal
+	case *api.Value_StrVal:
+		s = val.StrVal
+	case *api.Value_IntVal:
+		s, tp = strconv.FormatInt(val.IntVal, 10), "xs:int"
+	case *api.Value_DoubleVal:
+		s, tp = strconv.FormatFloat(val.DoubleVal, 'f', -1, 64), "xs:float"
+	case *api.Value_BoolVal:
+		s, tp = strconv.FormatBool(val.BoolVal), "xs:boolean"
+	case *api.Value_DatetimeVal:
+		var t time.Time
+		if err := t.UnmarshalBinary(val.DatetimeVal); err != nil {
+			s = string(val.DatetimeVal)
+		} else {
+			s = t.Format(time.RFC3339Nano)
+		}
+		tp = "xs:dateTime"
+	case *api.Value_GeoVal:
+		s, tp = string(val.GeoVal), "geo:geojson"
+	case *api.Value_PasswordVal:
+		// 密码不输出明文
+		s, tp = "******", "xs:password"
+	case *api.Value_BytesVal:
+		s = string(val.BytesVal)
+	case *api.Value_UidVal:
+		return fmt.Sprintf("<0x%x>", val.UidVal)
+	}
+	r := strconv.Quote(s)
+	if lang != "" {
+		r += "@" + lang
+	}
+	if tp != "" {
+		r += "^^<" + tp + ">"
+	}
+	return r
+}
+
+func rdfFacet(f *api.Facet) string {
+	switch f.ValType {
+	case api.Facet_STRING:
+		return strconv.Quote(string(f.Value))
+	case api.Facet_DATETIME:
+		var t time.Time
+		if err := t.UnmarshalBinary(f.Value); err == nil {
+			return t.Format(time.RFC3339Nano)
+		}
+	}
+	return string(f.Value)
+}
+
+func isStarVal(v *api.Value) bool {
+	if v == nil {
+		return false
+	}
+	d, ok := v.Val.(*api.Value_DefaultVal)
+	return ok && d.DefaultVal == StarAll
+}
+
+func sortedKeys(m map[string]bool) []string {
+	var r []string
+	for k := range m {
+		r = append(r, k)
+	}
+	sort.Strings(r)
+	return r
+}
+
+func indent(s string) string {
+	var b strings.Builder
+	for _, l := range strings.Split(strings.TrimSuffix(s, "\n"), "\n") {
+		b.WriteString("    " + l + "\n")
+	}
+	return b.String()
+}
+
+// dryRun 记录请求的解析结果,返回空响应
+func (d *Txn) dryRun(req, sent *api.Request) *api.Response {
+	ex := ExplainRequest(req)
+	// JSON模式下同时记录将要发送的json
+	if sent != req {
+		for i, mu := range sent.Mutations {
+			ex.Mutations[i].SetJson, ex.Mutations[i].DelJson = string(mu.SetJson), string(mu.DeleteJson)
+		}
+	}
+	d.Explains = append(d.Explains, ex)
+	return &api.Response{}
+}
@@ -0,0 +1,30 @@
+/**
+ * @Author: daipengyuan
+ * @Description: 导出给dql_test使用的内部函数
+ * @File:  export_test
+ * @Version: 1.0.0
+ * @Date: 2026/10/20 01:30
+ */
+
+package dql
+
+// ResetOwned 清空RegisterOwned登记的谓词
+func ResetOwned() {
+	ownedMu.Lock()
+	defer ownedMu.Unlock()
+	ownedPreds = map[string]bool{}
+}
+
+// SetExportPageSize 修改ExportRDF每页的节点数量,返回恢复函数
+func SetExportPageSize(n int) func() {
+	old := exportPageSize
+	exportPageSize = n
+	return func() { exportPageSize = old }
+}
+
+// SetIntegrityPageSize 修改CheckIntegrity每页的节点数量,返回恢复函数
+func SetIntegrityPageSize(n int) func() {
+	old := integrityPageSize
+	integrityPageSize = n
+	return func() { integrityPageSize = old }
+}
@@ -0,0 +1,169 @@
+/**
+ * @Author: daipengyuan
+ * @Description: 根据结构体标签生成查询,读取单个节点或某个类型的节点列表
+ * @File:  fetch
+ * @Version: 1.0.0
+ * @Date: 2026/10/20 01:00
+ */
+
+package dql
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Get 按obj的Uid读取节点到obj中,obj必须为结构体指针
+// 节点不存在、类型不符或已被软删除时返回错误
+func (d *Txn) Get(obj interface{}) error {
+	val := reflect.ValueOf(obj)
+	if val.Kind() != reflect.Ptr || val.Elem().Kind() != reflect.Struct {
+		return errors.New("obj must be pointer of struct")
+	}
+	uid, dtype, err := parseUidField(val.Elem())
+	if err != nil {
+		return err
+	}
+	if uid == "" || dtype == "" {
+		return errors.New("obj must have uid value and dtype tag")
+	}
+	filter := fmt.Sprintf("type(%s)", dtype)
+	if sd := SoftDeleteOf(obj); sd != "" {
+		filter += " AND " + notDeleted(sd)
+	}
+	q := fmt.Sprintf("{ q(func: uid(%s)) @filter(%s) { %s } }", uid, filter, selection(val.Elem().Type()))
+	var res []json.RawMessage
+	if err = d.fetch(q, val.Elem().Type(), &res); err != nil {
+		return err
+	}
+	if len(res) == 0 {
+		return errors.New("not found")
+	}
+	return Unmarshal(res[0], obj)
+}
+
+// List 读取objs元素类型的节点列表,objs必须为结构体切片的指针,pager为空时读取全部
+// 已被软删除的节点不会返回
+func (d *Txn) List(objs interface{}, pager *Pager) error {
+	val := reflect.ValueOf(objs)
+	if val.Kind() != reflect.Ptr || val.Elem().Kind() != reflect.Slice {
+		return errors.New("objs must be pointer of slice")
+	}
+	tp := val.Elem().Type().Elem()
+	if tp.Kind() != reflect.Struct {
+		return errors.New("objs must be pointer of struct slice")
+	}
+	f, ok := tp.FieldByName(Uid)
+	if !ok || f.Tag.Get(TagDtype) == "" {
+		return errors.New("obj must have Uid field with dtype tag")
+	}
+	root := fmt.Sprintf("type(%s)", f.Tag.Get(TagDtype))
+	if pager != nil && pager.String() != "" {
+		root += ", " + pager.String()
+	}
+	var filter string
+	if sd := f.Tag.Get(TagSoftDelete); sd != "" {
+		filter = fmt.Sprintf(" @filter(%s)", notDeleted(sd))
+	}
+	q := fmt.Sprintf("{ q(func: %s)%s { %s } }", root, filter, selection(tp))
+	var res []json.RawMessage
+	if err := d.fetch(q, tp, &res); err != nil {
+		return err
+	}
+	bs, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+	return Unmarshal(bs, objs)
+}
+
+// fetch 执行查询,并将uid谓词返回的对象转换为结构体中的uid字符串
+func (d *Txn) fetch(q string, tp reflect.Type, res *[]json.RawMessage) error {
+	var raw struct {
+		Q []map[string]interface{} `json:"q"`
+	}
+	defer d.Cancel()
+	resp, err := d.Txn.Query(d.Ctx(), q)
+	if err != nil {
+		return err
+	}
+	if err = decodeNumber(resp.Json, &raw); err != nil {
+		return err
+	}
+	for _, n := range raw.Q {
+		flattenUids(n, tp)
+		bs, err := json.Marshal(n)
+		if err != nil {
+			return err
+		}
+		*res = append(*res, bs)
+	}
+	return nil
+}
+
+// decodeNumber 解析json,数字保留为json.Number,再次序列化时不丢失大整数的精度
+func decodeNumber(data []byte, v interface{}) error {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	return d.Decode(v)
+}
+
+// flattenUids 将节点中uid谓词返回的对象替换为uid字符串,与tp中uid字段的类型对应
+func flattenUids(n map[string]interface{}, tp reflect.Type) {
+	for i := 0; i < tp.NumField(); i++ {
+		f := tp.Field(i)
+		tags := strings.Split(f.Tag.Get(TagDb), ",")
+		if f.Name == Uid || len(tags) < 2 || tags[1] != TypeUid {
+			continue
+		}
+		name := jsonName(f)
+		var uids []interface{}
+		switch v := n[name].(type) {
+		case []interface{}:
+			for _, o := range v {
+				if m, ok := o.(map[string]interface{}); ok {
+					uids = append(uids, m["uid"])
+				}
+			}
+		case map[string]interface{}:
+			uids = append(uids, v["uid"])
+		default:
+			continue
+		}
+		if f.Type.Kind() == reflect.Slice {
+			n[name] = uids
+		} else if len(uids) > 0 {
+			n[name] = uids[0]
+		}
+	}
+}
+
+// selection 根据db标签生成查询的展示项,以json名为别名
+func selection(tp reflect.Type) string {
+	sel := []string{"uid"}
+	for i := 0; i < tp.NumField(); i++ {
+		f := tp.Field(i)
+		tags := strings.Split(f.Tag.Get(TagDb), ",")
+		if f.Name == Uid || len(tags) < 2 {
+			continue
+		}
+		s := fmt.Sprintf("%s: %s", jsonName(f), tags[0])
+		if tags[1] == TypeUid {
+			s += " { uid }"
+		}
+		sel = append(sel, s)
+	}
+	return strings.Join(sel, " ")
+}
+
+func jsonName(f reflect.StructField) string {
+	name := strings.Split(f.Tag.Get("json"), ",")[0]
+	if name == "" || name == "-" {
+		return f.Name
+	}
+	return name
+}
@@ -0,0 +1,176 @@
+/**
+ * @Author: daipengyuan
+ * @Description: 字段掩码,区分未设置与零值
+ * @File:  fieldmask
+ * @Version: 1.0.0
+ * @Date: 2026/10/20 03:30
+ */
+
+package dql
+
+import (
+	"errors"
+	"github.com/dgraph-io/dgo/v200/protos/api"
+	"reflect"
+	"strings"
+)
+
+// FieldMask Update/Merge只处理掩码中的字段,元素为结构体字段名、json名或谓词名
+//
+//	掩码中的字段即使为零值也会写入,如Merge时设置active=false
+//	掩码中的nil指针字段会删除该谓词
+//	auto:"updated"与auto:"updated_by"字段不受掩码限制
+//
+// 掩码为空时与Update/Merge相同
+type FieldMask []string
+
+// Fields 生成字段掩码
+func Fields(names ...string) FieldMask {
+	return names
+}
+
+// fieldPred db标签中的谓词名,不含语言
+func fieldPred(f reflect.StructField) string {
+	return strings.Split(strings.Split(f.Tag.Get(TagDb), ",")[0], "@")[0]
+}
+
+// matchField 字段名、json名或谓词名与name一致
+func matchField(f reflect.StructField, name string) bool {
+	return name == f.Name || name == jsonName(f) || name == fieldPred(f)
+}
+
+// checkMask 掩码中的每个名称都必须对应一个带db标签的字段
+func (m *mutation) checkMask() error {
+	for _, name := range m.mask {
+		found := false
+		for i := 0; i < m.Val.NumField(); i++ {
+			f := m.Val.Type().Field(i)
+			if f.Name != Uid && f.Tag.Get(TagDb) != "" && matchField(f, name) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return errors.New("field " + name + " in mask not found")
+		}
+	}
+	return nil
+}
+
+// inMask 未设置掩码时返回true
+func (m *mutation) inMask(f reflect.StructField) bool {
+	if m.mask == nil {
+		return true
+	}
+	if auto := f.Tag.Get(TagAuto); auto == AutoUpdated || auto == AutoUpdatedBy {
+		return true
+	}
+	for _, name := range m.mask {
+		if matchField(f, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// masked 字段在掩码中显式列出
+func (m *mutation) masked(f reflect.StructField) bool {
+	return m.mask != nil && m.inMask(f)
+}
+
+func isNilPtr(fv reflect.Value) bool {
+	return fv.Kind() == reflect.Ptr && fv.IsNil()
+}
+
+// withMask 在生成请求前设置掩码
+func withMask(mask FieldMask, mk func(*mutation) (*api.Request, error)) func(*mutation) (*api.Request, error) {
+	return func(m *mutation) (*api.Request, error) {
+		m.mask = mask
+		return mk(m)
+	}
+}
+
+// MakeSetNull 删除掩码中字段对应的谓词,must与id字段不能删除
+func (m *mutation) MakeSetNull() (*api.Request, error) {
+	if m.Subject == "" {
+		return nil, errors.New("make set null failed, subject is nil")
+	}
+	if len(m.mask) == 0 {
+		return nil, errors.New("no field to set null")
+	}
+	if err := m.checkMask(); err != nil {
+		return nil, err
+	}
+	var del []*api.NQuad
+	for i := 0; i < m.Val.NumField(); i++ {
+		f := m.Val.Type().Field(i)
+		if f.Name == Uid || f.Tag.Get(TagDb) == "" || !m.masked(f) || f.Tag.Get(TagAuto) != "" {
+			continue
+		}
+		if err := m.parseTag(f.Tag); err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(m.curName, "~") {
+			return nil, errors.New("cannot set null on reverse predicate " + m.curName)
+		}
+		if m.curMustSet || (m.idSet && m.idName == m.curName) || m.curVersion {
+			return nil, errors.New("cannot set null on " + m.curName)
+		}
+		nq, err := m.delCurPred()
+		if err != nil {
+			return nil, err
+		}
+		del = append(del, nq)
+	}
+	return &api.Request{Mutations: []*api.Mutation{{Del: del}}}, nil
+}
+
+// BuildUpdateFields 生成Txn.UpdateFields发送的请求
+func BuildUpdateFields(obj interface{}, mask FieldMask, facets ...*Facet) (*api.Request, error) {
+	return build(obj, facets, buildEnv{}, withMask(mask, (*mutation).MakeUpd))
+}
+
+// BuildMergeFields 生成Txn.MergeFields发送的请求
+func BuildMergeFields(obj interface{}, mask FieldMask, facets ...*Facet) (*api.Request, error) {
+	return build(obj, facets, buildEnv{}, withMask(mask, (*mutation).MakeMerge))
+}
+
+// BuildSetNull 生成Txn.SetNull发送的请求
+func BuildSetNull(obj interface{}, fields ...string) (*api.Request, error) {
+	return build(obj, nil, buildEnv{}, withMask(fields, (*mutation).MakeSetNull))
+}
+
+// UpdateFields 只更新掩码中的字段,其余谓词保持不变
+func (d *Txn) UpdateFields(obj interface{}, mask FieldMask, facets ...*Facet) (*api.Response, error) {
+	req, err := build(obj, facets, d.env(), withMask(mask, (*mutation).MakeUpd))
+	if err != nil {
+		return nil, err
+	}
+	resp, err := d.do(req)
+	if err != nil || d.DryRun {
+		return resp, err
+	}
+	return resp, checkVersion(obj, req, resp)
+}
+
+// MergeFields 写入掩码中的字段,零值同样写入
+func (d *Txn) MergeFields(obj interface{}, mask FieldMask, facets ...*Facet) (*api.Response, error) {
+	req, err := build(obj, facets, d.env(), withMask(mask, (*mutation).MakeMerge))
+	if err != nil {
+		return nil, err
+	}
+	resp, err := d.do(req)
+	if err != nil || d.DryRun {
+		return resp, err
+	}
+	return resp, checkVersion(obj, req, resp)
+}
+
+// SetNull 删除obj节点上fields对应的谓词,fields为字段名、json名或谓词名
+func (d *Txn) SetNull(obj interface{}, fields ...string) (*api.Response, error) {
+	req, err := BuildSetNull(obj, fields...)
+	if err != nil {
+		return nil, err
+	}
+	return d.do(req)
+}
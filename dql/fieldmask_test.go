@@ -0,0 +1,104 @@
+/**
+ * @Author: daipengyuan
+ * @Description:
+ * @File:  fieldmask_test
+ * @Version: 1.0.0
+ * @Date: 2026/10/20 03:30
+ */
+
+package dql_test
+
+import (
+	"github.com/golang-common/dglib/dql"
+	"github.com/golang-common/dglib/dql/dqltest"
+	"testing"
+)
+
+type subscriber struct {
+	Uid    string  `json:"uid" db:"uid,string" dtype:"Subscriber"`
+	Name   string  `json:"name" db:"name,string" validate:"required"`
+	Age    int     `json:"age" db:"age,int"`
+	Active bool    `json:"active" db:"active,bool"`
+	Nick   *string `json:"nick" db:"nick,string"`
+}
+
+func TestFieldMask(t *testing.T) {
+	c := dqltest.NewClient(t)
+	nick := "n"
+	txn := c.Txn()
+	resp, err := txn.Add(subscriber{Name: "a", Age: 30, Active: true, Nick: &nick})
+	txn.CommitOrAbort(err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var uid string
+	for _, u := range resp.Uids {
+		uid = u
+	}
+	write := func(f func(txn *dql.Txn) error) {
+		t.Helper()
+		txn := c.Txn()
+		err := f(txn)
+		txn.CommitOrAbort(err)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	get := func() subscriber {
+		t.Helper()
+		m := subscriber{Uid: uid}
+		if err := c.Txn(true).Get(&m); err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+
+	// 掩码中的零值同样写入,其余字段不变
+	write(func(txn *dql.Txn) error {
+		_, err := txn.MergeFields(subscriber{Uid: uid}, dql.Fields("Active", "age"))
+		return err
+	})
+	if m := get(); m.Active || m.Age != 0 || m.Name != "a" || m.Nick == nil {
+		t.Fatalf("merge with mask: %+v", m)
+	}
+
+	// 不带掩码的Update中nil指针视为未设置
+	write(func(txn *dql.Txn) error {
+		_, err := txn.Update(subscriber{Uid: uid, Name: "b", Age: 5})
+		return err
+	})
+	if m := get(); m.Name != "b" || m.Age != 5 || m.Nick == nil || *m.Nick != "n" {
+		t.Fatalf("update kept nil pointer: %+v", m)
+	}
+
+	// 掩码外的required字段不校验
+	write(func(txn *dql.Txn) error {
+		_, err := txn.UpdateFields(subscriber{Uid: uid, Age: 7}, dql.Fields("age"))
+		return err
+	})
+	if m := get(); m.Name != "b" || m.Age != 7 {
+		t.Fatalf("update with mask: %+v", m)
+	}
+
+	write(func(txn *dql.Txn) error {
+		_, err := txn.SetNull(subscriber{Uid: uid}, "nick")
+		return err
+	})
+	if m := get(); m.Nick != nil {
+		t.Fatalf("nick not removed: %+v", m)
+	}
+
+	if _, err = dql.BuildUpdateFields(subscriber{Uid: uid}, dql.Fields("unknown")); err == nil {
+		t.Fatal("unknown field in mask accepted")
+	}
+	if _, err = dql.BuildSetNull(subscriber{Uid: uid}); err == nil {
+		t.Fatal("set null without fields accepted")
+	}
+	req, err := dql.BuildMergeFields(subscriber{Uid: uid}, dql.Fields("nick"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mu := req.Mutations[0]; len(mu.Set) != 0 || len(mu.Del) != 1 || mu.Del[0].Predicate != "nick" {
+		t.Fatalf("masked nil pointer not deleted: %v", mu)
+	}
+}
@@ -0,0 +1,336 @@
+/**
+ * @Author: daipengyuan
+ * @Description: 节点健康检查与故障切换,pool作为api.DgraphClient交给dgo使用
+ * @File:  health
+ * @Version: 1.0.0
+ * @Date: 2026/10/19 10:12
+ */
+
+package dql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/dgraph-io/dgo/v200/protos/api"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	defaultHealthInterval = 10 * time.Second
+	defaultProbeTimeout   = 3 * time.Second
+)
+
+// TargetHealth 单个节点的健康状态
+type TargetHealth struct {
+	Target    string        `json:"target"`
+	Healthy   bool          `json:"healthy"`
+	Read      bool          `json:"read,omitempty"`    // 是否为优先处理只读查询的节点
+	Version   string        `json:"version,omitempty"` // CheckVersion返回的版本
+	Latency   time.Duration `json:"latency"`           // 最近一次探测耗时
+	LastCheck time.Time     `json:"last_check"`
+	Error     string        `json:"error,omitempty"`
+}
+
+// endpoint 一个alpha节点的连接及其状态
+type endpoint struct {
+	target  string
+	http    string
+	conn    *grpc.ClientConn
+	client  api.DgraphClient
+	healthy bool
+	read    bool
+	state   TargetHealth
+}
+
+// pool 实现api.DgraphClient,每次调用随机选取一个健康节点,只读查询优先选取ReadTargets中的节点
+// 节点在调用返回Unavailable或探测失败时被摘除,探测恢复后重新加入
+type pool struct {
+	mu       sync.RWMutex
+	eps      []*endpoint
+	https    []string
+	reads    map[string]bool
+	http     *http.Client
+	scheme   string
+	interval time.Duration
+	timeout  time.Duration
+	stop     chan struct{}
+	done     chan struct{}
+	started  bool
+	closed   bool
+}
+
+func newPool(config Config) (*pool, error) {
+	p := &pool{
+		https:    config.HttpTargets,
+		reads:    map[string]bool{},
+		http:     &http.Client{},
+		scheme:   "http",
+		interval: config.HealthInterval,
+		timeout:  config.DialTimeout,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if p.interval == 0 {
+		p.interval = defaultHealthInterval
+	}
+	for _, t := range config.ReadTargets {
+		found := false
+		for _, target := range config.Targets {
+			found = found || target == t
+		}
+		if !found {
+			return nil, errors.New("read target " + t + " is not in targets")
+		}
+		p.reads[t] = true
+	}
+	if p.timeout <= 0 {
+		p.timeout = defaultProbeTimeout
+	}
+	if config.Tls != (Tls{}) && len(config.HttpTargets) > 0 {
+		conf, err := config.Tls.Config()
+		if err != nil {
+			return nil, err
+		}
+		p.http.Transport = &http.Transport{TLSClientConfig: conf}
+		p.scheme = "https"
+	}
+	return p, nil
+}
+
+// add 按Targets顺序加入节点,初始视为健康
+func (p *pool) add(target string, conn *grpc.ClientConn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ep := &endpoint{
+		target:  target,
+		conn:    conn,
+		client:  api.NewDgraphClient(conn),
+		healthy: true,
+		read:    p.reads[target],
+		state:   TargetHealth{Target: target, Healthy: true, Read: p.reads[target]},
+	}
+	if len(p.eps) < len(p.https) {
+		ep.http = p.https[len(p.eps)]
+	}
+	p.eps = append(p.eps, ep)
+}
+
+// start 启动定时健康检查
+func (p *pool) start() {
+	if p.interval < 0 {
+		return
+	}
+	p.started = true
+	go func() {
+		defer close(p.done)
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.stop:
+				return
+			case <-ticker.C:
+				p.probeAll(context.Background())
+			}
+		}
+	}()
+}
+
+func (p *pool) close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	p.mu.Unlock()
+	close(p.stop)
+	if p.started {
+		<-p.done
+	}
+	var errs []string
+	for _, ep := range p.eps {
+		if err := ep.conn.Close(); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", ep.target, err))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(fmt.Sprintf("close connections failed, %v", errs))
+	}
+	return nil
+}
+
+// probeAll 并发探测所有节点,更新状态并返回结果
+func (p *pool) probeAll(ctx context.Context) []TargetHealth {
+	var (
+		wg  sync.WaitGroup
+		res = make([]TargetHealth, len(p.eps))
+	)
+	for i, ep := range p.eps {
+		wg.Add(1)
+		go func(i int, ep *endpoint) {
+			defer wg.Done()
+			res[i] = p.probe(ctx, ep)
+		}(i, ep)
+	}
+	wg.Wait()
+	return res
+}
+
+// probe 通过grpc CheckVersion探测节点,设置了http地址时同时请求/health
+func (p *pool) probe(ctx context.Context, ep *endpoint) TargetHealth {
+	var (
+		st  = TargetHealth{Target: ep.target, Read: ep.read, LastCheck: time.Now()}
+		err error
+	)
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+	ver, err := ep.client.CheckVersion(ctx, &api.Check{})
+	if err == nil {
+		st.Version = ver.Tag
+		if ep.http != "" {
+			err = p.probeHttp(ctx, ep.http)
+		}
+	}
+	st.Latency = time.Since(st.LastCheck)
+	st.Healthy = err == nil
+	if err != nil {
+		st.Error = err.Error()
+	}
+	p.mu.Lock()
+	ep.healthy = st.Healthy
+	ep.state = st
+	p.mu.Unlock()
+	return st
+}
+
+func (p *pool) probeHttp(ctx context.Context, addr string) error {
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s://%s/health", p.scheme, addr), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.http.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.New(fmt.Sprintf("health endpoint returned %s", resp.Status))
+	}
+	return nil
+}
+
+// pick 从未尝试过的节点中随机选择一个,优先选择健康节点,read为真时优先选择健康的只读节点
+// 所有节点都不健康时仍然返回节点,使调用方得到服务端的真实错误
+func (p *pool) pick(tried map[*endpoint]bool, read bool) *endpoint {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	var healthy, reads, other []*endpoint
+	for _, ep := range p.eps {
+		if tried[ep] {
+			continue
+		}
+		if ep.healthy {
+			healthy = append(healthy, ep)
+			if ep.read {
+				reads = append(reads, ep)
+			}
+		} else {
+			other = append(other, ep)
+		}
+	}
+	if read && len(reads) > 0 {
+		return reads[rand.Intn(len(reads))]
+	}
+	if len(healthy) > 0 {
+		return healthy[rand.Intn(len(healthy))]
+	}
+	if len(tried) == 0 && len(other) > 0 {
+		return other[rand.Intn(len(other))]
+	}
+	return nil
+}
+
+func (p *pool) markDown(ep *endpoint, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ep.healthy = false
+	ep.state.Healthy = false
+	ep.state.Error = err.Error()
+}
+
+// do 在选中的节点上执行fn,节点不可用时将其摘除
+// retry为真时换一个健康节点重试,只用于重复执行无副作用的请求;read为真时优先使用只读节点
+func (p *pool) do(ctx context.Context, retry, read bool, fn func(c api.DgraphClient) error) error {
+	var tried = make(map[*endpoint]bool)
+	for {
+		ep := p.pick(tried, read)
+		if ep == nil {
+			return errors.New("no available target")
+		}
+		tried[ep] = true
+		err := fn(ep.client)
+		if status.Code(err) != codes.Unavailable {
+			return err
+		}
+		p.markDown(ep, err)
+		if !retry || ctx.Err() != nil || p.pick(tried, read) == nil {
+			return err
+		}
+	}
+}
+
+func (p *pool) Login(ctx context.Context, in *api.LoginRequest, opts ...grpc.CallOption) (*api.Response, error) {
+	var resp *api.Response
+	err := p.do(ctx, true, false, func(c api.DgraphClient) (err error) {
+		resp, err = c.Login(ctx, in, opts...)
+		return err
+	})
+	return resp, err
+}
+
+// Query 不带变更的查询可以重试,带变更时不重试避免重复写入
+// 只读事务中的查询优先发送到只读节点
+func (p *pool) Query(ctx context.Context, in *api.Request, opts ...grpc.CallOption) (*api.Response, error) {
+	var resp *api.Response
+	err := p.do(ctx, len(in.Mutations) == 0, in.ReadOnly && len(in.Mutations) == 0, func(c api.DgraphClient) (err error) {
+		resp, err = c.Query(ctx, in, opts...)
+		return err
+	})
+	return resp, err
+}
+
+// Alter 不重试,超时的alter可能已到达leader并执行,重发到其他节点会重复修改schema
+func (p *pool) Alter(ctx context.Context, in *api.Operation, opts ...grpc.CallOption) (*api.Payload, error) {
+	var resp *api.Payload
+	err := p.do(ctx, false, false, func(c api.DgraphClient) (err error) {
+		resp, err = c.Alter(ctx, in, opts...)
+		return err
+	})
+	return resp, err
+}
+
+func (p *pool) CommitOrAbort(ctx context.Context, in *api.TxnContext, opts ...grpc.CallOption) (*api.TxnContext, error) {
+	var resp *api.TxnContext
+	err := p.do(ctx, false, false, func(c api.DgraphClient) (err error) {
+		resp, err = c.CommitOrAbort(ctx, in, opts...)
+		return err
+	})
+	return resp, err
+}
+
+func (p *pool) CheckVersion(ctx context.Context, in *api.Check, opts ...grpc.CallOption) (*api.Version, error) {
+	var resp *api.Version
+	err := p.do(ctx, true, false, func(c api.DgraphClient) (err error) {
+		resp, err = c.CheckVersion(ctx, in, opts...)
+		return err
+	})
+	return resp, err
+}
@@ -0,0 +1,118 @@
+/**
+ * @Author: daipengyuan
+ * @Description:
+ * @File:  health_test
+ * @Version: 1.0.0
+ * @Date: 2026/10/19 11:30
+ */
+
+package dql
+
+import (
+	"context"
+	"github.com/dgraph-io/dgo/v200/protos/api"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+// downClient 模拟一个已停止的alpha
+type downClient struct {
+	api.DgraphClient
+	calls int
+}
+
+func (c *downClient) Query(ctx context.Context, in *api.Request, opts ...grpc.CallOption) (*api.Response, error) {
+	c.calls++
+	return nil, status.Error(codes.Unavailable, "connection refused")
+}
+
+func (c *downClient) Alter(ctx context.Context, in *api.Operation, opts ...grpc.CallOption) (*api.Payload, error) {
+	c.calls++
+	return nil, status.Error(codes.Unavailable, "deadline exceeded after reaching leader")
+}
+
+type upClient struct {
+	api.DgraphClient
+	calls int
+}
+
+func (c *upClient) Query(ctx context.Context, in *api.Request, opts ...grpc.CallOption) (*api.Response, error) {
+	c.calls++
+	return &api.Response{Json: []byte(`{}`)}, nil
+}
+
+func (c *upClient) Alter(ctx context.Context, in *api.Operation, opts ...grpc.CallOption) (*api.Payload, error) {
+	c.calls++
+	return &api.Payload{}, nil
+}
+
+func TestPoolFailover(t *testing.T) {
+	var (
+		down = &downClient{}
+		up   = &upClient{}
+		p    = &pool{eps: []*endpoint{
+			{target: "down", client: down, healthy: true},
+			{target: "up", client: up, healthy: true},
+		}}
+	)
+	for i := 0; i < 10; i++ {
+		_, err := p.Query(context.Background(), &api.Request{Query: "{}"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if down.calls > 1 {
+		t.Fatalf("unhealthy target called %d times", down.calls)
+	}
+	if p.eps[0].healthy {
+		t.Fatal("target returning Unavailable should be marked unhealthy")
+	}
+	// 带变更的请求不重试
+	p.eps[0].healthy, p.eps[1].healthy = true, false
+	_, err := p.Query(context.Background(), &api.Request{Mutations: []*api.Mutation{{}}})
+	if status.Code(err) != codes.Unavailable {
+		t.Fatalf("mutation should not be retried, got %v", err)
+	}
+	// alter不重试,发送到不可用节点时直接返回错误
+	p.eps[1].healthy = true
+	for i := 0; i < 20; i++ {
+		p.eps[0].healthy = true
+		downCalls, upCalls := down.calls, up.calls
+		_, err = p.Alter(context.Background(), &api.Operation{})
+		if down.calls > downCalls && (up.calls > upCalls || status.Code(err) != codes.Unavailable) {
+			t.Fatalf("alter should not be retried, got %v", err)
+		}
+	}
+}
+
+func TestPoolReadPreference(t *testing.T) {
+	var (
+		write = &upClient{}
+		read  = &upClient{}
+		p     = &pool{eps: []*endpoint{
+			{target: "write", client: write, healthy: true},
+			{target: "read", client: read, healthy: true, read: true},
+		}}
+	)
+	for i := 0; i < 10; i++ {
+		if _, err := p.Query(context.Background(), &api.Request{Query: "{}", ReadOnly: true}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if read.calls != 10 || write.calls != 0 {
+		t.Fatalf("read-only queries should go to read target, got read %d write %d", read.calls, write.calls)
+	}
+	// 只读节点不健康时回退到其他节点
+	p.eps[1].healthy = false
+	if _, err := p.Query(context.Background(), &api.Request{Query: "{}", ReadOnly: true}); err != nil {
+		t.Fatal(err)
+	}
+	if write.calls != 1 {
+		t.Fatal("read-only query should fall back to healthy target")
+	}
+	if _, err := newPool(Config{Targets: []string{"a:9080"}, ReadTargets: []string{"b:9080"}}); err == nil {
+		t.Fatal("read target not in targets should fail")
+	}
+}
@@ -0,0 +1,324 @@
+/**
+ * @Author: daipengyuan
+ * @Description: 数据完整性检查,查找无类型节点、悬空边、must缺失与id重复
+ * @File:  integrity
+ * @Version: 1.0.0
+ * @Date: 2026/10/20 02:00
+ */
+
+package dql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dgraph-io/dgo/v200/protos/api"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// IntegrityOptions 完整性检查选项
+// Types 为注册的结构体,用于检查must与id标签;Repair 为真时生成修复用的变更
+type IntegrityOptions struct {
+	Types  []interface{}
+	Repair bool
+}
+
+// DanglingEdge 指向没有任何谓词的节点的边
+type DanglingEdge struct {
+	Subject   string `json:"subject"`
+	Predicate string `json:"predicate"`
+	Object    string `json:"object"`
+}
+
+// MissingField 缺少must谓词的节点
+type MissingField struct {
+	Uid       string `json:"uid"`
+	Type      string `json:"type"`
+	Predicate string `json:"predicate"`
+}
+
+// DuplicateId 同一类型中id谓词取值相同的节点
+type DuplicateId struct {
+	Type      string   `json:"type"`
+	Predicate string   `json:"predicate"`
+	Value     string   `json:"value"`
+	Uids      []string `json:"uids"`
+}
+
+// IntegrityReport 完整性检查结果
+// Repair 只包含删除悬空边与无类型节点的变更,must缺失与id重复需要人工处理
+type IntegrityReport struct {
+	Untyped     []string        `json:"untyped,omitempty"`
+	Dangling    []DanglingEdge  `json:"dangling,omitempty"`
+	MissingMust []MissingField  `json:"missing_must,omitempty"`
+	Duplicates  []DuplicateId   `json:"duplicates,omitempty"`
+	Repair      []*api.Mutation `json:"-"`
+}
+
+// Ok 没有发现任何问题
+func (r *IntegrityReport) Ok() bool {
+	return len(r.Untyped) == 0 && len(r.Dangling) == 0 && len(r.MissingMust) == 0 && len(r.Duplicates) == 0
+}
+
+// RepairRequest 修复计划对应的请求,可用ExplainRequest审阅后通过Txn.Txn.Do执行
+func (r *IntegrityReport) RepairRequest() *api.Request {
+	return &api.Request{Mutations: r.Repair}
+}
+
+type uidNode struct {
+	Uid string `json:"uid"`
+}
+
+// CheckIntegrity 根据库中的schema与注册的结构体检查数据完整性,只读不修改数据
+func CheckIntegrity(ctx context.Context, c *Client, opts IntegrityOptions) (*IntegrityReport, error) {
+	txn := c.Txn(true).WithContext(ctx)
+	schema, err := txn.GetSchema()
+	if err != nil {
+		return nil, err
+	}
+	var (
+		r        = &IntegrityReport{}
+		preds    []string
+		uidPreds []string
+		untyped  map[string][]string
+	)
+	for _, p := range schema.Preds {
+		preds = append(preds, p.Predicate)
+		if p.Type == TypeUid {
+			uidPreds = append(uidPreds, p.Predicate)
+		}
+	}
+	if r.Untyped, untyped, err = checkUntyped(txn, preds); err != nil {
+		return nil, err
+	}
+	if r.Dangling, err = checkDangling(txn, preds, uidPreds); err != nil {
+		return nil, err
+	}
+	for _, obj := range opts.Types {
+		if err = checkType(txn, obj, r); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Repair {
+		r.Repair = repairPlan(r, untyped)
+	}
+	return r, nil
+}
+
+// query 执行查询并解析到res
+func (d *Txn) query(q string, res interface{}) error {
+	defer d.Cancel()
+	resp, err := d.Txn.Query(d.Ctx(), q)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(resp.Json, res)
+}
+
+// integrityPageSize 完整性检查每次查询的节点数量
+var integrityPageSize = 1000
+
+// pageQuery 按uid分页执行q(func: root) block查询,每页解析到page后调用fn
+// page为元素带Uid字段的结构体切片的指针
+func pageQuery(txn *Txn, root, block string, page interface{}, fn func() error) error {
+	val := reflect.ValueOf(page).Elem()
+	var after string
+	for {
+		r := fmt.Sprintf("%s, first: %d", root, integrityPageSize)
+		if after != "" {
+			r += ", after: " + after
+		}
+		var res struct {
+			Q json.RawMessage `json:"q"`
+		}
+		if err := txn.query(fmt.Sprintf("{ q(func: %s) %s }", r, block), &res); err != nil {
+			return err
+		}
+		val.Set(reflect.Zero(val.Type()))
+		if len(res.Q) > 0 {
+			if err := json.Unmarshal(res.Q, page); err != nil {
+				return err
+			}
+		}
+		if val.Len() == 0 {
+			return nil
+		}
+		if err := fn(); err != nil {
+			return err
+		}
+		if val.Len() < integrityPageSize {
+			return nil
+		}
+		after = val.Index(val.Len() - 1).FieldByName(Uid).String()
+	}
+}
+
+// checkUntyped 有谓词但没有dgraph.type的节点,同时返回每个节点拥有的谓词
+func checkUntyped(txn *Txn, preds []string) ([]string, map[string][]string, error) {
+	set := map[string]bool{}
+	nodePreds := map[string][]string{}
+	for _, p := range preds {
+		var nodes []uidNode
+		err := pageQuery(txn, fmt.Sprintf("has(%s)", p), "@filter(NOT has(dgraph.type)) { uid }", &nodes, func() error {
+			for _, n := range nodes {
+				set[n.Uid] = true
+				nodePreds[n.Uid] = append(nodePreds[n.Uid], p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if len(set) == 0 {
+		return nil, nil, nil
+	}
+	return sortedKeys(set), nodePreds, nil
+}
+
+// checkDangling 指向的节点没有任何谓词(包括dgraph.type)的边,每页的边单独检查指向的节点
+func checkDangling(txn *Txn, preds, uidPreds []string) ([]DanglingEdge, error) {
+	var (
+		r   []DanglingEdge
+		has []string
+	)
+	for _, p := range append([]string{"dgraph.type"}, preds...) {
+		has = append(has, fmt.Sprintf("has(%s)", p))
+	}
+	for _, p := range uidPreds {
+		var nodes []struct {
+			Uid string    `json:"uid"`
+			O   []uidNode `json:"o"`
+		}
+		err := pageQuery(txn, fmt.Sprintf("has(%s)", p), fmt.Sprintf("{ uid o: %s { uid } }", p), &nodes, func() error {
+			var (
+				edges   []DanglingEdge
+				targets = map[string]bool{}
+			)
+			for _, n := range nodes {
+				for _, o := range n.O {
+					edges = append(edges, DanglingEdge{Subject: n.Uid, Predicate: p, Object: o.Uid})
+					targets[o.Uid] = true
+				}
+			}
+			alive, err := aliveOf(txn, sortedKeys(targets), strings.Join(has, " OR "))
+			if err != nil {
+				return err
+			}
+			for _, e := range edges {
+				if !alive[e.Object] {
+					r = append(r, e)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// aliveOf 返回uids中满足filter的节点,每次查询最多integrityPageSize个uid
+func aliveOf(txn *Txn, uids []string, filter string) (map[string]bool, error) {
+	alive := map[string]bool{}
+	for len(uids) > 0 {
+		n := integrityPageSize
+		if n > len(uids) {
+			n = len(uids)
+		}
+		var live map[string][]uidNode
+		q := fmt.Sprintf("{ q(func: uid(%s)) @filter(%s) { uid } }", strings.Join(uids[:n], ", "), filter)
+		if err := txn.query(q, &live); err != nil {
+			return nil, err
+		}
+		for _, u := range live["q"] {
+			alive[u.Uid] = true
+		}
+		uids = uids[n:]
+	}
+	return alive, nil
+}
+
+// checkType 检查注册结构体的must与id标签
+func checkType(txn *Txn, obj interface{}, r *IntegrityReport) error {
+	tp := reflect.TypeOf(obj)
+	for tp.Kind() == reflect.Ptr {
+		tp = tp.Elem()
+	}
+	if tp.Kind() != reflect.Struct {
+		return errors.New("registered type must be struct")
+	}
+	f, ok := tp.FieldByName(Uid)
+	if !ok || f.Tag.Get(TagDtype) == "" {
+		return errors.New("obj must have Uid field with dtype tag")
+	}
+	dtype := f.Tag.Get(TagDtype)
+	for i := 0; i < tp.NumField(); i++ {
+		tags := strings.Split(tp.Field(i).Tag.Get(TagDb), ",")
+		if tp.Field(i).Name == Uid || len(tags) < 2 {
+			continue
+		}
+		pred := strings.Split(tags[0], "@")[0]
+		for _, tg := range tags[2:] {
+			switch tg {
+			case tagMust:
+				var nodes []uidNode
+				err := pageQuery(txn, fmt.Sprintf("type(%s)", dtype), fmt.Sprintf("@filter(NOT has(%s)) { uid }", pred), &nodes, func() error {
+					for _, n := range nodes {
+						r.MissingMust = append(r.MissingMust, MissingField{Uid: n.Uid, Type: dtype, Predicate: pred})
+					}
+					return nil
+				})
+				if err != nil {
+					return err
+				}
+			case tagId:
+				var nodes []struct {
+					Uid string      `json:"uid"`
+					V   interface{} `json:"v"`
+				}
+				groups := map[string][]string{}
+				err := pageQuery(txn, fmt.Sprintf("type(%s)", dtype), fmt.Sprintf("@filter(has(%s)) { uid v: %s }", pred, pred), &nodes, func() error {
+					for _, n := range nodes {
+						v := fmt.Sprintf("%v", n.V)
+						groups[v] = append(groups[v], n.Uid)
+					}
+					return nil
+				})
+				if err != nil {
+					return err
+				}
+				for v, uids := range groups {
+					if len(uids) > 1 {
+						sort.Strings(uids)
+						r.Duplicates = append(r.Duplicates, DuplicateId{Type: dtype, Predicate: pred, Value: v, Uids: uids})
+					}
+				}
+				sort.Slice(r.Duplicates, func(i, j int) bool { return r.Duplicates[i].Value < r.Duplicates[j].Value })
+			}
+		}
+	}
+	return nil
+}
+
+// repairPlan 删除悬空边与无类型节点
+// dgraph的S * *只展开dgraph.type中类型的谓词,对无类型节点不起作用,因此逐个删除节点拥有的谓词
+func repairPlan(r *IntegrityReport, untyped map[string][]string) []*api.Mutation {
+	var del []*api.NQuad
+	for _, e := range r.Dangling {
+		del = append(del, &api.NQuad{Subject: e.Subject, Predicate: e.Predicate, ObjectId: e.Object})
+	}
+	for _, uid := range r.Untyped {
+		for _, p := range untyped[uid] {
+			del = append(del, &api.NQuad{Subject: uid, Predicate: p, ObjectValue: starNqVal})
+		}
+	}
+	if len(del) == 0 {
+		return nil
+	}
+	return []*api.Mutation{{Del: del}}
+}
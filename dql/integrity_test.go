@@ -0,0 +1,98 @@
+/**
+ * @Author: daipengyuan
+ * @Description:
+ * @File:  integrity_test
+ * @Version: 1.0.0
+ * @Date: 2026/10/20 02:00
+ */
+
+package dql_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/dgraph-io/dgo/v200/protos/api"
+	"github.com/golang-common/dglib/dql"
+	"github.com/golang-common/dglib/dql/dqltest"
+	"strings"
+	"testing"
+)
+
+type member struct {
+	Uid    string   `json:"uid" db:"uid,string" dtype:"Member"`
+	Email  string   `json:"email" db:"email,string,id"`
+	Name   string   `json:"name" db:"name,string,must"`
+	Groups []string `json:"groups" db:"groups,uid"`
+}
+
+// nq 以_:或0x开头的对象作为uid,其余作为字符串值
+func nq(s, p, o string) *api.NQuad {
+	if strings.HasPrefix(o, "_:") || strings.HasPrefix(o, "0x") {
+		return &api.NQuad{Subject: s, Predicate: p, ObjectId: o}
+	}
+	return &api.NQuad{Subject: s, Predicate: p, ObjectValue: &api.Value{Val: &api.Value_StrVal{StrVal: o}}}
+}
+
+// 按默认页大小与每页一个节点分别检查
+func TestCheckIntegrity(t *testing.T) {
+	for _, size := range []int{1000, 1} {
+		t.Run(fmt.Sprintf("page%d", size), func(t *testing.T) {
+			defer dql.SetIntegrityPageSize(size)()
+			checkIntegrity(t)
+		})
+	}
+}
+
+func checkIntegrity(t *testing.T) {
+	c := dqltest.NewClient(t)
+	ctx := context.Background()
+	set := []*api.NQuad{
+		nq("_:a", "dgraph.type", "Member"), nq("_:a", "email", "a@x"), nq("_:a", "name", "a"),
+		nq("_:a", "groups", "_:g"), nq("_:a", "groups", "0x999"),
+		nq("_:g", "dgraph.type", "Group"), nq("_:g", "name", "g"),
+		nq("_:b", "dgraph.type", "Member"), nq("_:b", "email", "a@x"),
+		nq("_:o", "name", "orphan"),
+	}
+	txn := c.Txn()
+	resp, err := txn.Txn.Mutate(ctx, &api.Mutation{Set: set})
+	txn.CommitOrAbort(err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := resp.Uids
+
+	report, err := dql.CheckIntegrity(ctx, c, dql.IntegrityOptions{Types: []interface{}{member{}}, Repair: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Untyped) != 1 || report.Untyped[0] != u["o"] {
+		t.Errorf("untyped %v", report.Untyped)
+	}
+	if len(report.Dangling) != 1 || report.Dangling[0] != (dql.DanglingEdge{Subject: u["a"], Predicate: "groups", Object: "0x999"}) {
+		t.Errorf("dangling %+v", report.Dangling)
+	}
+	if len(report.MissingMust) != 1 || report.MissingMust[0].Uid != u["b"] {
+		t.Errorf("missing must %+v", report.MissingMust)
+	}
+	if len(report.Duplicates) != 1 || len(report.Duplicates[0].Uids) != 2 || report.Duplicates[0].Value != "a@x" {
+		t.Errorf("duplicates %+v", report.Duplicates)
+	}
+	// 无类型节点逐个删除谓词,S * *对其不起作用
+	if rdf := dql.RDF(report.Repair[0].Del); !strings.Contains(rdf, "<"+u["o"]+"> <name> * .") || strings.Contains(rdf, "<"+u["o"]+"> * *") {
+		t.Errorf("repair plan\n%s", rdf)
+	}
+
+	txn = c.Txn()
+	_, err = txn.Txn.Do(ctx, report.RepairRequest())
+	txn.CommitOrAbort(err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err = dql.CheckIntegrity(ctx, c, dql.IntegrityOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Ok() {
+		t.Errorf("repair incomplete %+v", report)
+	}
+}
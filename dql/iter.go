@@ -0,0 +1,290 @@
+/**
+ * @Author: daipengyuan
+ * @Description: 按uid分页流式读取大量节点
+ * @File:  iter
+ * @Version: 1.0.0
+ * @Date: 2026/10/20 07:00
+ */
+
+package dql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+const defaultIterPage = 1000
+
+// IterQuery 流式查询,根函数的结果按uid升序分页读取
+// Func 根函数,如type(Person);Filter 为@filter中的条件,可以为空;Selection 为展示项,uid总是返回
+// PageSize 每页的节点数量,为0时使用1000;Prefetch 预取的页数,为0时读完一页再查询下一页
+// 预取时后台goroutine使用同一个dgo事务查询,Close之前不能再用该事务执行其他请求
+type IterQuery struct {
+	Func      string
+	Filter    string
+	Selection string
+	PageSize  int
+	Prefetch  int
+}
+
+// iterPage 一页查询结果,last为该页最后一个节点的uid
+type iterPage struct {
+	data []byte
+	last string
+	n    int
+	err  error
+}
+
+// Iterator 按页查询并逐个解析节点,同时只在内存中保留当前页与预取的页
+//
+//	it := txn.Iterate(q)
+//	defer it.Close()
+//	for it.Next(ctx) {
+//		if err := it.Scan(&obj); err != nil { ... }
+//	}
+//	if err := it.Err(); err != nil { ... }
+//
+// 不同页的查询在同一个事务中执行,只读事务时各页读取同一时刻的数据
+// 开启预取时dgo事务被后台goroutine并发使用,遍历结束或Close之前调用方不能使用该事务
+type Iterator struct {
+	txn   *Txn
+	q     IterQuery
+	dec   *json.Decoder
+	cur   json.RawMessage
+	after string
+	end   bool
+	err   error
+
+	once   sync.Once
+	pages  chan iterPage
+	cancel context.CancelFunc
+}
+
+// Iterate 按q流式读取节点
+func (d *Txn) Iterate(q IterQuery) *Iterator {
+	if q.PageSize <= 0 {
+		q.PageSize = defaultIterPage
+	}
+	return &Iterator{txn: d, q: q}
+}
+
+// IterateType 与List一样根据obj的标签生成查询,流式读取obj类型的节点,已被软删除的节点不会返回
+// q中的Func、Selection为空时使用obj生成的值,Filter与排除软删除节点的条件以AND组合
+func (d *Txn) IterateType(obj interface{}, q IterQuery) (*Iterator, error) {
+	tp := reflect.TypeOf(obj)
+	for tp != nil && tp.Kind() == reflect.Ptr {
+		tp = tp.Elem()
+	}
+	if tp == nil || tp.Kind() != reflect.Struct {
+		return nil, errors.New("obj must be struct or pointer of struct")
+	}
+	f, ok := tp.FieldByName(Uid)
+	if !ok || f.Tag.Get(TagDtype) == "" {
+		return nil, errors.New("obj must have Uid field with dtype tag")
+	}
+	if q.Func == "" {
+		q.Func = fmt.Sprintf("type(%s)", f.Tag.Get(TagDtype))
+	}
+	if sd := f.Tag.Get(TagSoftDelete); sd != "" {
+		if q.Filter == "" {
+			q.Filter = notDeleted(sd)
+		} else {
+			q.Filter = fmt.Sprintf("%s AND (%s)", notDeleted(sd), q.Filter)
+		}
+	}
+	if q.Selection == "" {
+		q.Selection = selection(tp)
+	}
+	return d.Iterate(q), nil
+}
+
+// Next 移动到下一个节点,没有更多节点或出错时返回false,错误由Err返回
+// 开启预取时,第一次调用的ctx控制后台的预取,之后的ctx只控制等待
+func (it *Iterator) Next(ctx context.Context) bool {
+	if it.err != nil {
+		return false
+	}
+	for {
+		if it.dec != nil && it.dec.More() {
+			it.cur = nil
+			if it.err = it.dec.Decode(&it.cur); it.err != nil {
+				return false
+			}
+			return true
+		}
+		it.dec, it.cur = nil, nil
+		p, ok := it.nextPage(ctx)
+		if !ok {
+			return false
+		}
+		if p.err != nil {
+			it.err = p.err
+			return false
+		}
+		if it.dec, it.err = pageDecoder(p.data); it.err != nil {
+			return false
+		}
+	}
+}
+
+// Scan 将当前节点解析到obj,结构体中的uid字段与List一样为uid字符串
+func (it *Iterator) Scan(obj interface{}) error {
+	if it.cur == nil {
+		return errors.New("no current node, call Next first")
+	}
+	tp := reflect.TypeOf(obj)
+	if tp.Kind() != reflect.Ptr || tp.Elem().Kind() != reflect.Struct {
+		return Unmarshal(it.cur, obj)
+	}
+	var n map[string]interface{}
+	if err := decodeNumber(it.cur, &n); err != nil {
+		return err
+	}
+	flattenUids(n, tp.Elem())
+	bs, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	return Unmarshal(bs, obj)
+}
+
+// Raw 当前节点的json
+func (it *Iterator) Raw() json.RawMessage {
+	return it.cur
+}
+
+func (it *Iterator) Err() error {
+	return it.err
+}
+
+// Close 停止预取,提前结束遍历时需要调用
+func (it *Iterator) Close() {
+	if it.cancel != nil {
+		it.cancel()
+	}
+	it.end = true
+	it.dec, it.cur = nil, nil
+}
+
+// nextPage 读取下一页,没有更多页时返回false
+func (it *Iterator) nextPage(ctx context.Context) (iterPage, bool) {
+	if it.q.Prefetch <= 0 {
+		if it.end {
+			return iterPage{}, false
+		}
+		p := it.fetch(ctx, it.after)
+		it.after = p.last
+		it.end = p.err != nil || p.n < it.q.PageSize
+		return p, true
+	}
+	it.once.Do(func() {
+		if it.end {
+			return
+		}
+		pctx, cancel := context.WithCancel(ctx)
+		it.cancel = cancel
+		it.pages = make(chan iterPage, it.q.Prefetch)
+		go it.prefetch(pctx)
+	})
+	if it.pages == nil || it.end {
+		return iterPage{}, false
+	}
+	select {
+	case p, ok := <-it.pages:
+		return p, ok
+	case <-ctx.Done():
+		// 停止预取,之后的Next直接返回false
+		it.end = true
+		it.cancel()
+		return iterPage{err: ctx.Err()}, true
+	}
+}
+
+// prefetch 在后台依次查询各页,通道已满时等待
+func (it *Iterator) prefetch(ctx context.Context) {
+	defer close(it.pages)
+	var after string
+	for {
+		p := it.fetch(ctx, after)
+		select {
+		case it.pages <- p:
+		case <-ctx.Done():
+			return
+		}
+		if p.err != nil || p.n < it.q.PageSize {
+			return
+		}
+		after = p.last
+	}
+}
+
+// fetch 查询after之后的一页,预取时与调用方并发执行,因此不使用Txn.Ctx
+func (it *Iterator) fetch(ctx context.Context, after string) iterPage {
+	root := fmt.Sprintf("%s, first: %d", it.q.Func, it.q.PageSize)
+	if after != "" {
+		root += ", after: " + after
+	}
+	var filter string
+	if it.q.Filter != "" {
+		filter = fmt.Sprintf(" @filter(%s)", it.q.Filter)
+	}
+	sel := it.q.Selection
+	if !strings.HasPrefix(strings.TrimSpace(sel), "uid") {
+		sel = "uid " + sel
+	}
+	if it.txn.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, it.txn.Timeout)
+		defer cancel()
+	}
+	resp, err := it.txn.Txn.Query(ctx, fmt.Sprintf("{ q(func: %s)%s { %s } }", root, filter, sel))
+	if err != nil {
+		return iterPage{err: err}
+	}
+	// 只解析uid,确定下一页的起点
+	var res struct {
+		Q []struct {
+			Uid string `json:"uid"`
+		} `json:"q"`
+	}
+	if err = json.Unmarshal(resp.Json, &res); err != nil {
+		return iterPage{err: err}
+	}
+	p := iterPage{data: resp.Json, n: len(res.Q)}
+	if p.n > 0 {
+		p.last = res.Q[p.n-1].Uid
+	}
+	return p
+}
+
+// pageDecoder 返回定位到q数组第一个元素之前的解码器,q不存在时返回空
+func pageDecoder(data []byte) (*json.Decoder, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+		return nil, errors.New("unexpected query response")
+	}
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		if t != "q" {
+			var skip json.RawMessage
+			if err = dec.Decode(&skip); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if t, err = dec.Token(); err != nil || t != json.Delim('[') {
+			return nil, errors.New("unexpected query response")
+		}
+		return dec, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,181 @@
+/**
+ * @Author: daipengyuan
+ * @Description:
+ * @File:  iter_test
+ * @Version: 1.0.0
+ * @Date: 2026/10/20 07:00
+ */
+
+package dql_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/dgraph-io/dgo/v200/protos/api"
+	"github.com/golang-common/dglib/dql"
+	"github.com/golang-common/dglib/dql/dqltest"
+	"strconv"
+	"testing"
+)
+
+type sensor struct {
+	Uid     string  `json:"uid" db:"uid,string" dtype:"Sensor"`
+	Serial  string  `json:"serial" db:"serial,string"`
+	Reading float64 `json:"reading" db:"reading,float"`
+	Site    string  `json:"site" db:"site,uid"`
+}
+
+func TestIterate(t *testing.T) {
+	c := dqltest.NewClient(t)
+	if err := c.SetPred(dql.Pred{Predicate: "site", Type: "uid"}); err != nil {
+		t.Fatal(err)
+	}
+	set := []*api.NQuad{nq("_:site", "name", "north")}
+	for i := 0; i < 25; i++ {
+		s := fmt.Sprintf("_:s%d", i)
+		set = append(set,
+			nq(s, "dgraph.type", "Sensor"),
+			nq(s, "serial", fmt.Sprintf("S%02d", i)),
+			&api.NQuad{Subject: s, Predicate: "reading", ObjectValue: &api.Value{Val: &api.Value_DoubleVal{DoubleVal: float64(i) / 2}}},
+			&api.NQuad{Subject: s, Predicate: "site", ObjectId: "_:site"},
+		)
+	}
+	txn := c.Txn()
+	resp, err := txn.Txn.Mutate(txn.Ctx(), &api.Mutation{Set: set, CommitNow: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	for _, prefetch := range []int{0, 2} {
+		t.Run("prefetch"+strconv.Itoa(prefetch), func(t *testing.T) {
+			it, err := c.Txn(true).IterateType(sensor{}, dql.IterQuery{PageSize: 7, Prefetch: prefetch})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer it.Close()
+			var (
+				n    int
+				last uint64
+			)
+			for it.Next(ctx) {
+				var s sensor
+				if err := it.Scan(&s); err != nil {
+					t.Fatal(err)
+				}
+				u, _ := strconv.ParseUint(s.Uid[2:], 16, 64)
+				if u <= last {
+					t.Fatalf("uid %s out of order", s.Uid)
+				}
+				last = u
+				if s.Serial != fmt.Sprintf("S%02d", n) || s.Reading != float64(n)/2 || s.Site != resp.Uids["site"] {
+					t.Fatalf("node %d: %+v", n, s)
+				}
+				n++
+			}
+			if err := it.Err(); err != nil {
+				t.Fatal(err)
+			}
+			if n != 25 {
+				t.Fatalf("iterated %d nodes", n)
+			}
+		})
+	}
+
+	// 自定义查询,页大小整除节点数时最后一页为空
+	it := c.Txn(true).Iterate(dql.IterQuery{Func: "type(Sensor)", Filter: `lt(reading, 5)`, Selection: "serial", PageSize: 5})
+	var serials []string
+	for it.Next(ctx) {
+		var s struct {
+			Serial string `json:"serial"`
+		}
+		if err := it.Scan(&s); err != nil {
+			t.Fatal(err)
+		}
+		serials = append(serials, s.Serial)
+	}
+	if it.Err() != nil || len(serials) != 10 || serials[9] != "S09" {
+		t.Fatalf("serials %v, err %v", serials, it.Err())
+	}
+	if err = it.Scan(&sensor{}); err == nil {
+		t.Fatal("scan after end must fail")
+	}
+
+	// 提前结束时Close停止预取
+	it = c.Txn(true).Iterate(dql.IterQuery{Func: "type(Sensor)", Selection: "serial", PageSize: 2, Prefetch: 1})
+	if !it.Next(ctx) || len(it.Raw()) == 0 {
+		t.Fatalf("expected first node, err %v", it.Err())
+	}
+	it.Close()
+	if it.Next(ctx) {
+		t.Fatal("next after close must be false")
+	}
+
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	it = c.Txn(true).Iterate(dql.IterQuery{Func: "type(Sensor)", Selection: "serial"})
+	if it.Next(cctx) || it.Err() == nil {
+		t.Fatal("expected error of canceled ctx")
+	}
+	// 预取时ctx取消后结束遍历
+	it = c.Txn(true).Iterate(dql.IterQuery{Func: "type(Sensor)", Selection: "serial", PageSize: 1, Prefetch: 1})
+	defer it.Close()
+	if !it.Next(ctx) {
+		t.Fatalf("expected first node, err %v", it.Err())
+	}
+	it.Next(ctx)
+	for it.Next(cctx) {
+	}
+	if it.Err() == nil || it.Next(ctx) {
+		t.Fatal("expected iteration to stop after ctx canceled")
+	}
+}
+
+type meter struct {
+	Uid   string `json:"uid" db:"uid,string" dtype:"Meter" softdelete:"deleted_at"`
+	Total int64  `json:"total" db:"total,int"`
+}
+
+// 自定义Filter时仍排除软删除的节点,大整数不丢失精度
+func TestIterateType_SoftDelete(t *testing.T) {
+	c := dqltest.NewClient(t)
+	const big = int64(1)<<53 + 1
+	var uids []string
+	for _, total := range []int64{big, big + 2} {
+		txn := c.Txn()
+		resp, err := txn.Add(meter{Total: total})
+		txn.CommitOrAbort(err)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, u := range resp.Uids {
+			uids = append(uids, u)
+		}
+	}
+	txn := c.Txn()
+	_, err := txn.DelNode(meter{Uid: uids[1]})
+	txn.CommitOrAbort(err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	it, err := c.Txn(true).IterateType(meter{}, dql.IterQuery{Filter: "has(total)"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer it.Close()
+	var ms []meter
+	for it.Next(context.Background()) {
+		var m meter
+		if err := it.Scan(&m); err != nil {
+			t.Fatal(err)
+		}
+		ms = append(ms, m)
+	}
+	if it.Err() != nil || len(ms) != 1 || ms[0].Total != big {
+		t.Fatalf("meters %+v, err %v", ms, it.Err())
+	}
+	m := meter{Uid: uids[0]}
+	if err = c.Txn(true).Get(&m); err != nil || m.Total != big {
+		t.Fatalf("get %+v, err %v", m, err)
+	}
+}
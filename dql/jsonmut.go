@@ -0,0 +1,303 @@
+/**
+ * @Author: daipengyuan
+ * @Description: 将nquad变更转换为dgraph的json变更
+ * @File:  jsonmut
+ * @Version: 1.0.0
+ * @Date: 2026/10/20 05:30
+ */
+
+package dql
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dgraph-io/dgo/v200/protos/api"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ToJSONRequest 将请求中的Set/Del nquad转换为SetJson/DeleteJson,语义与原请求一致
+//
+//	谓词的语言写为pred@lang,facet写为pred|key,uid边的facet写在子节点中
+//	同一变更中只被引用一次的空白节点嵌套到引用它的节点中
+//	同一谓词有多个值时写为数组,值的facet写为{"序号":值}
+//
+// 不支持val(x)宾语;变更中已有SetJson/DeleteJson时不能再有对应的Set/Del,SetNquads/DelNquads原样保留
+func ToJSONRequest(req *api.Request) (*api.Request, error) {
+	r := &api.Request{Query: req.Query, Vars: req.Vars, CommitNow: req.CommitNow, RespFormat: req.RespFormat}
+	for _, mu := range req.Mutations {
+		if (len(mu.SetJson) > 0 && len(mu.Set) > 0) || (len(mu.DeleteJson) > 0 && len(mu.Del) > 0) {
+			return nil, errors.New("mutation has both json and nquads of the same kind")
+		}
+		jm := &api.Mutation{Cond: mu.Cond, CommitNow: mu.CommitNow, SetJson: mu.SetJson, DeleteJson: mu.DeleteJson,
+			SetNquads: mu.SetNquads, DelNquads: mu.DelNquads}
+		if len(mu.Set) > 0 {
+			objs, err := setJSON(mu.Set)
+			if err != nil {
+				return nil, err
+			}
+			if jm.SetJson, err = json.Marshal(objs); err != nil {
+				return nil, err
+			}
+		}
+		if len(mu.Del) > 0 {
+			objs, err := delJSON(mu.Del)
+			if err != nil {
+				return nil, err
+			}
+			if jm.DeleteJson, err = json.Marshal(objs); err != nil {
+				return nil, err
+			}
+		}
+		r.Mutations = append(r.Mutations, jm)
+	}
+	return r, nil
+}
+
+type jsonObj = map[string]interface{}
+
+// jsonNodes 按主语出现的顺序收集节点
+type jsonNodes struct {
+	order []string
+	objs  map[string]jsonObj
+}
+
+func (ns *jsonNodes) get(s string) jsonObj {
+	if ns.objs == nil {
+		ns.objs = map[string]jsonObj{}
+	}
+	o, ok := ns.objs[s]
+	if !ok {
+		o = jsonObj{"uid": s}
+		ns.objs[s] = o
+		ns.order = append(ns.order, s)
+	}
+	return o
+}
+
+// addJSON 向节点的key追加一个值,第二个值起转为数组
+func addJSON(o jsonObj, key string, v interface{}) int {
+	old, ok := o[key]
+	if !ok {
+		o[key] = v
+		return 0
+	}
+	l, isList := old.([]interface{})
+	if !isList {
+		l = []interface{}{old}
+	}
+	o[key] = append(l, v)
+	return len(l)
+}
+
+func setJSON(nqs []*api.NQuad) ([]jsonObj, error) {
+	var (
+		ns   jsonNodes
+		refs = map[string]int{}
+		// 值的facet按序号记录,数组时写为{"序号":值}
+		valFacets = map[string]map[string]map[int]interface{}{}
+	)
+	for _, nq := range nqs {
+		if nq.Predicate == StarAll {
+			return nil, errors.New("cannot set * predicate")
+		}
+		o := ns.get(nq.Subject)
+		key := nq.Predicate
+		if nq.Lang != "" {
+			key += "@" + nq.Lang
+		}
+		if nq.ObjectId != "" {
+			if strings.HasPrefix(nq.ObjectId, "val(") {
+				return nil, errors.New("val() object is not supported in json mutation")
+			}
+			child := jsonObj{"uid": nq.ObjectId}
+			for _, f := range nq.Facets {
+				v, err := facetJSON(f)
+				if err != nil {
+					return nil, err
+				}
+				child[nq.Predicate+"|"+f.Key] = v
+			}
+			addJSON(o, key, child)
+			refs[nq.ObjectId]++
+			continue
+		}
+		v, err := valueJSON(nq.ObjectValue)
+		if err != nil {
+			return nil, err
+		}
+		idx := addJSON(o, key, v)
+		for _, f := range nq.Facets {
+			fv, err := facetJSON(f)
+			if err != nil {
+				return nil, err
+			}
+			fk := nq.Subject + "\x00" + key
+			if valFacets[fk] == nil {
+				valFacets[fk] = map[string]map[int]interface{}{}
+			}
+			if valFacets[fk][f.Key] == nil {
+				valFacets[fk][f.Key] = map[int]interface{}{}
+			}
+			valFacets[fk][f.Key][idx] = fv
+		}
+	}
+	for fk, fs := range valFacets {
+		parts := strings.SplitN(fk, "\x00", 2)
+		o := ns.objs[parts[0]]
+		_, isList := o[parts[1]].([]interface{})
+		for k, vs := range fs {
+			if !isList {
+				o[parts[1]+"|"+k] = vs[0]
+				continue
+			}
+			m := jsonObj{}
+			for i, v := range vs {
+				m[strconv.Itoa(i)] = v
+			}
+			o[parts[1]+"|"+k] = m
+		}
+	}
+	// 只被引用一次的空白节点嵌套到引用处
+	nestable := func(s string) bool {
+		_, ok := ns.objs[s]
+		return ok && strings.HasPrefix(s, "_:") && refs[s] == 1
+	}
+	emitted := map[string]bool{}
+	var emit func(s string) jsonObj
+	emit = func(s string) jsonObj {
+		emitted[s] = true
+		o := ns.objs[s]
+		for k, v := range o {
+			switch x := v.(type) {
+			case jsonObj:
+				o[k] = inline(x, nestable, emitted, emit)
+			case []interface{}:
+				for i, e := range x {
+					if c, ok := e.(jsonObj); ok {
+						x[i] = inline(c, nestable, emitted, emit)
+					}
+				}
+			}
+		}
+		return o
+	}
+	var r []jsonObj
+	for _, s := range ns.order {
+		if !nestable(s) && !emitted[s] {
+			r = append(r, emit(s))
+		}
+	}
+	// 互相引用的空白节点没有根节点,按顺序补充
+	for _, s := range ns.order {
+		if !emitted[s] {
+			r = append(r, emit(s))
+		}
+	}
+	return r, nil
+}
+
+// inline 将边指向的节点合并到边对象中,边的facet保留
+func inline(c jsonObj, nestable func(string) bool, emitted map[string]bool, emit func(string) jsonObj) jsonObj {
+	uid, _ := c["uid"].(string)
+	if !nestable(uid) || emitted[uid] {
+		return c
+	}
+	o := emit(uid)
+	for k, v := range c {
+		o[k] = v
+	}
+	return o
+}
+
+func delJSON(nqs []*api.NQuad) ([]jsonObj, error) {
+	var (
+		ns    jsonNodes
+		nodes []jsonObj
+	)
+	for _, nq := range nqs {
+		if nq.Predicate == StarAll {
+			// 只有uid的对象表示删除节点的所有谓词
+			nodes = append(nodes, jsonObj{"uid": nq.Subject})
+			continue
+		}
+		o := ns.get(nq.Subject)
+		key := nq.Predicate
+		if nq.Lang != "" {
+			key += "@" + nq.Lang
+		}
+		switch {
+		case nq.ObjectId == StarAll || isStarVal(nq.ObjectValue):
+			o[key] = nil
+		case nq.ObjectId != "":
+			if strings.HasPrefix(nq.ObjectId, "val(") {
+				return nil, errors.New("val() object is not supported in json mutation")
+			}
+			old, _ := o[key].([]interface{})
+			o[key] = append(old, jsonObj{"uid": nq.ObjectId})
+		default:
+			v, err := valueJSON(nq.ObjectValue)
+			if err != nil {
+				return nil, err
+			}
+			addJSON(o, key, v)
+		}
+	}
+	var r []jsonObj
+	for _, s := range ns.order {
+		r = append(r, ns.objs[s])
+	}
+	return append(r, nodes...), nil
+}
+
+// valueJSON api.Value在json变更中的表示
+func valueJSON(v *api.Value) (interface{}, error) {
+	if v == nil {
+		return nil, errors.New("nquad has neither object id nor object value")
+	}
+	switch x := v.Val.(type) {
+	case *api.Value_DefaultVal:
+		return x.DefaultVal, nil
+	case *api.Value_StrVal:
+		return x.StrVal, nil
+	case *api.Value_IntVal:
+		return x.IntVal, nil
+	case *api.Value_DoubleVal:
+		return x.DoubleVal, nil
+	case *api.Value_BoolVal:
+		return x.BoolVal, nil
+	case *api.Value_PasswordVal:
+		return x.PasswordVal, nil
+	case *api.Value_DatetimeVal:
+		var t time.Time
+		if err := t.UnmarshalBinary(x.DatetimeVal); err != nil {
+			return nil, err
+		}
+		return t.Format(time.RFC3339Nano), nil
+	case *api.Value_GeoVal:
+		return json.RawMessage(x.GeoVal), nil
+	}
+	return nil, errors.New(fmt.Sprintf("unsupported value %v in json mutation", v))
+}
+
+// facetJSON facet在json变更中的表示,与Facet.parse的编码对应
+func facetJSON(f *api.Facet) (interface{}, error) {
+	s := string(f.Value)
+	switch f.ValType {
+	case api.Facet_INT:
+		return strconv.ParseInt(s, 10, 64)
+	case api.Facet_FLOAT:
+		return strconv.ParseFloat(s, 64)
+	case api.Facet_BOOL:
+		return strconv.ParseBool(s)
+	case api.Facet_DATETIME:
+		var t time.Time
+		if err := t.UnmarshalBinary(f.Value); err != nil {
+			return nil, err
+		}
+		return t.Format(time.RFC3339Nano), nil
+	}
+	return s, nil
+}
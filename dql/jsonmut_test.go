@@ -0,0 +1,188 @@
+/**
+ * @Author: daipengyuan
+ * @Description:
+ * @File:  jsonmut_test
+ * @Version: 1.0.0
+ * @Date: 2026/10/20 05:30
+ */
+
+package dql_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/dgraph-io/dgo/v200/protos/api"
+	"github.com/golang-common/dglib/dql"
+	"github.com/golang-common/dglib/dql/dqltest"
+	"testing"
+)
+
+func strVal(s string) *api.Value {
+	return &api.Value{Val: &api.Value_StrVal{StrVal: s}}
+}
+
+// jsonCases 覆盖嵌套空白节点、边与值的facet、语言、列表、删除谓词与删除节点
+func jsonCases(t *testing.T) []*api.Request {
+	since := &dql.Facet{Key: "since", Value: 2020}
+	close := &dql.Facet{Key: "close", Value: true}
+	edge := &api.NQuad{Subject: "_:a", Predicate: "friend", ObjectId: "_:b"}
+	tag := &api.NQuad{Subject: "_:a", Predicate: "tags", ObjectValue: strVal("x")}
+	for _, f := range []*dql.Facet{since, close} {
+		if err := f.Combine(edge); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := since.Combine(tag); err != nil {
+		t.Fatal(err)
+	}
+	return []*api.Request{
+		{Mutations: []*api.Mutation{{Set: []*api.NQuad{
+			{Subject: "_:a", Predicate: "name", ObjectValue: strVal("alice")},
+			{Subject: "_:a", Predicate: "name", Lang: "fr", ObjectValue: strVal("alice-fr")},
+			{Subject: "_:a", Predicate: "age", ObjectValue: &api.Value{Val: &api.Value_IntVal{IntVal: 30}}},
+			tag,
+			{Subject: "_:a", Predicate: "tags", ObjectValue: strVal("y")},
+			edge,
+			{Subject: "_:b", Predicate: "name", ObjectValue: strVal("bob")},
+			{Subject: "_:c", Predicate: "name", ObjectValue: strVal("carol")},
+			{Subject: "_:c", Predicate: "friend", ObjectId: "_:a"},
+			{Subject: "_:c", Predicate: "friend", ObjectId: "_:b"},
+		}}}},
+		{
+			Query: `query{ a as var(func: eq(name, "alice")) b as var(func: eq(name, "bob")) c as var(func: eq(name, "carol")) }`,
+			Mutations: []*api.Mutation{{
+				Del: []*api.NQuad{
+					{Subject: "uid(a)", Predicate: "tags", ObjectValue: strVal("y")},
+					{Subject: "uid(c)", Predicate: "friend", ObjectId: "uid(b)"},
+					{Subject: "uid(b)", Predicate: dql.StarAll, ObjectValue: &api.Value{Val: &api.Value_DefaultVal{DefaultVal: dql.StarAll}}},
+				},
+				Set: []*api.NQuad{{Subject: "uid(c)", Predicate: "age", ObjectValue: &api.Value{Val: &api.Value_IntVal{IntVal: 7}}}},
+			}},
+		},
+	}
+}
+
+const jsonDump = `{ q(func: has(name), orderasc: name) { name name@fr age tags @facets friend @facets { name } } }`
+
+func TestToJSONRequest(t *testing.T) {
+	var out bytes.Buffer
+	for _, req := range jsonCases(t) {
+		jr, err := dql.ToJSONRequest(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, mu := range jr.Mutations {
+			for _, bs := range [][]byte{mu.DeleteJson, mu.SetJson} {
+				if len(bs) > 0 {
+					json.Indent(&out, bs, "", "  ")
+					out.WriteString("\n")
+				}
+			}
+		}
+	}
+	dqltest.Golden(t, out.Bytes())
+
+	// 两种模式执行后的数据一致
+	dump := func(useJSON bool) string {
+		c := dqltest.NewClient(t)
+		if err := c.SetPred(dql.Pred{Predicate: "tags", Type: "string", List: true}); err != nil {
+			t.Fatal(err)
+		}
+		for _, req := range jsonCases(t) {
+			if useJSON {
+				var err error
+				if req, err = dql.ToJSONRequest(req); err != nil {
+					t.Fatal(err)
+				}
+			}
+			req.CommitNow = true
+			if _, err := c.Txn().Txn.Do(context.Background(), req); err != nil {
+				t.Fatal(err)
+			}
+		}
+		resp, err := c.Txn(true).Txn.Query(context.Background(), jsonDump)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(resp.Json)
+	}
+	if a, b := dump(false), dump(true); a != b {
+		t.Fatalf("json mode differs:\nnquad %s\njson  %s", a, b)
+	}
+
+	// 已有json的变更不能被转换结果覆盖
+	mixed := &api.Request{Mutations: []*api.Mutation{{
+		SetJson: []byte(`{"name":"x"}`),
+		Set:     []*api.NQuad{{Subject: "_:y", Predicate: "name", ObjectValue: &api.Value{Val: &api.Value_StrVal{StrVal: "y"}}}},
+	}}}
+	if _, err := dql.ToJSONRequest(mixed); err == nil {
+		t.Fatal("mutation with both SetJson and Set accepted")
+	}
+	mixed.Mutations[0].SetJson, mixed.Mutations[0].DeleteJson = nil, []byte(`{"uid":"0x1"}`)
+	jr, err := dql.ToJSONRequest(mixed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(jr.Mutations[0].DeleteJson) != `{"uid":"0x1"}` || len(jr.Mutations[0].SetJson) == 0 {
+		t.Fatalf("json lost: %+v", jr.Mutations[0])
+	}
+}
+
+func TestTxn_JSON(t *testing.T) {
+	c := dqltest.NewClient(t)
+	dqltest.SeqBlankNodes(t)
+	txn := c.Txn()
+	txn.JSON = true
+	resp, err := txn.Add(person{Name: "alice", Age: 30})
+	txn.CommitOrAbort(err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uid := resp.Uids["b1"]
+	if uid == "" {
+		t.Fatalf("blank node not returned: %v", resp.Uids)
+	}
+	txn = c.Txn()
+	txn.JSON = true
+	_, err = txn.Update(person{Uid: uid, Name: "alice", Age: 31})
+	txn.CommitOrAbort(err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := person{Uid: uid}
+	if err = c.Txn(true).Get(&p); err != nil || p.Age != 31 {
+		t.Fatalf("update in json mode: %v %+v", err, p)
+	}
+
+	txn = c.Txn()
+	txn.JSON, txn.DryRun = true, true
+	if _, err = txn.DelNode(person{Uid: uid}); err != nil {
+		t.Fatal(err)
+	}
+	if mu := txn.Explains[0].Mutations[0]; mu.DelJson != `[{"uid":"`+uid+`"}]` {
+		t.Fatalf("unexpected explain %+v", mu)
+	}
+}
+
+type label struct {
+	Uid  string `json:"uid" db:"uid,string" dtype:"Label"`
+	Name string `json:"name" db:"name@en,string"`
+}
+
+// 带语言的谓词以pred@lang为json键
+func TestTxn_JSONLang(t *testing.T) {
+	c := dqltest.NewClient(t)
+	txn := c.Txn()
+	txn.JSON, txn.DryRun = true, true
+	if _, err := txn.Add(label{Name: "red"}); err != nil {
+		t.Fatal(err)
+	}
+	var nodes []map[string]interface{}
+	if err := json.Unmarshal([]byte(txn.Explains[0].Mutations[0].SetJson), &nodes); err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 1 || nodes[0]["name@en"] != "red" {
+		t.Fatalf("unexpected set json %s", txn.Explains[0].Mutations[0].SetJson)
+	}
+}
@@ -0,0 +1,747 @@
+/**
+ * @Author: daipengyuan
+ * @Description: 从csv或jsonl文件批量写入结构体类型的节点
+ * @File:  loader
+ * @Version: 1.0.0
+ * @Date: 2026/10/20 06:30
+ */
+
+package dql
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dgraph-io/dgo/v200"
+	"github.com/dgraph-io/dgo/v200/protos/api"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type LoadFormat string
+
+const (
+	LoadCSV   LoadFormat = "csv"   // 第一行为表头
+	LoadJSONL LoadFormat = "jsonl" // 每行一个json对象
+)
+
+const (
+	defaultLoadBatch   = 500
+	defaultLoadWorkers = 4
+	defaultLoadRetries = 3
+	loadRetryBackoff   = 100 * time.Millisecond
+)
+
+// LoadOptions Load的选项
+type LoadOptions struct {
+	Format LoadFormat
+	// Columns 列名(csv表头或jsonl的键)到字段名、json名或谓词名的映射,值为"-"时忽略该列
+	// 未配置的列按同名匹配,无法匹配时报错
+	Columns map[string]string
+	// Refs uid字段的值为被引用节点id字段的值,键为uid字段,值为被引用类型的结构体(如Person{})
+	// 未配置的uid字段的值直接作为uid
+	Refs map[string]interface{}
+	// ListSep csv中切片字段多个值的分隔符,为空时使用";"
+	ListSep string
+	// BatchSize 每个事务写入的记录数,Workers 并行的事务数,Retries 事务冲突或节点不可用时的重试次数
+	BatchSize int
+	Workers   int
+	Retries   int
+	// Checkpoint 断点文件,记录已提交批次的序号与内容摘要,再次Load时跳过这些批次,全部完成后删除
+	// 断点文件还记录BatchSize与输入文件的路径(r为*os.File时),与本次不一致或批次内容不同时报错
+	Checkpoint string
+	// Progress 每提交一批后回调,并行时可能在不同的goroutine中调用,但不会同时调用
+	Progress func(LoadProgress)
+}
+
+// LoadProgress 加载进度,Skipped为断点文件中已完成而跳过的记录数
+type LoadProgress struct {
+	Records int
+	Skipped int
+	Batches int
+	Retries int
+}
+
+// loadRow 一条记录,row为在文件中的行号
+type loadRow struct {
+	row    int
+	values map[string]interface{}
+}
+
+// loadBatch 一批记录,hash为记录内容的摘要,keys为记录的id值
+type loadBatch struct {
+	seq  int
+	rows []loadRow
+	hash string
+	keys []string
+}
+
+// loadRef uid字段按被引用类型的id谓词查找节点
+type loadRef struct {
+	dtype string
+	pred  string
+}
+
+type loader struct {
+	client  *Client
+	tp      reflect.Type
+	upsert  bool
+	keys    map[int]bool
+	source  string
+	opts    LoadOptions
+	refs    map[int]loadRef
+	fields  map[string]int
+	fieldMu sync.Mutex
+
+	mu       sync.Mutex
+	progress LoadProgress
+	done     map[int]string
+
+	keyMu    sync.Mutex
+	keyCond  *sync.Cond
+	inflight map[string]bool
+}
+
+// Load 读取r中的记录写入obj类型的节点,obj为带dtype标签的结构体
+//
+//	列的值按字段db标签中的数据类型转换,再与Add一样生成nquad;obj有id标签的字段时按id执行Upsert,重复加载不会产生重复节点
+//	引用的节点在写入每批记录前查询,因此被引用的类型需要先加载
+//	并行的批次之间没有顺序,但id值相同的记录按文件中的顺序写入,不会在并行的事务中同时创建节点
+//	出错时已提交的批次不会回滚,配置Checkpoint后可从断点继续
+func Load(ctx context.Context, client *Client, obj interface{}, r io.Reader, opts LoadOptions) (LoadProgress, error) {
+	m, err := newMutation(obj)
+	if err != nil {
+		return LoadProgress{}, err
+	}
+	l := &loader{client: client, tp: m.Val.Type(), opts: opts, refs: map[int]loadRef{}, fields: map[string]int{},
+		keys: map[int]bool{}, done: map[int]string{}, inflight: map[string]bool{}}
+	l.keyCond = sync.NewCond(&l.keyMu)
+	keys, err := m.upsertKeys(nil)
+	l.upsert = err == nil
+	for _, f := range keys {
+		l.keys[f.Index[0]] = true
+	}
+	if f, ok := r.(*os.File); ok {
+		l.source = f.Name()
+	}
+	if l.opts.BatchSize <= 0 {
+		l.opts.BatchSize = defaultLoadBatch
+	}
+	if l.opts.Workers <= 0 {
+		l.opts.Workers = defaultLoadWorkers
+	}
+	if l.opts.Retries <= 0 {
+		l.opts.Retries = defaultLoadRetries
+	}
+	if l.opts.ListSep == "" {
+		l.opts.ListSep = ";"
+	}
+	if err = l.parseRefs(); err != nil {
+		return LoadProgress{}, err
+	}
+	if err = l.readCheckpoint(); err != nil {
+		return LoadProgress{}, err
+	}
+	var rows func() (loadRow, error)
+	switch opts.Format {
+	case LoadCSV:
+		rows, err = csvRows(r)
+	case LoadJSONL:
+		rows = jsonlRows(r)
+	default:
+		err = errors.New("unsupported load format " + string(opts.Format))
+	}
+	if err != nil {
+		return LoadProgress{}, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+		batches  = make(chan loadBatch)
+	)
+	fail := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+	for i := 0; i < l.opts.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for b := range batches {
+				if err := l.load(ctx, b); err != nil {
+					fail(err)
+				}
+				l.release(b.keys)
+			}
+		}()
+	}
+	if err = l.produce(ctx, rows, batches); err != nil {
+		fail(err)
+	}
+	close(batches)
+	wg.Wait()
+	if firstErr != nil {
+		return l.progress, firstErr
+	}
+	if l.opts.Checkpoint != "" {
+		if err = os.Remove(l.opts.Checkpoint); err != nil && !os.IsNotExist(err) {
+			return l.progress, err
+		}
+	}
+	return l.progress, nil
+}
+
+// produce 按BatchSize分批,跳过断点文件中已完成的批次
+// 批次中的id值与正在写入的批次相同时,等待其完成后再发送
+func (l *loader) produce(ctx context.Context, rows func() (loadRow, error), batches chan<- loadBatch) error {
+	b := loadBatch{}
+	send := func() error {
+		if len(b.rows) == 0 {
+			return nil
+		}
+		hash, err := batchHash(b.rows)
+		if err != nil {
+			return err
+		}
+		b.hash = hash
+		l.mu.Lock()
+		done, skip := l.done[b.seq]
+		if skip && done == b.hash {
+			l.progress.Skipped += len(b.rows)
+		}
+		l.mu.Unlock()
+		if skip && done != b.hash {
+			return errors.New(fmt.Sprintf("batch from row %d differs from the checkpoint", b.rows[0].row))
+		}
+		if !skip {
+			b.keys = l.batchKeys(b.rows)
+			if err = l.acquire(ctx, b.keys); err != nil {
+				return err
+			}
+			select {
+			case batches <- b:
+			case <-ctx.Done():
+				l.release(b.keys)
+				return ctx.Err()
+			}
+		}
+		b = loadBatch{seq: b.seq + 1}
+		return nil
+	}
+	for {
+		row, err := rows()
+		if err == io.EOF {
+			return send()
+		}
+		if err != nil {
+			return err
+		}
+		b.rows = append(b.rows, row)
+		if len(b.rows) >= l.opts.BatchSize {
+			if err = send(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// batchHash 一批记录的内容摘要,用于确认断点文件中的批次与本次输入一致
+func batchHash(rows []loadRow) (string, error) {
+	h := sha256.New()
+	for _, row := range rows {
+		bs, err := json.Marshal(row.values)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%d\x00%s\n", row.row, bs)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// batchKeys 一批记录中id字段的值,无法解析的记录在load中报错
+func (l *loader) batchKeys(rows []loadRow) []string {
+	if !l.upsert {
+		return nil
+	}
+	set := map[string]bool{}
+	for _, row := range rows {
+		for col, raw := range row.values {
+			i, err := l.field(col)
+			if err != nil || i < 0 || !l.keys[i] || raw == nil {
+				continue
+			}
+			if v, err := coerce(fieldDt(l.tp.Field(i)), raw); err == nil {
+				set[fmt.Sprintf("%d\x00%v", i, v)] = true
+			}
+		}
+	}
+	return sortedKeys(set)
+}
+
+// acquire 等待正在写入的批次中没有相同的id值,再登记本批次的id值
+// 等待时必有持有这些id值的批次在写入,其完成或出错后都会调用release唤醒
+func (l *loader) acquire(ctx context.Context, keys []string) error {
+	l.keyMu.Lock()
+	defer l.keyMu.Unlock()
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		busy := false
+		for _, k := range keys {
+			if l.inflight[k] {
+				busy = true
+				break
+			}
+		}
+		if !busy {
+			break
+		}
+		l.keyCond.Wait()
+	}
+	for _, k := range keys {
+		l.inflight[k] = true
+	}
+	return nil
+}
+
+func (l *loader) release(keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+	l.keyMu.Lock()
+	for _, k := range keys {
+		delete(l.inflight, k)
+	}
+	l.keyMu.Unlock()
+	l.keyCond.Broadcast()
+}
+
+func csvRows(r io.Reader) (func() (loadRow, error), error) {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err != nil {
+		return nil, err
+	}
+	line := 1
+	return func() (loadRow, error) {
+		rec, err := cr.Read()
+		if err != nil {
+			return loadRow{}, err
+		}
+		line++
+		row := loadRow{row: line, values: map[string]interface{}{}}
+		for i, col := range header {
+			// 空单元格视为未设置
+			if rec[i] != "" {
+				row.values[strings.TrimSpace(col)] = rec[i]
+			}
+		}
+		return row, nil
+	}, nil
+}
+
+func jsonlRows(r io.Reader) func() (loadRow, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+	return func() (loadRow, error) {
+		for sc.Scan() {
+			line++
+			bs := bytes.TrimSpace(sc.Bytes())
+			if len(bs) == 0 {
+				continue
+			}
+			d := json.NewDecoder(bytes.NewReader(bs))
+			d.UseNumber()
+			row := loadRow{row: line}
+			if err := d.Decode(&row.values); err != nil {
+				return row, errors.New(fmt.Sprintf("row %d: %s", line, err.Error()))
+			}
+			return row, nil
+		}
+		if err := sc.Err(); err != nil {
+			return loadRow{}, err
+		}
+		return loadRow{}, io.EOF
+	}
+}
+
+// parseRefs 被引用类型必须有dtype标签与唯一的id字段
+func (l *loader) parseRefs() error {
+	for name, sample := range l.opts.Refs {
+		i := l.findField(name)
+		if i < 0 {
+			return errors.New("ref field " + name + " not found")
+		}
+		if fieldDt(l.tp.Field(i)) != TypeUid {
+			return errors.New("ref field " + name + " is not uid type")
+		}
+		m, err := newMutation(sample)
+		if err != nil {
+			return err
+		}
+		keys, err := m.upsertKeys(nil)
+		if err != nil {
+			return errors.New("ref type " + m.Dtype + " has no id field")
+		}
+		l.refs[i] = loadRef{dtype: m.Dtype, pred: fieldPred(keys[0])}
+	}
+	return nil
+}
+
+func fieldDt(f reflect.StructField) string {
+	tags := strings.Split(f.Tag.Get(TagDb), ",")
+	if len(tags) < 2 {
+		return ""
+	}
+	return tags[1]
+}
+
+// field 列对应的字段序号
+func (l *loader) field(col string) (int, error) {
+	l.fieldMu.Lock()
+	defer l.fieldMu.Unlock()
+	if i, ok := l.fields[col]; ok {
+		return i, nil
+	}
+	name := col
+	if mapped, ok := l.opts.Columns[col]; ok {
+		name = mapped
+	}
+	if name == "-" {
+		l.fields[col] = -1
+		return -1, nil
+	}
+	i := l.findField(name)
+	if i < 0 {
+		return -1, errors.New("no field for column " + col)
+	}
+	l.fields[col] = i
+	return i, nil
+}
+
+// findField 字段名、json名或谓词名为name的带db标签字段,不存在时返回-1
+func (l *loader) findField(name string) int {
+	for i := 0; i < l.tp.NumField(); i++ {
+		f := l.tp.Field(i)
+		if f.Name != Uid && f.Tag.Get(TagDb) != "" && matchField(f, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// load 解析一批记录,查询引用的节点,在一个事务中写入
+func (l *loader) load(ctx context.Context, b loadBatch) error {
+	var (
+		objs = make([]reflect.Value, len(b.rows))
+		refs = make([]map[int]interface{}, len(b.rows))
+	)
+	for i, row := range b.rows {
+		obj, ref, err := l.decode(row)
+		if err != nil {
+			return errors.New(fmt.Sprintf("row %d: %s", row.row, err.Error()))
+		}
+		objs[i], refs[i] = obj, ref
+	}
+	if err := l.resolve(ctx, b.rows, objs, refs); err != nil {
+		return err
+	}
+	env := buildEnv{actor: ActorFrom(ctx), now: l.client.now()}
+	reqs := make([]*api.Request, len(objs))
+	for i, obj := range objs {
+		var err error
+		if l.upsert {
+			reqs[i], _, err = buildUpsert(obj.Addr().Interface(), env, false, nil)
+		} else {
+			reqs[i], err = build(obj.Addr().Interface(), nil, env, (*mutation).MakeAdd)
+		}
+		if err != nil {
+			return errors.New(fmt.Sprintf("row %d: %s", b.rows[i].row, err.Error()))
+		}
+	}
+	retries, err := l.write(ctx, reqs)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.progress.Retries += retries
+	if err != nil {
+		return errors.New(fmt.Sprintf("batch from row %d: %s", b.rows[0].row, err.Error()))
+	}
+	l.progress.Records += len(objs)
+	l.progress.Batches++
+	l.done[b.seq] = b.hash
+	if err = l.writeCheckpoint(); err != nil {
+		return err
+	}
+	if l.opts.Progress != nil {
+		l.opts.Progress(l.progress)
+	}
+	return nil
+}
+
+// decode 将一条记录转换为结构体,引用字段的值单独返回,在resolve中替换为uid
+func (l *loader) decode(row loadRow) (reflect.Value, map[int]interface{}, error) {
+	obj := reflect.New(l.tp).Elem()
+	refs := map[int]interface{}{}
+	for col, raw := range row.values {
+		i, err := l.field(col)
+		if err != nil {
+			return obj, nil, err
+		}
+		if i < 0 || raw == nil {
+			continue
+		}
+		f := l.tp.Field(i)
+		fv := obj.Field(i)
+		// csv中切片字段的多个值以ListSep分隔
+		if s, ok := raw.(string); ok && fv.Kind() == reflect.Slice && !isScalar(fv.Type()) {
+			var list []interface{}
+			for _, e := range strings.Split(s, l.opts.ListSep) {
+				if e = strings.TrimSpace(e); e != "" {
+					list = append(list, e)
+				}
+			}
+			raw = list
+		}
+		if _, ok := l.refs[i]; ok {
+			refs[i] = raw
+			continue
+		}
+		v, err := coerce(fieldDt(f), raw)
+		if err != nil {
+			return obj, nil, errors.New(col + ": " + err.Error())
+		}
+		if err = assign(fv, v); err != nil {
+			return obj, nil, errors.New(col + ": " + err.Error())
+		}
+	}
+	return obj, refs, nil
+}
+
+// coerce 将文本或json的值按dgraph数据类型转换,转换后的值由assign写入字段
+// 写入时再经过typeNqTypeMap的检查
+func coerce(dt string, raw interface{}) (interface{}, error) {
+	switch v := raw.(type) {
+	case []interface{}:
+		r := make([]interface{}, len(v))
+		for i, e := range v {
+			c, err := coerce(dt, e)
+			if err != nil {
+				return nil, err
+			}
+			r[i] = c
+		}
+		return r, nil
+	case json.Number:
+		raw = v.String()
+	case bool:
+		raw = strconv.FormatBool(v)
+	case string:
+	default:
+		// geo等对象原样写入
+		return raw, nil
+	}
+	s := strings.TrimSpace(raw.(string))
+	switch dt {
+	case TypeInt:
+		return strconv.ParseInt(s, 10, 64)
+	case TypeFloat:
+		return strconv.ParseFloat(s, 64)
+	case TypeBool:
+		return strconv.ParseBool(s)
+	case TypeDateTime:
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02", "2006/01/02"} {
+			if t, err := time.Parse(layout, s); err == nil {
+				return t.Format(time.RFC3339Nano), nil
+			}
+		}
+		return nil, errors.New("invalid datetime " + s)
+	case TypeGeo:
+		var g interface{}
+		if err := json.Unmarshal([]byte(s), &g); err != nil {
+			return nil, err
+		}
+		return g, nil
+	}
+	return raw, nil
+}
+
+// resolve 按被引用类型的id谓词查询一批记录引用的节点,写入uid字段
+func (l *loader) resolve(ctx context.Context, rows []loadRow, objs []reflect.Value, refs []map[int]interface{}) error {
+	var (
+		blocks []string
+		names  = map[string]string{}
+	)
+	key := func(i int, v interface{}) string {
+		return fmt.Sprintf("%d\x00%v", i, v)
+	}
+	for _, ref := range refs {
+		for i, raw := range ref {
+			list, ok := raw.([]interface{})
+			if !ok {
+				list = []interface{}{raw}
+			}
+			for _, v := range list {
+				k := key(i, v)
+				if _, ok := names[k]; ok {
+					continue
+				}
+				name := fmt.Sprintf("r%d", len(names))
+				names[k] = name
+				r := l.refs[i]
+				blocks = append(blocks, fmt.Sprintf("%s(func: eq(%s, %s)) @filter(type(%s)) { uid }",
+					name, r.pred, strconv.Quote(fmt.Sprint(v)), r.dtype))
+			}
+		}
+	}
+	if len(blocks) == 0 {
+		return nil
+	}
+	txn := l.client.Txn(true).WithContext(ctx)
+	defer txn.Cancel()
+	resp, err := txn.Txn.Query(txn.Ctx(), "{ "+strings.Join(blocks, " ")+" }")
+	if err != nil {
+		return err
+	}
+	var res map[string][]uidNode
+	if err = json.Unmarshal(resp.Json, &res); err != nil {
+		return err
+	}
+	for n, ref := range refs {
+		for i, raw := range ref {
+			list, isList := raw.([]interface{})
+			if !isList {
+				list = []interface{}{raw}
+			}
+			var uids []interface{}
+			for _, v := range list {
+				found := res[names[key(i, v)]]
+				if len(found) != 1 {
+					return errors.New(fmt.Sprintf("row %d: %s %v matched %d nodes of %s",
+						rows[n].row, l.tp.Field(i).Name, v, len(found), l.refs[i].dtype))
+				}
+				uids = append(uids, found[0].Uid)
+			}
+			var v interface{} = uids
+			if !isList {
+				v = uids[0]
+			}
+			if err = assign(objs[n].Field(i), v); err != nil {
+				return errors.New(fmt.Sprintf("row %d: %s", rows[n].row, err.Error()))
+			}
+		}
+	}
+	return nil
+}
+
+// write 在一个事务中发送一批请求,事务冲突或节点不可用时重试,返回重试的次数
+func (l *loader) write(ctx context.Context, reqs []*api.Request) (int, error) {
+	var err error
+	for i := 0; ; i++ {
+		if err = l.commit(ctx, reqs); err == nil || i >= l.opts.Retries || !isTransient(err) {
+			return i, err
+		}
+		select {
+		case <-time.After(loadRetryBackoff * time.Duration(i+1)):
+		case <-ctx.Done():
+			return i, ctx.Err()
+		}
+	}
+}
+
+func (l *loader) commit(ctx context.Context, reqs []*api.Request) error {
+	txn := l.client.Txn().WithContext(ctx)
+	defer txn.Cancel()
+	for _, req := range reqs {
+		if _, err := txn.do(req); err != nil {
+			txn.Txn.Discard(txn.Ctx())
+			return err
+		}
+	}
+	return txn.Txn.Commit(txn.Ctx())
+}
+
+// isTransient 事务冲突与节点不可用可以重试
+func isTransient(err error) bool {
+	if errors.Is(err, dgo.ErrAborted) {
+		return true
+	}
+	switch status.Code(err) {
+	case codes.Aborted, codes.Unavailable:
+		return true
+	}
+	return false
+}
+
+// checkpoint 断点文件的内容,输入文件或批次大小不同时无法继续
+// Done 为已提交批次的序号到内容摘要的映射
+type checkpoint struct {
+	Source    string         `json:"source,omitempty"`
+	BatchSize int            `json:"batch_size"`
+	Done      map[int]string `json:"done"`
+}
+
+func (l *loader) readCheckpoint() error {
+	if l.opts.Checkpoint == "" {
+		return nil
+	}
+	bs, err := ioutil.ReadFile(l.opts.Checkpoint)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var cp checkpoint
+	if err = json.Unmarshal(bs, &cp); err != nil {
+		return errors.New("invalid checkpoint file: " + err.Error())
+	}
+	if cp.Source != l.source {
+		return errors.New(fmt.Sprintf("checkpoint source %q differs from %q", cp.Source, l.source))
+	}
+	if cp.BatchSize != l.opts.BatchSize {
+		return errors.New(fmt.Sprintf("checkpoint batch size %d differs from %d", cp.BatchSize, l.opts.BatchSize))
+	}
+	for seq, hash := range cp.Done {
+		l.done[seq] = hash
+	}
+	return nil
+}
+
+// writeCheckpoint 先写临时文件再改名,中断时不会留下不完整的断点文件
+func (l *loader) writeCheckpoint() error {
+	if l.opts.Checkpoint == "" {
+		return nil
+	}
+	cp := checkpoint{Source: l.source, BatchSize: l.opts.BatchSize, Done: l.done}
+	bs, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	tmp := l.opts.Checkpoint + ".tmp"
+	if err = ioutil.WriteFile(tmp, bs, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, l.opts.Checkpoint)
+}
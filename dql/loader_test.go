@@ -0,0 +1,263 @@
+/**
+ * @Author: daipengyuan
+ * @Description:
+ * @File:  loader_test
+ * @Version: 1.0.0
+ * @Date: 2026/10/20 06:30
+ */
+
+package dql_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/golang-common/dglib/dql"
+	"github.com/golang-common/dglib/dql/dqltest"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+type customer struct {
+	Uid    string    `json:"uid" db:"uid,string" dtype:"Customer"`
+	Email  string    `json:"email" db:"email,string,id"`
+	Name   string    `json:"name" db:"name,string"`
+	Age    int       `json:"age" db:"age,int"`
+	Vip    bool      `json:"vip" db:"vip,bool"`
+	Joined time.Time `json:"joined" db:"joined,datetime"`
+	Tags   []string  `json:"tags" db:"tags,string"`
+}
+
+type shipment struct {
+	Uid      string   `json:"uid" db:"uid,string" dtype:"Shipment"`
+	Code     string   `json:"code" db:"code,string"`
+	Weight   float64  `json:"weight" db:"weight,float"`
+	Owner    string   `json:"owner" db:"owner,uid"`
+	Watchers []string `json:"watchers" db:"watchers,uid"`
+}
+
+const customerCSV = `E-Mail,Full Name,age,vip,joined,tags,Notes
+a@x.com,Alice,30,true,2020-01-02,red;blue,first
+b@x.com,Bob,41,false,2021-03-04 05:06:07,,
+c@x.com,"Carol, Jr.",25,true,2022-05-06T07:08:09Z,green,
+d@x.com,Dan,,,,,
+e@x.com,Eve,52,false,,,
+`
+
+func loaderClient(t *testing.T) *dql.Client {
+	c := dqltest.NewClient(t)
+	for _, p := range []dql.Pred{
+		{Predicate: "email", Type: "string", Index: true, Tokenizer: []string{"exact"}, Upsert: true},
+		{Predicate: "tags", Type: "string", List: true},
+		{Predicate: "owner", Type: "uid"},
+		{Predicate: "watchers", Type: "uid", List: true},
+	} {
+		if err := c.SetPred(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return c
+}
+
+func customers(t *testing.T, c *dql.Client) map[string]customer {
+	t.Helper()
+	var list []customer
+	if err := c.Txn(true).List(&list, nil); err != nil {
+		t.Fatal(err)
+	}
+	r := map[string]customer{}
+	for _, cu := range list {
+		if _, ok := r[cu.Email]; ok {
+			t.Fatalf("duplicate customer %s", cu.Email)
+		}
+		r[cu.Email] = cu
+	}
+	return r
+}
+
+func TestLoad(t *testing.T) {
+	ctx := context.Background()
+	c := loaderClient(t)
+	opts := dql.LoadOptions{
+		Format:    dql.LoadCSV,
+		Columns:   map[string]string{"E-Mail": "email", "Full Name": "Name", "Notes": "-"},
+		BatchSize: 2,
+		Workers:   2,
+	}
+	var calls int
+	opts.Progress = func(dql.LoadProgress) { calls++ }
+	p, err := dql.Load(ctx, c, customer{}, strings.NewReader(customerCSV), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Records != 5 || p.Batches != 3 || calls != 3 {
+		t.Fatalf("progress %+v, %d calls", p, calls)
+	}
+	got := customers(t, c)
+	alice := got["a@x.com"]
+	if alice.Name != "Alice" || alice.Age != 30 || !alice.Vip || !alice.Joined.Equal(time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("alice %+v", alice)
+	}
+	sort.Strings(alice.Tags)
+	if !reflect.DeepEqual(alice.Tags, []string{"blue", "red"}) {
+		t.Fatalf("alice tags %v", alice.Tags)
+	}
+	if got["c@x.com"].Name != "Carol, Jr." || !got["b@x.com"].Joined.Equal(time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)) {
+		t.Fatalf("customers %+v", got)
+	}
+
+	// 有id字段时按id更新,重复加载不产生重复节点
+	opts.Progress = nil
+	if _, err = dql.Load(ctx, c, customer{}, strings.NewReader(strings.Replace(customerCSV, "Eve,52", "Eve,53", 1)), opts); err != nil {
+		t.Fatal(err)
+	}
+	got = customers(t, c)
+	if len(got) != 5 || got["e@x.com"].Age != 53 {
+		t.Fatalf("reload %+v", got)
+	}
+
+	// 引用按被引用类型的id字段查找
+	shipments := `{"code": "S1", "weight": 1.5, "owner": "a@x.com", "watchers": ["b@x.com", "c@x.com"]}
+
+{"code": "S2", "weight": "2", "owner": "b@x.com"}
+`
+	p, err = dql.Load(ctx, c, shipment{}, strings.NewReader(shipments), dql.LoadOptions{
+		Format: dql.LoadJSONL,
+		Refs:   map[string]interface{}{"Owner": customer{}, "watchers": customer{}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res struct {
+		Q []struct {
+			Code   string  `json:"code"`
+			Weight float64 `json:"weight"`
+			Owner  struct {
+				Email string `json:"email"`
+			} `json:"owner"`
+			Watchers []struct {
+				Email string `json:"email"`
+			} `json:"watchers"`
+		} `json:"q"`
+	}
+	q := `{ q(func: type(Shipment), orderasc: code) { code weight owner { email } watchers(orderasc: email) { email } } }`
+	if err = c.Txn(true).UnmashalQueryStr(q, &res); err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Q) != 2 || res.Q[0].Owner.Email != "a@x.com" || len(res.Q[0].Watchers) != 2 ||
+		res.Q[0].Watchers[1].Email != "c@x.com" || res.Q[1].Weight != 2 || res.Q[1].Owner.Email != "b@x.com" {
+		t.Fatalf("shipments %+v", res.Q)
+	}
+
+	for _, tc := range []struct {
+		data string
+		opts dql.LoadOptions
+		err  string
+	}{
+		{"email,phone\nx@x.com,1\n", dql.LoadOptions{Format: dql.LoadCSV}, "no field for column phone"},
+		{"email,age\nx@x.com,1\ny@x.com,abc\n", dql.LoadOptions{Format: dql.LoadCSV}, "row 3: age"},
+		{`{"code": "S3", "owner": "nobody@x.com"}`, dql.LoadOptions{Format: dql.LoadJSONL, Refs: map[string]interface{}{"owner": customer{}}}, "row 1: Owner nobody@x.com matched 0 nodes"},
+		{"", dql.LoadOptions{Format: "xml"}, "unsupported load format"},
+	} {
+		obj := interface{}(customer{})
+		if tc.opts.Refs != nil {
+			obj = shipment{}
+		}
+		_, err := dql.Load(ctx, c, obj, strings.NewReader(tc.data), tc.opts)
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Fatalf("expected error %q, got %v", tc.err, err)
+		}
+	}
+}
+
+func TestLoad_Checkpoint(t *testing.T) {
+	ctx := context.Background()
+	c := loaderClient(t)
+	cp := filepath.Join(t.TempDir(), "customers.checkpoint")
+	opts := dql.LoadOptions{Format: dql.LoadCSV, BatchSize: 2, Workers: 1, Checkpoint: cp,
+		Columns: map[string]string{"E-Mail": "email", "Full Name": "name", "Notes": "-"}}
+	// 第三批的年龄有误,前两批已提交
+	bad := strings.Replace(customerCSV, "Eve,52", "Eve,old", 1)
+	if _, err := dql.Load(ctx, c, customer{}, strings.NewReader(bad), opts); err == nil || !strings.Contains(err.Error(), "row 6") {
+		t.Fatalf("expected error at row 6, got %v", err)
+	}
+	bs, err := ioutil.ReadFile(cp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved struct {
+		BatchSize int               `json:"batch_size"`
+		Done      map[string]string `json:"done"`
+	}
+	if err = json.Unmarshal(bs, &saved); err != nil || saved.BatchSize != 2 || len(saved.Done) != 2 || saved.Done["0"] == "" || saved.Done["1"] == "" {
+		t.Fatalf("checkpoint %s", bs)
+	}
+	if len(customers(t, c)) != 4 {
+		t.Fatal("expected the first two batches committed")
+	}
+
+	// 批次大小不同时不能继续
+	other := opts
+	other.BatchSize = 3
+	if _, err = dql.Load(ctx, c, customer{}, strings.NewReader(customerCSV), other); err == nil {
+		t.Fatal("expected batch size mismatch")
+	}
+	// 已完成批次的内容不同时不能继续
+	changed := strings.Replace(customerCSV, "Bob,41", "Bob,42", 1)
+	if _, err = dql.Load(ctx, c, customer{}, strings.NewReader(changed), opts); err == nil || !strings.Contains(err.Error(), "differs from the checkpoint") {
+		t.Fatalf("expected content mismatch, got %v", err)
+	}
+	// 输入文件不同时不能继续
+	path := filepath.Join(t.TempDir(), "customers.csv")
+	if err = ioutil.WriteFile(path, []byte(customerCSV), 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err = dql.Load(ctx, c, customer{}, f, opts); err == nil || !strings.Contains(err.Error(), "source") {
+		t.Fatalf("expected source mismatch, got %v", err)
+	}
+
+	p, err := dql.Load(ctx, c, customer{}, strings.NewReader(customerCSV), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Skipped != 4 || p.Records != 1 || p.Batches != 1 {
+		t.Fatalf("resume progress %+v", p)
+	}
+	if _, err = os.Stat(cp); !os.IsNotExist(err) {
+		t.Fatal("checkpoint must be removed after success")
+	}
+	if got := customers(t, c); len(got) != 5 || got["e@x.com"].Age != 52 {
+		t.Fatalf("customers %+v", got)
+	}
+}
+
+// id相同的记录分在不同批次并行写入时,按文件顺序写入同一个节点
+func TestLoad_SameIdParallel(t *testing.T) {
+	c := dqltest.NewClient(t)
+	// email没有@upsert,dgraph不会检测并行事务之间的冲突
+	if err := c.SetPred(dql.Pred{Predicate: "email", Type: "string", Index: true, Tokenizer: []string{"exact"}}); err != nil {
+		t.Fatal(err)
+	}
+	data := "email,name\n"
+	for i := 0; i < 40; i++ {
+		data += fmt.Sprintf("dup@x.com,n%02d\n", i)
+	}
+	_, err := dql.Load(context.Background(), c, customer{}, strings.NewReader(data), dql.LoadOptions{Format: dql.LoadCSV, BatchSize: 1, Workers: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := customers(t, c); len(got) != 1 || got["dup@x.com"].Name != "n39" {
+		t.Fatalf("customers %+v", got)
+	}
+}
@@ -13,10 +13,10 @@ import (
 	"errors"
 	"fmt"
 	"github.com/dgraph-io/dgo/v200/protos/api"
-	uuid "github.com/satori/go.uuid"
 	"github.com/twpayne/go-geom"
 	"github.com/twpayne/go-geom/encoding/geojson"
 	"reflect"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -110,89 +110,67 @@ var (
 )
 
 func (d *Txn) Add(obj interface{}, facets ...*Facet) (*api.Response, error) {
-	muta, err := newMutation(obj, facets...)
+	req, err := build(obj, facets, d.env(), (*mutation).MakeAdd)
 	if err != nil {
 		return nil, err
 	}
-	req, err := muta.MakeAdd()
-	if err != nil {
-		return nil, err
-	}
-	fmt.Println(IndentJson(req))
-	defer d.Cancel()
-	resp, err := d.Txn.Do(d.Ctx(), req)
-	if err != nil {
-		return nil, err
-	}
-	return resp, nil
+	return d.do(req)
 }
 
 func (d *Txn) Update(obj interface{}, facets ...*Facet) (*api.Response, error) {
-	muta, err := newMutation(obj, facets...)
+	req, err := build(obj, facets, d.env(), (*mutation).MakeUpd)
 	if err != nil {
 		return nil, err
 	}
-	req, err := muta.MakeUpd()
-	if err != nil {
-		return nil, err
+	resp, err := d.do(req)
+	if err != nil || d.DryRun {
+		return resp, err
 	}
-	defer d.Cancel()
-	resp, err := d.Txn.Do(d.Ctx(), req)
-	if err != nil {
-		return nil, err
-	}
-	return resp, nil
+	return resp, checkVersion(obj, req, resp)
 }
 
 func (d *Txn) Merge(obj interface{}, facets ...*Facet) (*api.Response, error) {
-	muta, err := newMutation(obj, facets...)
+	req, err := build(obj, facets, d.env(), (*mutation).MakeMerge)
 	if err != nil {
 		return nil, err
 	}
-	req, err := muta.MakeMerge()
-	if err != nil {
-		return nil, err
-	}
-	defer d.Cancel()
-	resp, err := d.Txn.Do(d.Ctx(), req)
-	if err != nil {
-		return nil, err
+	resp, err := d.do(req)
+	if err != nil || d.DryRun {
+		return resp, err
 	}
-	return resp, nil
+	return resp, checkVersion(obj, req, resp)
 }
 
 func (d *Txn) Delete(obj interface{}, facets ...*Facet) (*api.Response, error) {
-	muta, err := newMutation(obj, facets...)
+	req, err := build(obj, facets, d.env(), (*mutation).MakeDelVal)
 	if err != nil {
 		return nil, err
 	}
-	req, err := muta.MakeDelVal()
-	if err != nil {
-		return nil, err
-	}
-	defer d.Cancel()
-	resp, err := d.Txn.Do(d.Ctx(), req)
-	if err != nil {
-		return nil, err
-	}
-	return resp, nil
+	return d.do(req)
 }
 
 func (d *Txn) DelNode(obj interface{}, facets ...*Facet) (*api.Response, error) {
-	muta, err := newMutation(obj, facets...)
+	req, err := build(obj, facets, d.env(), (*mutation).MakeDelNode)
 	if err != nil {
 		return nil, err
 	}
-	req, err := muta.MakeDelNode()
-	if err != nil {
-		return nil, err
+	return d.do(req)
+}
+
+// do 发送变更请求,DryRun时只记录解析结果,JSON时以json变更发送
+func (d *Txn) do(req *api.Request) (*api.Response, error) {
+	sent := req
+	if d.JSON {
+		var err error
+		if sent, err = ToJSONRequest(req); err != nil {
+			return nil, err
+		}
 	}
-	defer d.Cancel()
-	resp, err := d.Txn.Do(d.Ctx(), req)
-	if err != nil {
-		return nil, err
+	if d.DryRun {
+		return d.dryRun(req, sent), nil
 	}
-	return resp, nil
+	defer d.Cancel()
+	return d.Txn.Do(d.Ctx(), sent)
 }
 
 func newMutation(obj interface{}, facets ...*Facet) (*mutation, error) {
@@ -210,9 +188,16 @@ func newMutation(obj interface{}, facets ...*Facet) (*mutation, error) {
 	if dtype == "" {
 		return nil, errors.New("obj must have Uid field with dtype tag")
 	}
+	// 非指针对象复制一份,以便填充auto字段时不修改调用方的值
+	if !val.CanSet() {
+		cp := reflect.New(val.Type()).Elem()
+		cp.Set(val)
+		val = cp
+	}
 	mu := &mutation{
 		Subject: uid,
 		Dtype:   dtype,
+		SoftDel: SoftDeleteOf(val.Interface()),
 		Val:     val,
 		Facets:  facets,
 	}
@@ -225,6 +210,7 @@ type MuType int
 type mutation struct {
 	Subject    string
 	Dtype      string
+	SoftDel    string // 软删除谓词,为空时DelNode彻底删除节点
 	Val        reflect.Value
 	Facets     []*Facet
 	curName    string
@@ -233,9 +219,15 @@ type mutation struct {
 	curLang    string
 	curReverse bool
 	curMustSet bool
+	curVersion bool
+	curAuto    string
+	actor      string
+	now        time.Time
 	idSet      bool
 	idName     string
 	idVal      string
+	mask       FieldMask // Update/Merge的字段掩码,为空时处理全部字段
+	mode       writeMode // 写入字段值的方式,决定校验哪些字段
 }
 
 func (m *mutation) MakeAdd() (*api.Request, error) {
@@ -245,7 +237,10 @@ func (m *mutation) MakeAdd() (*api.Request, error) {
 		cond      string
 		setNquads []*api.NQuad
 	)
-	m.Subject = fmt.Sprintf("_:%s", uuid.NewV1().String())
+	if err := m.begin(writeAdd); err != nil {
+		return nil, err
+	}
+	m.Subject = fmt.Sprintf("_:%s", BlankNode())
 	setNquads = append(setNquads, &api.NQuad{
 		Subject:     m.Subject,
 		Predicate:   "dgraph.type",
@@ -275,15 +270,8 @@ func (m *mutation) MakeAdd() (*api.Request, error) {
 			continue
 		}
 		if m.idSet && m.idName == m.curName {
-			switch fv.Interface().(type) {
-			case string:
-				m.idVal = fv.Interface().(string)
-			case int:
-				m.idVal = fmt.Sprintf("%d", fv.Interface().(int))
-			case float64:
-				m.idVal = fmt.Sprintf("%f", fv.Interface().(float64))
-			default:
-				return nil, errors.New("unsupport id datatype")
+			if m.idVal, err = idString(m.curDt, fv); err != nil {
+				return nil, err
 			}
 		}
 		nql, err := m.setCurVal(fv)
@@ -310,7 +298,7 @@ func (m *mutation) MakeAdd() (*api.Request, error) {
 		rplc := strings.NewReplacer(
 			"$type", m.Dtype,
 			"$name", m.idName,
-			"$value", fmt.Sprintf("%v", m.idVal),
+			"$value", strconv.Quote(m.idVal),
 		)
 		q = rplc.Replace(model)
 		cond = `@if(eq(len(a),0))`
@@ -330,6 +318,9 @@ func (m *mutation) MakeUpd() (*api.Request, error) {
 		setNquad []*api.NQuad
 		delNquad []*api.NQuad
 	)
+	if err := m.begin(writeUpdate); err != nil {
+		return nil, err
+	}
 	if m.Subject == "" {
 		return nil, errors.New("subject must not nil in update mutation")
 	}
@@ -340,14 +331,16 @@ func (m *mutation) MakeUpd() (*api.Request, error) {
 		if f.Name == Uid {
 			continue
 		}
-		if f.Name == "Friend" {
-			fmt.Println(true)
+		// 掩码外的字段与未设置的指针字段保持库中的值
+		if !m.inMask(f) || (isNilPtr(fv) && !m.masked(f)) {
+			continue
 		}
 		err := m.parseTag(f.Tag)
 		if err != nil {
 			return nil, err
 		}
-		if strings.HasPrefix(m.curName, "~") {
+		// 反向谓词与版本谓词不直接写入,版本由withVersion处理,创建时间保留库中的值
+		if strings.HasPrefix(m.curName, "~") || m.curVersion || m.curAuto == AutoCreated {
 			continue
 		}
 		if fv.IsZero() {
@@ -359,15 +352,8 @@ func (m *mutation) MakeUpd() (*api.Request, error) {
 			}
 		}
 		if m.idSet && m.idName == m.curName {
-			switch fv.Interface().(type) {
-			case string:
-				m.idVal = fv.Interface().(string)
-			case int:
-				m.idVal = fmt.Sprintf("%d", fv.Interface().(int))
-			case float64:
-				m.idVal = fmt.Sprintf("%f", fv.Interface().(float64))
-			default:
-				return nil, errors.New("unsupport id datatype")
+			if m.idVal, err = idString(m.curDt, fv); err != nil {
+				return nil, err
 			}
 		}
 		delNql, err := m.delCurPred()
@@ -396,7 +382,7 @@ func (m *mutation) MakeUpd() (*api.Request, error) {
 		rplc := strings.NewReplacer(
 			"$type", m.Dtype,
 			"$name", m.idName,
-			"$value", fmt.Sprintf("%v", m.idVal),
+			"$value", strconv.Quote(m.idVal),
 			"$uid", m.Subject,
 		)
 		q = rplc.Replace(model)
@@ -406,7 +392,9 @@ func (m *mutation) MakeUpd() (*api.Request, error) {
 		Query:     q,
 		Mutations: []*api.Mutation{{Cond: cond, Set: setNquad, Del: delNquad}},
 	}
-	fmt.Println(IndentJson(req))
+	if err := m.withVersion(req); err != nil {
+		return nil, err
+	}
 	return req, nil
 }
 
@@ -416,7 +404,11 @@ func (m *mutation) MakeMerge() (*api.Request, error) {
 		q        string
 		cond     string
 		setNquad []*api.NQuad
+		delNquad []*api.NQuad
 	)
+	if err := m.begin(writeMerge); err != nil {
+		return nil, err
+	}
 	if m.Subject == "" {
 		return nil, errors.New("subject must not nil in update mutation")
 	}
@@ -427,26 +419,32 @@ func (m *mutation) MakeMerge() (*api.Request, error) {
 		if f.Name == Uid {
 			continue
 		}
-		if fv.IsZero() {
+		// 零值字段只有在掩码中显式列出时才写入
+		if !m.inMask(f) || (fv.IsZero() && !m.masked(f)) {
 			continue
 		}
 		err := m.parseTag(f.Tag)
 		if err != nil {
 			return nil, err
 		}
-		if strings.HasPrefix(m.curName, "~") {
+		if strings.HasPrefix(m.curName, "~") || m.curVersion || m.curAuto == AutoCreated {
+			continue
+		}
+		// 掩码中的nil指针删除该谓词
+		if isNilPtr(fv) {
+			if m.curMustSet || (m.idSet && m.idName == m.curName) {
+				return nil, errors.New(fmt.Sprintf("%s must have a value", m.curName))
+			}
+			delNql, err := m.delCurPred()
+			if err != nil {
+				return nil, err
+			}
+			delNquad = append(delNquad, delNql)
 			continue
 		}
 		if m.idSet && m.idName == m.curName {
-			switch fv.Interface().(type) {
-			case string:
-				m.idVal = fv.Interface().(string)
-			case int:
-				m.idVal = fmt.Sprintf("%d", fv.Interface().(int))
-			case float64:
-				m.idVal = fmt.Sprintf("%f", fv.Interface().(float64))
-			default:
-				return nil, errors.New("unsupport id datatype")
+			if m.idVal, err = idString(m.curDt, fv); err != nil {
+				return nil, err
 			}
 		}
 		setNql, err := m.setCurVal(fv)
@@ -470,7 +468,7 @@ func (m *mutation) MakeMerge() (*api.Request, error) {
 		rplc := strings.NewReplacer(
 			"$type", m.Dtype,
 			"$name", m.idName,
-			"$value", fmt.Sprintf("%v", m.idVal),
+			"$value", strconv.Quote(m.idVal),
 			"$uid", m.Subject,
 		)
 		q = rplc.Replace(model)
@@ -478,7 +476,10 @@ func (m *mutation) MakeMerge() (*api.Request, error) {
 	}
 	var req = &api.Request{
 		Query:     q,
-		Mutations: []*api.Mutation{{Cond: cond, Set: setNquad}},
+		Mutations: []*api.Mutation{{Cond: cond, Set: setNquad, Del: delNquad}},
+	}
+	if err := m.withVersion(req); err != nil {
+		return nil, err
 	}
 	return req, nil
 }
@@ -515,15 +516,8 @@ func (m *mutation) MakeDelVal() (*api.Request, error) {
 			if !m.Val.IsZero() {
 				return nil, errors.New("field with id set must not delete")
 			}
-			switch fv.Interface().(type) {
-			case string:
-				m.idVal = fv.Interface().(string)
-			case int:
-				m.idVal = fmt.Sprintf("%d", fv.Interface().(int))
-			case float64:
-				m.idVal = fmt.Sprintf("%f", fv.Interface().(float64))
-			default:
-				return nil, errors.New("unsupport id datatype")
+			if m.idVal, err = idString(m.curDt, fv); err != nil {
+				return nil, err
 			}
 		}
 		setNql, err := m.setCurVal(fv)
@@ -546,12 +540,21 @@ func (m *mutation) MakeDelVal() (*api.Request, error) {
 	return req, nil
 }
 
+// MakeDelNode 删除节点,类型开启软删除时只写入删除时间
 func (m *mutation) MakeDelNode() (*api.Request, error) {
+	if m.SoftDel != "" {
+		return m.makeSoftDel()
+	}
+	return m.MakePurge()
+}
+
+// MakePurge 彻底删除节点及指向它的反向边
+func (m *mutation) MakePurge() (*api.Request, error) {
 	var (
 		revList  []string
 		q        string
 		mul      = []*api.Mutation{{Del: []*api.NQuad{{Subject: m.Subject, Predicate: StarAll, ObjectValue: starNqVal}}}}
-		delModel = `query{ var(func: type($type)) {$reverse}}`
+		delModel = `query{ var(func: uid($subject)) {$reverse}}`
 	)
 	for i := 0; i < m.Val.NumField(); i++ {
 		f := m.Val.Type().Field(i)
@@ -579,7 +582,7 @@ func (m *mutation) MakeDelNode() (*api.Request, error) {
 			keyList = append(keyList, key)
 		}
 		rplc := strings.NewReplacer(
-			"$type", m.Dtype,
+			"$subject", m.Subject,
 			"$reverse", "\n"+strings.Join(rList, "\n"),
 		)
 		q = rplc.Replace(delModel)
@@ -587,11 +590,12 @@ func (m *mutation) MakeDelNode() (*api.Request, error) {
 			if k < len(valList) {
 				mu := &api.Mutation{
 					Cond: fmt.Sprintf("@if(gt(len(%s),0))", v),
+					// 只删除指向该节点的边,引用方指向其他节点的边保留
 					Del: []*api.NQuad{
 						{
-							Subject:     fmt.Sprintf("uid(%s)", v),
-							Predicate:   valList[k],
-							ObjectValue: starNqVal,
+							Subject:   fmt.Sprintf("uid(%s)", v),
+							Predicate: valList[k],
+							ObjectId:  m.Subject,
 						},
 					},
 				}
@@ -599,23 +603,23 @@ func (m *mutation) MakeDelNode() (*api.Request, error) {
 			}
 		}
 	}
+	// 级联删除owned谓词指向的子节点
+	if blocks, cmul := m.cascade(); blocks != "" {
+		q = appendBlock(q, blocks)
+		mul = append(mul, cmul...)
+	}
 	req := &api.Request{
 		Query:     q,
 		Mutations: mul,
 	}
-	fmt.Println(IndentJson(req))
 	return req, nil
 }
 
 func (m *mutation) setCurVal(val reflect.Value) ([]*api.NQuad, error) {
 	var r []*api.NQuad
-	fc, ok := typeNqTypeMap[m.curDt]
-	if !ok {
-		return nil, errors.New("error datatype " + m.Dtype)
-	}
-	if val.Kind() != reflect.Slice {
-		nqVal, nqobj, err := fc(val)
-		if err != nil {
+	if val.Kind() != reflect.Slice || isScalar(val.Type()) {
+		nqVal, nqobj, ok, err := toNqValue(m.curDt, val)
+		if err != nil || !ok {
 			return nil, err
 		}
 		nq := &api.NQuad{
@@ -628,11 +632,13 @@ func (m *mutation) setCurVal(val reflect.Value) ([]*api.NQuad, error) {
 		r = append(r, nq)
 	} else {
 		for i := 0; i < val.Len(); i++ {
-			v := val.Index(i)
-			nqVal, nqobj, err := fc(v)
+			nqVal, nqobj, ok, err := toNqValue(m.curDt, val.Index(i))
 			if err != nil {
 				return nil, err
 			}
+			if !ok {
+				continue
+			}
 			nq := &api.NQuad{
 				Subject:     m.Subject,
 				Predicate:   m.curPred,
@@ -678,6 +684,8 @@ func (m *mutation) parseTag(tag reflect.StructTag) error {
 	m.curPred = ""
 	m.curLang = ""
 	m.curMustSet = false
+	m.curVersion = false
+	m.curAuto = tag.Get(TagAuto)
 	dbtag := tag.Get(TagDb)
 	dbtagList := strings.Split(dbtag, ",")
 	if len(dbtagList) < 2 {
@@ -689,7 +697,7 @@ func (m *mutation) parseTag(tag reflect.StructTag) error {
 			m.curName = tg
 			m.curPred = tgList[0]
 			if len(tgList) > 1 {
-				m.curLang = tgList[2]
+				m.curLang = tgList[1]
 			}
 			continue
 		}
@@ -709,6 +717,9 @@ func (m *mutation) parseTag(tag reflect.StructTag) error {
 		if tg == tagMust {
 			m.curMustSet = true
 		}
+		if tg == tagVersion {
+			m.curVersion = true
+		}
 	}
 	if m.curName == "" || m.curPred == "" || m.curDt == "" {
 		return errors.New("get predicate name or datatype failed in tag ")
@@ -9,24 +9,27 @@
 package dql
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"strings"
 )
 
 // UnmashalQueryObj 将q解析为查询并执行，并将返回的JSON结果绑定到obj
+// 未设置q.SoftDelete与q.IncludeDeleted时，按obj中各查询块对应类型的softdelete标签排除已删除的节点
 func (d *Txn) UnmashalQueryObj(q Query, obj interface{}) error {
 	qString, err := q.Parse()
 	if err != nil {
 		return err
 	}
+	if q.SoftDelete == "" && !q.IncludeDeleted {
+		qString = softDeleteQuery(qString, obj)
+	}
 	defer d.Cancel()
 	resp, err := d.Txn.Query(d.Ctx(), qString)
 	if err != nil {
 		return err
 	}
-	err = json.Unmarshal(resp.Json, obj)
+	err = Unmarshal(resp.Json, obj)
 	if err != nil {
 		return err
 	}
@@ -34,13 +37,14 @@ func (d *Txn) UnmashalQueryObj(q Query, obj interface{}) error {
 }
 
 // UnmashalQueryStr 执行q查询，并将返回的JSON结果绑定到obj
+// 与UnmashalQueryObj一样排除obj中开启软删除的类型已删除的节点，需要读取已删除节点时使用UnmashalQueryObj并设置IncludeDeleted
 func (d *Txn) UnmashalQueryStr(q string, obj interface{}) error {
 	defer d.Cancel()
-	resp, err := d.Txn.Query(d.Ctx(), q)
+	resp, err := d.Txn.Query(d.Ctx(), softDeleteQuery(q, obj))
 	if err != nil {
 		return err
 	}
-	err = json.Unmarshal(resp.Json, obj)
+	err = Unmarshal(resp.Json, obj)
 	if err != nil {
 		return err
 	}
@@ -58,6 +62,9 @@ type Query struct {
 	RootFilter  *Filter           `json:"root_filter"`
 	PredFilter  map[string]Filter `json:"pred_filter"`  // 注意,key=谓词名
 	FacetFilter map[string]Filter `json:"facet_filter"` // 注意,key=谓词名
+	SoftDelete  string            `json:"soft_delete"`  // 软删除谓词,设置后所有根查询块排除已删除的节点,为空时由UnmashalQueryObj按结果类型的标签确定
+	// IncludeDeleted 为真时UnmashalQueryObj不自动排除软删除的节点,用于查找待恢复的节点
+	IncludeDeleted bool `json:"include_deleted"`
 }
 
 func (q Query) Parse() (string, error) {
@@ -104,19 +111,31 @@ func (q Query) Parse() (string, error) {
 		sorter = strings.Join(slist, ",")
 	}
 	if strings.Contains(r, rprootft) {
-		if q.RootFilter == nil {
+		if q.RootFilter == nil && q.SoftDelete == "" {
 			return "", errors.New("query has $rootfilter but rootfilter is nil")
 		}
-		rf, err := q.RootFilter.Parse()
-		if err != nil {
-			return "", err
+		if q.RootFilter != nil {
+			rf, err := q.RootFilter.Parse()
+			if err != nil {
+				return "", err
+			}
+			rootft = rf
+		}
+		if q.SoftDelete != "" {
+			if rootft == "" {
+				rootft = notDeleted(q.SoftDelete)
+			} else {
+				rootft = fmt.Sprintf("%s AND (%s)", notDeleted(q.SoftDelete), rootft)
+			}
 		}
-		rootft = rf
 	}
 	// 替换原查询文本
 	psrReplace := strings.NewReplacer(
 		rppager, pager, rpsorter, sorter, rprecurse, recurse, rprootft, rootft)
 	r = psrReplace.Replace(r)
+	if q.SoftDelete != "" {
+		r = withSoftDelete(r, func(string) string { return q.SoftDelete })
+	}
 	// 开始解析替换谓词过滤器,只能在uid类型的谓词上使用
 	for k, v := range q.PredFilter {
 		pred, ok := PredMap[k]
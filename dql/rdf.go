@@ -0,0 +1,573 @@
+/**
+ * @Author: daipengyuan
+ * @Description: 按类型导出节点为RDF N-Quad,以及导入RDF N-Quad
+ * @File:  rdf
+ * @Version: 1.0.0
+ * @Date: 2026/10/20 06:00
+ */
+
+package dql
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dgraph-io/dgo/v200/protos/api"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const importBatchSize = 1000
+
+// exportPageSize 导出时每次查询的节点数量
+var exportPageSize = 1000
+
+// ExportRDF 按uid分页读取types中各类型的节点,以RDF N-Quad写入w
+//
+//	节点的uid写为空白节点_:0x1,导入到其他环境时创建新节点;边指向未导出的节点时,导入后为只有uid的空节点
+//	导出值的类型、语言与facet;带语言的谓词通过pred@*读取,其值不导出facet
+//	反向谓词与密码不导出,同时属于多个类型的节点只导出一次
+func ExportRDF(ctx context.Context, client *Client, types []string, w io.Writer) error {
+	if len(types) == 0 {
+		return errors.New("no type to export")
+	}
+	txn := client.Txn(true).WithContext(ctx)
+	schema, err := txn.GetSchema()
+	if err != nil {
+		return err
+	}
+	preds := map[string]Pred{}
+	for _, p := range schema.Preds {
+		preds[p.Predicate] = p
+	}
+	bw := bufio.NewWriter(w)
+	seen := map[string]bool{}
+	for _, name := range types {
+		var tp *Type
+		for i := range schema.Types {
+			if schema.Types[i].Name == name {
+				tp = &schema.Types[i]
+			}
+		}
+		if tp == nil {
+			return errors.New("type " + name + " not found in schema")
+		}
+		if err = exportType(ctx, txn, *tp, preds, seen, bw); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+func exportType(ctx context.Context, txn *Txn, tp Type, preds map[string]Pred, seen map[string]bool, w *bufio.Writer) error {
+	sel := []string{"uid", "dgraph.type"}
+	for _, f := range tp.Fields {
+		p, ok := preds[f.Name]
+		switch {
+		case strings.HasPrefix(f.Name, "~") || !ok || p.Type == TypePassword:
+		case p.Type == TypeUid:
+			sel = append(sel, f.Name+" @facets { uid }")
+		case p.Lang:
+			sel = append(sel, f.Name+"@*")
+		default:
+			sel = append(sel, f.Name+" @facets")
+		}
+	}
+	var after string
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		root := fmt.Sprintf("type(%s), first: %d", tp.Name, exportPageSize)
+		if after != "" {
+			root += ", after: " + after
+		}
+		nodes, err := exportQuery(txn, fmt.Sprintf("{ q(func: %s) { %s } }", root, strings.Join(sel, " ")))
+		if err != nil {
+			return err
+		}
+		for _, n := range nodes {
+			uid, _ := n["uid"].(string)
+			after = uid
+			if seen[uid] {
+				continue
+			}
+			seen[uid] = true
+			nqs, err := exportNquads(n, preds)
+			if err != nil {
+				return errors.New(fmt.Sprintf("node %s: %s", uid, err.Error()))
+			}
+			if _, err = w.WriteString(RDF(nqs)); err != nil {
+				return err
+			}
+		}
+		if len(nodes) < exportPageSize {
+			return nil
+		}
+	}
+}
+
+// exportQuery 数字保留为json.Number,以免大整数丢失精度
+func exportQuery(txn *Txn, q string) ([]map[string]interface{}, error) {
+	defer txn.Cancel()
+	resp, err := txn.Txn.Query(txn.Ctx(), q)
+	if err != nil {
+		return nil, err
+	}
+	var res struct {
+		Q []map[string]interface{} `json:"q"`
+	}
+	d := json.NewDecoder(bytes.NewReader(resp.Json))
+	d.UseNumber()
+	if err = d.Decode(&res); err != nil {
+		return nil, err
+	}
+	return res.Q, nil
+}
+
+// exportNquads 将查询返回的一个节点转换为nquad,谓词按名称排序
+func exportNquads(n map[string]interface{}, preds map[string]Pred) ([]*api.NQuad, error) {
+	subject := "_:" + n["uid"].(string)
+	keys := make([]string, 0, len(n))
+	for k := range n {
+		if k != "uid" && !strings.Contains(k, "|") {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	var r []*api.NQuad
+	for _, k := range keys {
+		pred, lang := k, ""
+		if i := strings.Index(k, "@"); i >= 0 {
+			pred, lang = k[:i], k[i+1:]
+		}
+		list, isList := n[k].([]interface{})
+		if !isList {
+			list = []interface{}{n[k]}
+		}
+		for i, v := range list {
+			nq := &api.NQuad{Subject: subject, Predicate: pred, Lang: lang}
+			var facets map[string]interface{}
+			if child, ok := v.(map[string]interface{}); ok && preds[pred].Type == TypeUid {
+				nq.ObjectId = "_:" + child["uid"].(string)
+				facets = facetsOf(child, pred, -1)
+			} else {
+				val, err := exportValue(preds[pred].Type, v)
+				if err != nil {
+					return nil, errors.New(k + ": " + err.Error())
+				}
+				nq.ObjectValue = val
+				facets = facetsOf(n, k, i)
+			}
+			fks := make([]string, 0, len(facets))
+			for fk := range facets {
+				fks = append(fks, fk)
+			}
+			sort.Strings(fks)
+			for _, fk := range fks {
+				f, err := exportFacet(fk, facets[fk])
+				if err != nil {
+					return nil, errors.New(k + ": " + err.Error())
+				}
+				nq.Facets = append(nq.Facets, f)
+			}
+			r = append(r, nq)
+		}
+	}
+	return r, nil
+}
+
+// facetsOf 取出o中key的facet,列表值的facet为{"序号":值},idx小于0时不按序号取
+func facetsOf(o map[string]interface{}, key string, idx int) map[string]interface{} {
+	r := map[string]interface{}{}
+	for k, v := range o {
+		if !strings.HasPrefix(k, key+"|") {
+			continue
+		}
+		if m, ok := v.(map[string]interface{}); ok && idx >= 0 {
+			if v, ok = m[strconv.Itoa(idx)]; !ok {
+				continue
+			}
+		}
+		r[k[len(key)+1:]] = v
+	}
+	return r
+}
+
+// exportValue 按schema类型将查询返回的值转换为api.Value
+func exportValue(tp string, v interface{}) (*api.Value, error) {
+	switch tp {
+	case TypeInt:
+		n, ok := v.(json.Number)
+		if !ok {
+			break
+		}
+		i, err := n.Int64()
+		if err != nil {
+			return nil, err
+		}
+		return &api.Value{Val: &api.Value_IntVal{IntVal: i}}, nil
+	case TypeFloat:
+		n, ok := v.(json.Number)
+		if !ok {
+			break
+		}
+		f, err := n.Float64()
+		if err != nil {
+			return nil, err
+		}
+		return &api.Value{Val: &api.Value_DoubleVal{DoubleVal: f}}, nil
+	case TypeBool:
+		if b, ok := v.(bool); ok {
+			return &api.Value{Val: &api.Value_BoolVal{BoolVal: b}}, nil
+		}
+	case TypeDateTime:
+		s, ok := v.(string)
+		if !ok {
+			break
+		}
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return nil, err
+		}
+		bs, err := t.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		return &api.Value{Val: &api.Value_DatetimeVal{DatetimeVal: bs}}, nil
+	case TypeGeo:
+		bs, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		return &api.Value{Val: &api.Value_GeoVal{GeoVal: bs}}, nil
+	case TypeString:
+		if s, ok := v.(string); ok {
+			return &api.Value{Val: &api.Value_StrVal{StrVal: s}}, nil
+		}
+	default:
+		return &api.Value{Val: &api.Value_DefaultVal{DefaultVal: fmt.Sprint(v)}}, nil
+	}
+	return nil, errors.New(fmt.Sprintf("value %v is not %s", v, tp))
+}
+
+// exportFacet 查询返回的facet转换为api.Facet,能解析为时间的字符串视为datetime
+func exportFacet(key string, v interface{}) (*api.Facet, error) {
+	switch x := v.(type) {
+	case json.Number:
+		if _, err := x.Int64(); err == nil {
+			return &api.Facet{Key: key, Value: []byte(x.String()), ValType: api.Facet_INT}, nil
+		}
+		return &api.Facet{Key: key, Value: []byte(x.String()), ValType: api.Facet_FLOAT}, nil
+	case string:
+		if t, err := time.Parse(time.RFC3339Nano, x); err == nil {
+			return (&Facet{Key: key, Value: t}).parse()
+		}
+	}
+	return (&Facet{Key: key, Value: v}).parse()
+}
+
+// ImportOptions ImportRDF的选项
+// BatchSize 每个事务写入的nquad数量,为0时使用1000;Progress 每提交一批后回调
+type ImportOptions struct {
+	BatchSize int
+	Progress  func(ImportProgress)
+}
+
+// ImportProgress 导入进度,Lines为已读取的行数,NQuads为已写入的nquad数量
+type ImportProgress struct {
+	Lines   int
+	NQuads  int
+	Batches int
+}
+
+// ImportRDF 逐行解析r中的RDF N-Quad,分批以独立事务写入,返回空白节点名(不含_:)到新uid的映射
+//
+//	同一空白节点在后续批次中替换为已分配的uid,因此一个节点的三元组可以跨批次
+//	<0x1>形式的主语与宾语按已有节点写入;出错时已提交的批次不会回滚
+func ImportRDF(ctx context.Context, client *Client, r io.Reader, opts ImportOptions) (map[string]string, error) {
+	size := opts.BatchSize
+	if size <= 0 {
+		size = importBatchSize
+	}
+	var (
+		uids  = map[string]string{}
+		prog  ImportProgress
+		batch []*api.NQuad
+	)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		for _, nq := range batch {
+			nq.Subject = remapBlank(nq.Subject, uids)
+			nq.ObjectId = remapBlank(nq.ObjectId, uids)
+		}
+		txn := client.Txn().WithContext(ctx)
+		resp, err := txn.do(&api.Request{Mutations: []*api.Mutation{{Set: batch}}, CommitNow: true})
+		if err != nil {
+			return err
+		}
+		for k, v := range resp.Uids {
+			uids[k] = v
+		}
+		prog.NQuads += len(batch)
+		prog.Batches++
+		batch = nil
+		if opts.Progress != nil {
+			opts.Progress(prog)
+		}
+		return nil
+	}
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for sc.Scan() {
+		prog.Lines++
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		nq, err := ParseNquad(line)
+		if err != nil {
+			return uids, errors.New(fmt.Sprintf("line %d: %s", prog.Lines, err.Error()))
+		}
+		batch = append(batch, nq)
+		if len(batch) >= size {
+			if err = flush(); err != nil {
+				return uids, err
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return uids, err
+	}
+	return uids, flush()
+}
+
+func remapBlank(id string, uids map[string]string) string {
+	if strings.HasPrefix(id, "_:") {
+		if uid, ok := uids[id[2:]]; ok {
+			return uid
+		}
+	}
+	return id
+}
+
+// ParseNquad 解析一行RDF N-Quad,如
+//
+//	_:a <name> "Alice"@en (since=2006-01-02T15:04:05Z, close=true) .
+//	<0x1> <age> "18"^^<xs:int> .
+//
+// 宾语后的graph标签被忽略
+func ParseNquad(line string) (*api.NQuad, error) {
+	s := strings.TrimSpace(line)
+	subject, s, err := rdfTerm(s)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(subject, `"`) {
+		return nil, errors.New("subject must be node")
+	}
+	pred, s, err := rdfTerm(s)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(pred, "<") {
+		return nil, errors.New("predicate must be <name>")
+	}
+	nq := &api.NQuad{Subject: strings.Trim(subject, "<>"), Predicate: strings.Trim(pred, "<>")}
+	if !strings.HasPrefix(s, `"`) {
+		var obj string
+		if obj, s, err = rdfTerm(s); err != nil {
+			return nil, err
+		}
+		nq.ObjectId = strings.Trim(obj, "<>")
+	} else if s, err = rdfLiteral(s, nq); err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(s, "<") {
+		// graph标签
+		if _, s, err = rdfTerm(s); err != nil {
+			return nil, err
+		}
+	}
+	if strings.HasPrefix(s, "(") {
+		end := strings.LastIndex(s, ")")
+		if end < 0 {
+			return nil, errors.New("unterminated facets")
+		}
+		if nq.Facets, err = rdfFacets(s[1:end]); err != nil {
+			return nil, err
+		}
+		s = strings.TrimSpace(s[end+1:])
+	}
+	if s != "." {
+		return nil, errors.New("nquad must end with '.'")
+	}
+	return nq, nil
+}
+
+// rdfTerm 读取<iri>或_:blank,返回该项与剩余部分
+func rdfTerm(s string) (string, string, error) {
+	var end int
+	switch {
+	case strings.HasPrefix(s, "<"):
+		end = strings.Index(s, ">") + 1
+		if end == 0 {
+			return "", "", errors.New("unterminated iri")
+		}
+	case strings.HasPrefix(s, "_:"):
+		end = strings.IndexAny(s, " \t")
+		if end < 0 {
+			return "", "", errors.New("incomplete nquad")
+		}
+	default:
+		return "", "", errors.New("unexpected term " + s)
+	}
+	return s[:end], strings.TrimSpace(s[end:]), nil
+}
+
+// rdfLiteral 读取"值"@lang^^<类型>,写入nq的ObjectValue与Lang
+func rdfLiteral(s string, nq *api.NQuad) (string, error) {
+	end := 1
+	for ; end < len(s) && s[end] != '"'; end++ {
+		if s[end] == '\\' {
+			end++
+		}
+	}
+	if end >= len(s) {
+		return "", errors.New("unterminated literal")
+	}
+	lit, err := strconv.Unquote(s[:end+1])
+	if err != nil {
+		return "", err
+	}
+	s = s[end+1:]
+	if strings.HasPrefix(s, "@") {
+		i := strings.IndexAny(s, " \t^")
+		if i < 0 {
+			return "", errors.New("incomplete nquad")
+		}
+		nq.Lang, s = s[1:i], s[i:]
+	}
+	var tp string
+	if strings.HasPrefix(s, "^^<") {
+		i := strings.Index(s, ">")
+		if i < 0 {
+			return "", errors.New("unterminated datatype")
+		}
+		tp, s = s[3:i], s[i+1:]
+	}
+	if nq.ObjectValue, err = rdfTypedValue(lit, tp); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(s), nil
+}
+
+// rdfTypedValue 与rdfValue输出的类型对应
+func rdfTypedValue(s, tp string) (*api.Value, error) {
+	switch tp {
+	case "":
+		return &api.Value{Val: &api.Value_DefaultVal{DefaultVal: s}}, nil
+	case "xs:string":
+		return &api.Value{Val: &api.Value_StrVal{StrVal: s}}, nil
+	case "xs:int", "xs:integer":
+		i, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		return &api.Value{Val: &api.Value_IntVal{IntVal: i}}, nil
+	case "xs:float", "xs:double":
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, err
+		}
+		return &api.Value{Val: &api.Value_DoubleVal{DoubleVal: f}}, nil
+	case "xs:boolean":
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return nil, err
+		}
+		return &api.Value{Val: &api.Value_BoolVal{BoolVal: b}}, nil
+	case "xs:dateTime", "xs:date":
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			t, err = time.Parse("2006-01-02", s)
+		}
+		if err != nil {
+			return nil, err
+		}
+		bs, err := t.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		return &api.Value{Val: &api.Value_DatetimeVal{DatetimeVal: bs}}, nil
+	case "geo:geojson":
+		return &api.Value{Val: &api.Value_GeoVal{GeoVal: []byte(s)}}, nil
+	case "xs:password":
+		return &api.Value{Val: &api.Value_PasswordVal{PasswordVal: s}}, nil
+	}
+	return nil, errors.New("unsupported datatype " + tp)
+}
+
+// rdfFacets 解析k=v列表,字符串需加引号,未加引号的值为true/false时为bool,否则按int、float、datetime依次尝试
+func rdfFacets(s string) ([]*api.Facet, error) {
+	var (
+		r     []*api.Facet
+		parts []string
+		quote bool
+		start int
+	)
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && quote:
+			i++
+		case s[i] == '"':
+			quote = !quote
+		case s[i] == ',' && !quote:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	parts = append(parts, s[start:])
+	for _, p := range parts {
+		kv := strings.SplitN(p, "=", 2)
+		if len(kv) != 2 {
+			return nil, errors.New("invalid facet " + p)
+		}
+		f := &Facet{Key: strings.TrimSpace(kv[0])}
+		v := strings.TrimSpace(kv[1])
+		if strings.HasPrefix(v, `"`) {
+			str, err := strconv.Unquote(v)
+			if err != nil {
+				return nil, err
+			}
+			f.Value = str
+		} else if v == "true" || v == "false" {
+			f.Value = v == "true"
+		} else if _, err := strconv.ParseInt(v, 10, 64); err == nil {
+			r = append(r, &api.Facet{Key: f.Key, Value: []byte(v), ValType: api.Facet_INT})
+			continue
+		} else if _, err := strconv.ParseFloat(v, 64); err == nil {
+			// 保留原文,Facet.parse会截断精度
+			r = append(r, &api.Facet{Key: f.Key, Value: []byte(v), ValType: api.Facet_FLOAT})
+			continue
+		} else if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			f.Value = t
+		} else {
+			return nil, errors.New("invalid facet value " + v)
+		}
+		af, err := f.parse()
+		if err != nil {
+			return nil, err
+		}
+		r = append(r, af)
+	}
+	return r, nil
+}
@@ -0,0 +1,205 @@
+/**
+ * @Author: daipengyuan
+ * @Description:
+ * @File:  rdf_test
+ * @Version: 1.0.0
+ * @Date: 2026/10/20 06:00
+ */
+
+package dql_test
+
+import (
+	"bytes"
+	"context"
+	"github.com/dgraph-io/dgo/v200/protos/api"
+	"github.com/golang-common/dglib/dql"
+	"github.com/golang-common/dglib/dql/dqltest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+const rdfData = `# 两个Person、一个City,Secret不导出
+_:alice <dgraph.type> "Person" .
+_:alice <name> "Alice" .
+_:alice <nick> "Ali"@en .
+_:alice <nick> "Alí"@es .
+_:alice <age> "30"^^<xs:int> (verified=true) .
+_:alice <score> "9.5"^^<xs:float> .
+_:alice <born> "1990-01-02T03:04:05Z"^^<xs:dateTime> .
+_:alice <friend> _:bob (since=2019-05-01T00:00:00Z, weight=0.75, note="met at \"work\", again") .
+_:alice <lives> _:paris .
+_:bob <dgraph.type> "Person" .
+_:bob <name> "Bob" .
+_:bob <tags> "x" .
+_:bob <tags> "y" .
+_:paris <dgraph.type> "City" .
+_:paris <name> "Paris" .
+_:paris <loc> "{\"type\":\"Point\",\"coordinates\":[2.35,48.85]}"^^<geo:geojson> .
+_:s <dgraph.type> "Secret" .
+_:s <name> "hidden" .
+`
+
+func rdfClient(t *testing.T) *dql.Client {
+	c := dqltest.NewClient(t)
+	for _, p := range []dql.Pred{
+		{Predicate: "name", Type: "string", Index: true, Tokenizer: []string{"exact"}},
+		{Predicate: "nick", Type: "string", Lang: true},
+		{Predicate: "age", Type: "int"},
+		{Predicate: "score", Type: "float"},
+		{Predicate: "born", Type: "datetime"},
+		{Predicate: "friend", Type: "uid", List: true},
+		{Predicate: "lives", Type: "uid"},
+		{Predicate: "tags", Type: "string", List: true},
+		{Predicate: "loc", Type: "geo"},
+	} {
+		if err := c.SetPred(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, tp := range []dql.Type{
+		{Name: "Person", Fields: []dql.Field{{Name: "name"}, {Name: "nick"}, {Name: "age"}, {Name: "score"},
+			{Name: "born"}, {Name: "friend"}, {Name: "lives"}, {Name: "tags"}}},
+		{Name: "City", Fields: []dql.Field{{Name: "name"}, {Name: "loc"}}},
+		{Name: "Secret", Fields: []dql.Field{{Name: "name"}}},
+	} {
+		if err := c.SetType(tp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return c
+}
+
+// sortedLines 将空白节点按m改名后排序,用于比较两次导出的内容
+func sortedLines(s string, m map[string]string) []string {
+	for k, v := range m {
+		s = strings.ReplaceAll(s, "_:"+k+" ", "_:\x00"+v+" ")
+	}
+	s = strings.ReplaceAll(s, "\x00", "")
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	sort.Strings(lines)
+	return lines
+}
+
+func TestExportRDF(t *testing.T) {
+	ctx := context.Background()
+	src := rdfClient(t)
+	var progress []dql.ImportProgress
+	uids, err := dql.ImportRDF(ctx, src, strings.NewReader(rdfData), dql.ImportOptions{
+		BatchSize: 4,
+		Progress:  func(p dql.ImportProgress) { progress = append(progress, p) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 18条nquad分5批,跨批次的空白节点使用同一个节点
+	if len(progress) != 5 || progress[4] != (dql.ImportProgress{Lines: 19, NQuads: 18, Batches: 5}) {
+		t.Fatalf("progress %+v", progress)
+	}
+	if len(uids) != 4 {
+		t.Fatalf("blank nodes %v", uids)
+	}
+
+	var out bytes.Buffer
+	defer dql.SetExportPageSize(1)()
+	if err = dql.ExportRDF(ctx, src, []string{"Person", "City"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	dqltest.Golden(t, out.Bytes())
+	if strings.Contains(out.String(), "hidden") {
+		t.Fatal("type not in list must not be exported")
+	}
+
+	// 导入到另一个环境后再次导出,内容除uid外一致
+	dst := rdfClient(t)
+	moved, err := dql.ImportRDF(ctx, dst, bytes.NewReader(out.Bytes()), dql.ImportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var again bytes.Buffer
+	if err = dql.ExportRDF(ctx, dst, []string{"Person", "City"}, &again); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := sortedLines(again.String(), nil), sortedLines(out.String(), moved); !reflect.DeepEqual(got, want) {
+		t.Fatalf("round trip:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	if err = dql.ExportRDF(ctx, src, []string{"Nothing"}, &out); err == nil {
+		t.Fatal("unknown type must fail")
+	}
+}
+
+// 值为0、1的int facet导出再导入后仍为int
+func TestExportRDF_IntFacets(t *testing.T) {
+	ctx := context.Background()
+	data := `_:a <dgraph.type> "Person" .
+_:a <name> "A" .
+_:a <friend> _:b (n=0, m=1, ok=true) .
+_:b <dgraph.type> "Person" .
+_:b <name> "B" .
+`
+	src := rdfClient(t)
+	if _, err := dql.ImportRDF(ctx, src, strings.NewReader(data), dql.ImportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := dql.ExportRDF(ctx, src, []string{"Person"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "n=0") || !strings.Contains(out.String(), "m=1") || !strings.Contains(out.String(), "ok=true") {
+		t.Fatalf("facets changed on export:\n%s", out.String())
+	}
+	dst := rdfClient(t)
+	moved, err := dql.ImportRDF(ctx, dst, bytes.NewReader(out.Bytes()), dql.ImportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var again bytes.Buffer
+	if err = dql.ExportRDF(ctx, dst, []string{"Person"}, &again); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := sortedLines(again.String(), nil), sortedLines(out.String(), moved); !reflect.DeepEqual(got, want) {
+		t.Fatalf("round trip:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestParseNquad(t *testing.T) {
+	for _, line := range []string{
+		`_:a <name> "Alice"@en .`,
+		`<0x1> <age> "18"^^<xs:int> .`,
+		`_:a <friend> <0x2> (close=true, n=3) .`,
+		`_:a <note> "tab\there" <graph> .`,
+	} {
+		nq, err := dql.ParseNquad(line)
+		if err != nil {
+			t.Fatalf("%s: %v", line, err)
+		}
+		if got := strings.TrimSpace(dql.RDF([]*api.NQuad{nq})); got != strings.Replace(line, " <graph>", "", 1) {
+			t.Fatalf("%s parsed as %s", line, got)
+		}
+	}
+	for _, line := range []string{
+		`"a" <name> "b" .`,
+		`_:a name "b" .`,
+		`_:a <name> "b"`,
+		`_:a <name> "b .`,
+		`_:a <age> "x"^^<xs:int> .`,
+		`_:a <age> "1"^^<xs:unknown> .`,
+		`_:a <friend> _:b (k) .`,
+	} {
+		if _, err := dql.ParseNquad(line); err == nil {
+			t.Fatalf("%s must fail", line)
+		}
+	}
+	// 只有日期的xs:date
+	nq, err := dql.ParseNquad(`_:a <born> "2020-01-02"^^<xs:date> .`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var born time.Time
+	if err = born.UnmarshalBinary(nq.ObjectValue.GetDatetimeVal()); err != nil || !born.Equal(time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("date parsed as %v, %v", born, err)
+	}
+}
@@ -0,0 +1,99 @@
+/**
+ * @Author: daipengyuan
+ * @Description: 只读与尽力而为的查询,以及可在多个goroutine中共享的读快照
+ * @File:  snapshot
+ * @Version: 1.0.0
+ * @Date: 2026/10/20 07:30
+ */
+
+package dql
+
+import (
+	"context"
+	"errors"
+	"github.com/dgraph-io/dgo/v200/protos/api"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"strings"
+)
+
+// NewReadOnlyTxn 新建只读事务,与Txn(true)相同,查询优先发送到Config.ReadTargets中的节点
+func (d *Client) NewReadOnlyTxn() *Txn {
+	return d.Txn(true)
+}
+
+// BestEffort 只读事务的查询不向zero申请时间戳,使用alpha已知的最新时间戳,延迟更低但可能读不到刚提交的数据
+// 与dgo一致,非只读事务调用时panic
+func (d *Txn) BestEffort() *Txn {
+	d.Txn.BestEffort()
+	return d
+}
+
+// Snapshot 固定start_ts的只读视图,可以在多个goroutine中并发查询,各查询读取同一时刻的数据
+// 用于多个查询结果需要相互一致的报表
+type Snapshot struct {
+	client     *Client
+	startTs    uint64
+	bestEffort bool
+}
+
+// Snapshot 执行一次查询取得start_ts并返回读快照,bestEffort为真时使用尽力而为的时间戳
+func (d *Client) Snapshot(ctx context.Context, bestEffort bool) (*Snapshot, error) {
+	s := &Snapshot{client: d, bestEffort: bestEffort}
+	resp, err := s.Query(ctx, "{ q(func: uid(0x1)) { uid } }", nil)
+	if err != nil {
+		return nil, err
+	}
+	if s.startTs = resp.GetTxn().GetStartTs(); s.startTs == 0 {
+		return nil, errors.New("server returned no start_ts")
+	}
+	return s, nil
+}
+
+// StartTs 快照读取的时间戳
+func (s *Snapshot) StartTs() uint64 {
+	return s.startTs
+}
+
+// Query 在快照的时间戳上执行查询,vars为查询变量,可以为空
+func (s *Snapshot) Query(ctx context.Context, q string, vars map[string]string) (*api.Response, error) {
+	if s.client.optTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.client.optTimeout)
+		defer cancel()
+	}
+	req := &api.Request{Query: q, Vars: vars, StartTs: s.startTs, ReadOnly: true, BestEffort: s.bestEffort}
+	resp, err := s.client.pool.Query(s.jwtCtx(ctx), req)
+	if isJwtExpired(err) {
+		// 与dgo一样,令牌过期时重新登录后重试一次
+		if err = s.client.client.Relogin(ctx); err != nil {
+			return nil, err
+		}
+		resp, err = s.client.pool.Query(s.jwtCtx(ctx), req)
+	}
+	return resp, err
+}
+
+// UnmashalQuery 在快照的时间戳上执行查询并将结果解析到obj,与Txn.UnmashalQueryStr一样排除软删除的节点
+func (s *Snapshot) UnmashalQuery(ctx context.Context, q string, obj interface{}) error {
+	resp, err := s.Query(ctx, softDeleteQuery(q, obj), nil)
+	if err != nil {
+		return err
+	}
+	return Unmarshal(resp.Json, obj)
+}
+
+// jwtCtx 绕过dgo直接发送请求,需要自行附加登录得到的令牌
+func (s *Snapshot) jwtCtx(ctx context.Context) context.Context {
+	jwt := s.client.client.GetJwt()
+	if jwt.AccessJwt == "" {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, "accessJwt", jwt.AccessJwt)
+}
+
+func isJwtExpired(err error) bool {
+	st, ok := status.FromError(err)
+	return err != nil && ok && st.Code() == codes.Unauthenticated && strings.Contains(err.Error(), "Token is expired")
+}
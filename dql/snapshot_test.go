@@ -0,0 +1,95 @@
+/**
+ * @Author: daipengyuan
+ * @Description:
+ * @File:  snapshot_test
+ * @Version: 1.0.0
+ * @Date: 2026/10/20 07:30
+ */
+
+package dql_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/dgraph-io/dgo/v200/protos/api"
+	"github.com/golang-common/dglib/dql/dqltest"
+	"sync"
+	"testing"
+)
+
+func TestSnapshot(t *testing.T) {
+	c := dqltest.NewClient(t)
+	ctx := context.Background()
+	write := func(n string) {
+		t.Helper()
+		txn := c.Txn()
+		if _, err := txn.Txn.Mutate(txn.Ctx(), &api.Mutation{Set: []*api.NQuad{nq("_:n", "name", n)}, CommitNow: true}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	const q = `{ q(func: has(name)) { name } }`
+	write("a")
+	write("b")
+
+	snap, err := c.Snapshot(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snap.StartTs() == 0 {
+		t.Fatal("snapshot must have start ts")
+	}
+	write("c")
+
+	// 多个goroutine在同一时间戳上查询
+	var (
+		wg   sync.WaitGroup
+		errs = make(chan error, 8)
+	)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var res map[string][]struct {
+				Name string `json:"name"`
+			}
+			if err := snap.UnmashalQuery(ctx, q, &res); err != nil {
+				errs <- err
+				return
+			}
+			if n := len(res["q"]); n != 2 {
+				errs <- fmt.Errorf("snapshot saw %d nodes", n)
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	var res map[string][]struct {
+		Name string `json:"name"`
+	}
+	if err = c.NewReadOnlyTxn().BestEffort().UnmashalQueryStr(q, &res); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(res["q"]); n != 3 {
+		t.Fatalf("new read-only txn saw %d nodes", n)
+	}
+	resp, err := snap.Query(ctx, `query q($n: string) { q(func: eq(name, $n)) { name } }`, map[string]string{"$n": "c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(resp.Json) != `{"q":[]}` {
+		t.Fatalf("node written after snapshot is visible: %s", resp.Json)
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("best effort on read-write txn must panic")
+			}
+		}()
+		c.Txn().BestEffort()
+	}()
+}
@@ -0,0 +1,287 @@
+/**
+ * @Author: daipengyuan
+ * @Description: 软删除,DelNode只写入删除时间,可恢复或彻底删除
+ * @File:  softdelete
+ * @Version: 1.0.0
+ * @Date: 2026/10/20 01:00
+ */
+
+package dql
+
+import (
+	"errors"
+	"fmt"
+	"github.com/dgraph-io/dgo/v200/protos/api"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// TagSoftDelete 设置在Uid字段上,值为记录删除时间的datetime谓词,如softdelete:"deleted_at"
+const TagSoftDelete = "softdelete"
+
+// SoftDeleteOf 获取obj类型的软删除谓词,未开启软删除时返回空
+func SoftDeleteOf(obj interface{}) string {
+	tp := reflect.TypeOf(obj)
+	for tp != nil && (tp.Kind() == reflect.Ptr || tp.Kind() == reflect.Slice) {
+		tp = tp.Elem()
+	}
+	if tp == nil || tp.Kind() != reflect.Struct {
+		return ""
+	}
+	f, ok := tp.FieldByName(Uid)
+	if !ok {
+		return ""
+	}
+	return f.Tag.Get(TagSoftDelete)
+}
+
+// notDeleted 排除软删除节点的过滤条件
+func notDeleted(pred string) string {
+	return fmt.Sprintf("NOT has(%s)", pred)
+}
+
+// makeSoftDel 写入删除时间
+func (m *mutation) makeSoftDel() (*api.Request, error) {
+	if m.Subject == "" {
+		return nil, errors.New("make del node failed, subject is nil")
+	}
+	bs, err := m.clock().MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	nq := &api.NQuad{
+		Subject:     m.Subject,
+		Predicate:   m.SoftDel,
+		ObjectValue: &api.Value{Val: &api.Value_DatetimeVal{DatetimeVal: bs}},
+	}
+	return &api.Request{Mutations: []*api.Mutation{{Set: []*api.NQuad{nq}}}}, nil
+}
+
+// MakeRestore 删除软删除时间,恢复节点
+func (m *mutation) MakeRestore() (*api.Request, error) {
+	if m.SoftDel == "" {
+		return nil, errors.New("type " + m.Dtype + " has no soft delete predicate")
+	}
+	if m.Subject == "" {
+		return nil, errors.New("make restore failed, subject is nil")
+	}
+	nq := &api.NQuad{Subject: m.Subject, Predicate: m.SoftDel, ObjectValue: starNqVal}
+	return &api.Request{Mutations: []*api.Mutation{{Del: []*api.NQuad{nq}}}}, nil
+}
+
+// BuildRestore 生成Txn.Restore发送的请求
+func BuildRestore(obj interface{}) (*api.Request, error) {
+	return build(obj, nil, buildEnv{}, (*mutation).MakeRestore)
+}
+
+// BuildPurge 生成Txn.Purge发送的请求
+func BuildPurge(obj interface{}) (*api.Request, error) {
+	return build(obj, nil, buildEnv{}, (*mutation).MakePurge)
+}
+
+// Restore 恢复软删除的节点
+func (d *Txn) Restore(obj interface{}) (*api.Response, error) {
+	req, err := BuildRestore(obj)
+	if err != nil {
+		return nil, err
+	}
+	return d.do(req)
+}
+
+// Purge 彻底删除节点及指向它的反向边,不论类型是否开启软删除
+func (d *Txn) Purge(obj interface{}) (*api.Response, error) {
+	req, err := BuildPurge(obj)
+	if err != nil {
+		return nil, err
+	}
+	return d.do(req)
+}
+
+// PurgeBefore 彻底删除类型obj中删除时间早于t的节点,返回删除的数量
+// 用于定期清理超过保留期的软删除数据
+func (d *Txn) PurgeBefore(obj interface{}, t time.Time) (int, error) {
+	m, err := newMutation(obj)
+	if err != nil {
+		return 0, err
+	}
+	if m.SoftDel == "" {
+		return 0, errors.New("type " + m.Dtype + " has no soft delete predicate")
+	}
+	var res struct {
+		Q []struct {
+			Uid string `json:"uid"`
+		} `json:"q"`
+	}
+	q := fmt.Sprintf(`{ q(func: type(%s)) @filter(lt(%s, "%s")) { uid } }`, m.Dtype, m.SoftDel, t.Format(time.RFC3339Nano))
+	if err = d.UnmashalQueryStr(q, &res); err != nil {
+		return 0, err
+	}
+	for _, n := range res.Q {
+		// 每个节点使用新的mutation,parseTag的状态不能跨请求复用
+		pm, err := newMutation(obj)
+		if err != nil {
+			return 0, err
+		}
+		pm.Subject = n.Uid
+		req, err := pm.MakePurge()
+		if err != nil {
+			return 0, err
+		}
+		if _, err = d.do(req); err != nil {
+			return 0, err
+		}
+	}
+	return len(res.Q), nil
+}
+
+// softDeleteBlocks 根据obj中各字段的元素类型的softdelete标签,得到查询块名到软删除谓词的映射
+// 查询块名为字段的json名,如Q []Account `json:"q"`对应q块
+func softDeleteBlocks(obj interface{}) map[string]string {
+	tp := reflect.TypeOf(obj)
+	for tp != nil && tp.Kind() == reflect.Ptr {
+		tp = tp.Elem()
+	}
+	if tp == nil || tp.Kind() != reflect.Struct {
+		return nil
+	}
+	r := map[string]string{}
+	for i := 0; i < tp.NumField(); i++ {
+		f := tp.Field(i)
+		sd := SoftDeleteOf(reflect.Zero(f.Type).Interface())
+		if sd == "" {
+			continue
+		}
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "" {
+			name = f.Name
+		}
+		r[name] = sd
+	}
+	return r
+}
+
+// softDeleteQuery 按obj中各查询块对应类型的软删除谓词,在这些根查询块中排除已删除的节点
+func softDeleteQuery(q string, obj interface{}) string {
+	blocks := softDeleteBlocks(obj)
+	if len(blocks) == 0 {
+		return q
+	}
+	return withSoftDelete(q, func(block string) string { return blocks[block] })
+}
+
+// withSoftDelete 在每个根查询块的根函数后增加排除软删除节点的过滤,predOf返回块的软删除谓词,为空时跳过
+// 根节点已有@filter时与之以AND组合,已包含该条件时不重复添加
+func withSoftDelete(q string, predOf func(block string) string) string {
+	roots := rootFuncs(q)
+	for i := len(roots) - 1; i >= 0; i-- {
+		pred := predOf(roots[i].name)
+		if pred == "" {
+			continue
+		}
+		end := roots[i].end
+		k := skipDirectives(q, end)
+		if strings.HasPrefix(q[k:], "@filter(") {
+			k += len("@filter(")
+			if strings.HasPrefix(q[k:], notDeleted(pred)) {
+				continue
+			}
+			q = q[:k] + notDeleted(pred) + " AND (" + closeFilter(q[k:])
+			continue
+		}
+		q = q[:end] + " @filter(" + notDeleted(pred) + ")" + q[end:]
+	}
+	return q
+}
+
+// rootFunc 根查询块,end为根函数右括号之后的位置
+type rootFunc struct {
+	name string
+	end  int
+}
+
+// rootFuncs 找出查询中所有带func的根查询块,跳过字符串中的内容
+func rootFuncs(q string) []rootFunc {
+	var (
+		r      []rootFunc
+		braces int
+		parens int
+		start  = -1
+	)
+	for i := 0; i < len(q); i++ {
+		switch q[i] {
+		case '"':
+			for i++; i < len(q) && q[i] != '"'; i++ {
+				if q[i] == '\\' {
+					i++
+				}
+			}
+		case '{':
+			braces++
+		case '}':
+			braces--
+		case '(':
+			if braces == 1 && parens == 0 && strings.HasPrefix(strings.TrimLeft(q[i+1:], " "), "func:") {
+				start = i
+			}
+			parens++
+		case ')':
+			parens--
+			if parens == 0 && start >= 0 {
+				name := strings.TrimSpace(q[:start])
+				if j := strings.LastIndexAny(name, " {}\t\n"); j >= 0 {
+					name = name[j+1:]
+				}
+				r = append(r, rootFunc{name: name, end: i + 1})
+				start = -1
+			}
+		}
+	}
+	return r
+}
+
+// skipDirectives 跳过根函数之后@filter之前的其他指令,如@cascade、@normalize,返回下一个指令或{的位置
+func skipDirectives(q string, i int) int {
+	for {
+		for i < len(q) && (q[i] == ' ' || q[i] == '\t' || q[i] == '\n') {
+			i++
+		}
+		if i >= len(q) || q[i] != '@' || strings.HasPrefix(q[i:], "@filter(") {
+			return i
+		}
+		j := i + 1
+		for j < len(q) && q[j] != ' ' && q[j] != '(' && q[j] != '{' && q[j] != '@' {
+			j++
+		}
+		if j < len(q) && q[j] == '(' {
+			for depth := 0; j < len(q); j++ {
+				if q[j] == '(' {
+					depth++
+				} else if q[j] == ')' {
+					if depth--; depth == 0 {
+						j++
+						break
+					}
+				}
+			}
+		}
+		i = j
+	}
+}
+
+// closeFilter 在已有过滤条件的结尾补上括号,s为@filter(之后的内容
+func closeFilter(s string) string {
+	depth := 1
+	for j := 0; j < len(s); j++ {
+		switch s[j] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		}
+		if depth == 0 {
+			return s[:j] + ")" + s[j:]
+		}
+	}
+	return s
+}
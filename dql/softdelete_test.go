@@ -0,0 +1,219 @@
+/**
+ * @Author: daipengyuan
+ * @Description:
+ * @File:  softdelete_test
+ * @Version: 1.0.0
+ * @Date: 2026/10/20 01:00
+ */
+
+package dql_test
+
+import (
+	"fmt"
+	"github.com/dgraph-io/dgo/v200/protos/api"
+	"github.com/golang-common/dglib/dql"
+	"github.com/golang-common/dglib/dql/dqltest"
+	"testing"
+	"time"
+)
+
+type account struct {
+	Uid   string   `json:"uid" db:"uid,string" dtype:"Account" softdelete:"deleted_at"`
+	Name  string   `json:"name" db:"name,string"`
+	Owner []string `json:"owner" db:"owner,uid"`
+}
+
+type voucher struct {
+	Uid  string `json:"uid" db:"uid,string" dtype:"Voucher" softdelete:"deleted_at"`
+	Code string `json:"code" db:"code,string,id"`
+}
+
+func TestSoftDelete(t *testing.T) {
+	c := dqltest.NewClient(t)
+	var uids []string
+	for _, name := range []string{"a", "b"} {
+		txn := c.Txn()
+		resp, err := txn.Add(account{Name: name, Owner: []string{"0x100"}})
+		txn.CommitOrAbort(err)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, u := range resp.Uids {
+			uids = append(uids, u)
+		}
+	}
+	count := func() int {
+		var as []account
+		if err := c.Txn(true).List(&as, nil); err != nil {
+			t.Fatal(err)
+		}
+		return len(as)
+	}
+	if n := count(); n != 2 {
+		t.Fatalf("expected 2 accounts, got %d", n)
+	}
+
+	txn := c.Txn()
+	_, err := txn.DelNode(account{Uid: uids[0]})
+	txn.CommitOrAbort(err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := count(); n != 1 {
+		t.Fatalf("soft deleted account listed, got %d", n)
+	}
+	if err = c.Txn(true).Get(&account{Uid: uids[0]}); err == nil {
+		t.Fatal("soft deleted account returned by Get")
+	}
+	var res struct {
+		Q []account `json:"q"`
+	}
+	q := dql.Query{Q: `{ q(func: type(Account)) { uid } }`, SoftDelete: dql.SoftDeleteOf(account{})}
+	if err = c.Txn(true).UnmashalQueryObj(q, &res); err != nil || len(res.Q) != 1 {
+		t.Fatalf("query helper did not filter deleted: %v %+v", err, res.Q)
+	}
+	// 未设置SoftDelete时按结果类型的标签过滤每个查询块
+	var multi struct {
+		Q     []account `json:"q"`
+		Named []account `json:"named"`
+		Raw   []struct {
+			Uid string `json:"uid"`
+		} `json:"raw"`
+	}
+	mq := `{ q(func: type(Account)) { uid } named(func: eq(name, "a")) @filter(type(Account)) { uid } raw(func: type(Account)) { uid } }`
+	if err = c.Txn(true).UnmashalQueryStr(mq, &multi); err != nil || len(multi.Q) != 1 || len(multi.Named) != 0 || len(multi.Raw) != 2 {
+		t.Fatalf("query helper did not filter deleted in every block: %v %+v", err, multi)
+	}
+	if err = c.Txn(true).UnmashalQueryObj(dql.Query{Q: `{ q(func: type(Account)) { uid } }`, IncludeDeleted: true}, &res); err != nil || len(res.Q) != 2 {
+		t.Fatalf("include deleted: %v %+v", err, res.Q)
+	}
+
+	txn = c.Txn()
+	_, err = txn.Restore(account{Uid: uids[0]})
+	txn.CommitOrAbort(err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := account{Uid: uids[0]}
+	if err = c.Txn(true).Get(&a); err != nil {
+		t.Fatal(err)
+	}
+	if a.Name != "a" || len(a.Owner) != 1 || a.Owner[0] != "0x100" {
+		t.Fatalf("unexpected restored account %+v", a)
+	}
+
+	txn = c.Txn()
+	_, err = txn.DelNode(account{Uid: uids[1]})
+	txn.CommitOrAbort(err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	txn = c.Txn()
+	n, err := txn.PurgeBefore(account{}, time.Now().Add(time.Hour))
+	txn.CommitOrAbort(err)
+	if err != nil || n != 1 {
+		t.Fatalf("purge: %d %v", n, err)
+	}
+}
+
+// 类型有id字段时一次清理多个节点
+func TestPurgeBefore_IdTag(t *testing.T) {
+	c := dqltest.NewClient(t)
+	for _, code := range []string{"v1", "v2", "v3"} {
+		txn := c.Txn()
+		resp, err := txn.Add(voucher{Code: code})
+		txn.CommitOrAbort(err)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code == "v3" {
+			continue
+		}
+		for _, u := range resp.Uids {
+			txn = c.Txn()
+			_, err = txn.DelNode(voucher{Uid: u})
+			txn.CommitOrAbort(err)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	txn := c.Txn()
+	n, err := txn.PurgeBefore(voucher{}, time.Now().Add(time.Hour))
+	txn.CommitOrAbort(err)
+	if err != nil || n != 2 {
+		t.Fatalf("purge: %d %v", n, err)
+	}
+	var vs []voucher
+	if err = c.Txn(true).List(&vs, nil); err != nil || len(vs) != 1 || vs[0].Code != "v3" {
+		t.Fatalf("vouchers %+v %v", vs, err)
+	}
+}
+
+func TestQuery_SoftDelete(t *testing.T) {
+	cases := map[string]string{
+		`{ q(func: type(A)) { uid } }`:                      `{ q(func: type(A)) @filter(NOT has(deleted_at)) { uid } }`,
+		`{ q(func: type(A)) @filter(eq(n, "x")) { uid } }`:  `{ q(func: type(A)) @filter(NOT has(deleted_at) AND (eq(n, "x"))) { uid } }`,
+		`{ q(func: type(A)) @filter($rootfilter) { uid } }`: `{ q(func: type(A)) @filter(NOT has(deleted_at)) { uid } }`,
+		`{ a(func: type(A)) @cascade { uid b { uid } } c(func: eq(n, "(func: x)")) @normalize @filter(has(n)) { uid } }`: `{ a(func: type(A)) @filter(NOT has(deleted_at)) @cascade { uid b { uid } } c(func: eq(n, "(func: x)")) @normalize @filter(NOT has(deleted_at) AND (has(n))) { uid } }`,
+	}
+	for in, want := range cases {
+		got, err := dql.Query{Q: in, SoftDelete: "deleted_at"}.Parse()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+	}
+}
+
+type asset struct {
+	Uid    string   `json:"uid" db:"uid,string" dtype:"Asset"`
+	Name   string   `json:"name" db:"name,string"`
+	Owners []string `json:"owners" db:"~owns,uid"`
+}
+
+// 彻底删除节点时只删除指向该节点的反向边
+func TestPurge_ReverseEdges(t *testing.T) {
+	c := dqltest.NewClient(t)
+	if err := c.SetPred(dql.Pred{Predicate: "owns", Type: "uid", Reverse: true, List: true}); err != nil {
+		t.Fatal(err)
+	}
+	txn := c.Txn()
+	resp, err := txn.Txn.Mutate(txn.Ctx(), &api.Mutation{CommitNow: true, Set: []*api.NQuad{
+		nq("_:x", "dgraph.type", "Asset"), nq("_:x", "name", "x"),
+		nq("_:y", "dgraph.type", "Asset"), nq("_:y", "name", "y"),
+		nq("_:r1", "name", "r1"), nq("_:r1", "owns", "_:x"), nq("_:r1", "owns", "_:y"),
+		nq("_:r2", "name", "r2"), nq("_:r2", "owns", "_:y"),
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	txn = c.Txn()
+	_, err = txn.Purge(asset{Uid: resp.Uids["x"]})
+	txn.CommitOrAbort(err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res struct {
+		Q []struct {
+			Name string `json:"name"`
+			Owns []struct {
+				Uid string `json:"uid"`
+			} `json:"owns"`
+		} `json:"q"`
+	}
+	q := fmt.Sprintf(`{ q(func: uid(%s, %s), orderasc: name) { name owns { uid } } }`, resp.Uids["r1"], resp.Uids["r2"])
+	if err = c.Txn(true).UnmashalQueryStr(q, &res); err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Q) != 2 {
+		t.Fatalf("referrers %+v", res.Q)
+	}
+	for _, r := range res.Q {
+		if len(r.Owns) != 1 || r.Owns[0].Uid != resp.Uids["y"] {
+			t.Fatalf("edges of %s changed: %+v", r.Name, r.Owns)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+/**
+ * @Author: daipengyuan
+ * @Description: 按一个或多个谓词组成的键写入节点
+ * @File:  upsert
+ * @Version: 1.0.0
+ * @Date: 2026/10/20 04:30
+ */
+
+package dql
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dgraph-io/dgo/v200/protos/api"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const upsertBlock = "upsert_key"
+
+// UpsertResult Upsert/InsertIgnore的结果,Created为真时Uid为新建的节点
+// DryRun时Uid为空
+type UpsertResult struct {
+	Uid      string
+	Created  bool
+	Response *api.Response
+}
+
+// BuildUpsert 生成Txn.Upsert发送的请求,ignore为真时生成Txn.InsertIgnore的请求
+func BuildUpsert(obj interface{}, ignore bool, on ...string) (*api.Request, error) {
+	req, _, err := buildUpsert(obj, buildEnv{}, ignore, on)
+	return req, err
+}
+
+// Upsert 按on中的字段查找节点,不存在时新增,存在时写入obj的非零字段
+// on为字段名、json名或谓词名,为空时使用id标签的字段;多个字段共同组成键
+func (d *Txn) Upsert(obj interface{}, on ...string) (*UpsertResult, error) {
+	return d.upsert(obj, false, on)
+}
+
+// InsertIgnore 按on中的字段查找节点,不存在时新增,存在时不做修改
+func (d *Txn) InsertIgnore(obj interface{}, on ...string) (*UpsertResult, error) {
+	return d.upsert(obj, true, on)
+}
+
+func (d *Txn) upsert(obj interface{}, ignore bool, on []string) (*UpsertResult, error) {
+	req, blank, err := buildUpsert(obj, d.env(), ignore, on)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := d.do(req)
+	if err != nil {
+		return nil, err
+	}
+	r := &UpsertResult{Response: resp}
+	if d.DryRun {
+		return r, nil
+	}
+	var res map[string][]uidNode
+	if err = json.Unmarshal(resp.Json, &res); err != nil {
+		return nil, err
+	}
+	switch n := len(res[upsertBlock]); n {
+	case 0:
+		r.Uid, r.Created = resp.Uids[blank], true
+	case 1:
+		r.Uid = res[upsertBlock][0].Uid
+	default:
+		return nil, errors.New(fmt.Sprintf("upsert key matched %d nodes", n))
+	}
+	return r, nil
+}
+
+// buildUpsert 生成查询键对应节点的变量k,以及k为空时新增、k唯一时更新的两个变更
+// 键的值通过请求变量传递,返回新增节点的空白节点名
+func buildUpsert(obj interface{}, env buildEnv, ignore bool, on []string) (*api.Request, string, error) {
+	m, err := newMutation(obj)
+	if err != nil {
+		return nil, "", err
+	}
+	if m.Subject != "" {
+		return nil, "", errors.New("upsert obj must not have uid")
+	}
+	if m.Dtype == "" {
+		return nil, "", errors.New("obj must have dtype tag")
+	}
+	m.actor, m.now = env.actor, env.now
+	if err = m.begin(writeAdd); err != nil {
+		return nil, "", err
+	}
+	keys, err := m.upsertKeys(on)
+	if err != nil {
+		return nil, "", err
+	}
+	var (
+		decl    []string
+		filters []string
+		vars    = map[string]string{}
+	)
+	for i, k := range keys {
+		tp, v, err := keyVar(m.Val.FieldByName(k.Name))
+		if err != nil {
+			return nil, "", errors.New(fmt.Sprintf("key %s: %s", k.Name, err.Error()))
+		}
+		name := fmt.Sprintf("$k%d", i)
+		decl = append(decl, name+": "+tp)
+		filters = append(filters, fmt.Sprintf("eq(%s, %s)", fieldPred(k), name))
+		vars[name] = v
+	}
+	q := fmt.Sprintf("query upsert(%s) { k as var(func: type(%s)) @filter(%s) %s(func: uid(k)) { uid } }",
+		strings.Join(decl, ", "), m.Dtype, strings.Join(filters, " AND "), upsertBlock)
+
+	blank := BlankNode()
+	m.Subject = "_:" + blank
+	set, err := m.upsertNquads(true, keys)
+	if err != nil {
+		return nil, "", err
+	}
+	set = append([]*api.NQuad{{
+		Subject:     m.Subject,
+		Predicate:   "dgraph.type",
+		ObjectValue: &api.Value{Val: &api.Value_StrVal{StrVal: m.Dtype}},
+	}}, set...)
+	mul := []*api.Mutation{{Cond: "@if(eq(len(k),0))", Set: set}}
+	if !ignore {
+		m.Subject = "uid(k)"
+		upd, err := m.upsertNquads(false, keys)
+		if err != nil {
+			return nil, "", err
+		}
+		if len(upd) > 0 {
+			mul = append(mul, &api.Mutation{Cond: "@if(eq(len(k),1))", Set: upd})
+		}
+	}
+	return &api.Request{Query: q, Vars: vars, Mutations: mul}, blank, nil
+}
+
+// upsertKeys on对应的字段,为空时使用id标签的字段
+func (m *mutation) upsertKeys(on []string) ([]reflect.StructField, error) {
+	var keys []reflect.StructField
+	tp := m.Val.Type()
+	if len(on) == 0 {
+		for i := 0; i < tp.NumField(); i++ {
+			for _, tg := range strings.Split(tp.Field(i).Tag.Get(TagDb), ",")[1:] {
+				if tg == tagId {
+					keys = append(keys, tp.Field(i))
+				}
+			}
+		}
+		if len(keys) == 0 {
+			return nil, errors.New("upsert need key fields or id tag")
+		}
+		return keys, nil
+	}
+	for _, name := range on {
+		found := false
+		for i := 0; i < tp.NumField(); i++ {
+			f := tp.Field(i)
+			if f.Name != Uid && f.Tag.Get(TagDb) != "" && matchField(f, name) {
+				if strings.HasPrefix(fieldPred(f), "~") {
+					return nil, errors.New("reverse predicate cannot be upsert key")
+				}
+				keys = append(keys, f)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, errors.New("key field " + name + " not found")
+		}
+	}
+	return keys, nil
+}
+
+// keyVar 键的值转换为请求变量的类型与字符串,浮点数保留全部精度
+func keyVar(fv reflect.Value) (string, string, error) {
+	b, err := baseValue("", fv)
+	if err != nil {
+		return "", "", err
+	}
+	if !b.IsValid() {
+		return "", "", errors.New("key must not be nil")
+	}
+	switch v := b.Interface().(type) {
+	case string:
+		return "string", v, nil
+	case int64:
+		return "int", strconv.FormatInt(v, 10), nil
+	case float64:
+		return "float", strconv.FormatFloat(v, 'g', -1, 64), nil
+	case bool:
+		return "bool", strconv.FormatBool(v), nil
+	case time.Time:
+		return "string", v.Format(time.RFC3339Nano), nil
+	}
+	return "", "", errors.New("unsupport key datatype " + b.Type().String())
+}
+
+// upsertNquads 新增时写入全部非零字段与键,更新时不写入键、创建时间与版本字段
+func (m *mutation) upsertNquads(insert bool, keys []reflect.StructField) ([]*api.NQuad, error) {
+	var r []*api.NQuad
+	// 新增与更新各遍历一次标签,parseTag不允许重复出现id标签
+	m.idSet = false
+	for i := 0; i < m.Val.NumField(); i++ {
+		f := m.Val.Type().Field(i)
+		fv := m.Val.Field(i)
+		if f.Name == Uid {
+			continue
+		}
+		if err := m.parseTag(f.Tag); err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(m.curName, "~") || m.curVersion {
+			continue
+		}
+		key := isKey(f, keys)
+		if !insert && (m.curAuto == AutoCreated || key) {
+			continue
+		}
+		// 零值的键同样写入,否则新节点无法再被该键找到
+		if fv.IsZero() && !(insert && key) {
+			if insert && m.curMustSet {
+				return nil, errors.New(fmt.Sprintf("%s must have a value", m.curName))
+			}
+			continue
+		}
+		nql, err := m.setCurVal(fv)
+		if err != nil {
+			return nil, err
+		}
+		for _, fc := range m.Facets {
+			if fc.PredWithLang == m.curName && fc.Seq < len(nql) {
+				if err = fc.Combine(nql[fc.Seq]); err != nil {
+					return nil, err
+				}
+			}
+		}
+		r = append(r, nql...)
+	}
+	return r, nil
+}
+
+func isKey(f reflect.StructField, keys []reflect.StructField) bool {
+	for _, k := range keys {
+		if k.Name == f.Name {
+			return true
+		}
+	}
+	return false
+}
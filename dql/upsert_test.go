@@ -0,0 +1,129 @@
+/**
+ * @Author: daipengyuan
+ * @Description:
+ * @File:  upsert_test
+ * @Version: 1.0.0
+ * @Date: 2026/10/20 04:30
+ */
+
+package dql_test
+
+import (
+	"github.com/golang-common/dglib/dql"
+	"github.com/golang-common/dglib/dql/dqltest"
+	"testing"
+)
+
+type record struct {
+	Uid        string  `json:"uid" db:"uid,string" dtype:"Record"`
+	Tenant     int     `json:"tenant" db:"tenant,int"`
+	ExternalId string  `json:"external_id" db:"external_id,string"`
+	Name       string  `json:"name" db:"name,string"`
+	Weight     float64 `json:"weight" db:"weight,float"`
+}
+
+type sku struct {
+	Uid  string `json:"uid" db:"uid,string" dtype:"Sku"`
+	Code string `json:"code" db:"code,string,id"`
+	Name string `json:"name" db:"name,string"`
+}
+
+func TestUpsert(t *testing.T) {
+	c := dqltest.NewClient(t)
+	run := func(ignore bool, r record, on ...string) *dql.UpsertResult {
+		t.Helper()
+		txn := c.Txn()
+		var (
+			res *dql.UpsertResult
+			err error
+		)
+		if ignore {
+			res, err = txn.InsertIgnore(r, on...)
+		} else {
+			res, err = txn.Upsert(r, on...)
+		}
+		txn.CommitOrAbort(err)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+	name := func(uid string) string {
+		t.Helper()
+		r := record{Uid: uid}
+		if err := c.Txn(true).Get(&r); err != nil {
+			t.Fatal(err)
+		}
+		return r.Name
+	}
+
+	first := run(false, record{Tenant: 1, ExternalId: "x", Name: "a"}, "tenant", "external_id")
+	if !first.Created || first.Uid == "" {
+		t.Fatalf("expected created, got %+v", first)
+	}
+	second := run(false, record{Tenant: 1, ExternalId: "x", Name: "b"}, "Tenant", "ExternalId")
+	if second.Created || second.Uid != first.Uid || name(first.Uid) != "b" {
+		t.Fatalf("expected update of %s, got %+v", first.Uid, second)
+	}
+	ignored := run(true, record{Tenant: 1, ExternalId: "x", Name: "c"}, "tenant", "external_id")
+	if ignored.Created || ignored.Uid != first.Uid || name(first.Uid) != "b" {
+		t.Fatalf("insert ignore modified node: %+v", ignored)
+	}
+	// 零值的键同样参与匹配
+	other := run(false, record{Tenant: 0, ExternalId: "x", Name: "d"}, "tenant", "external_id")
+	if !other.Created || other.Uid == first.Uid {
+		t.Fatalf("different tenant must create, got %+v", other)
+	}
+	if again := run(true, record{ExternalId: "x"}, "tenant", "external_id"); again.Uid != other.Uid {
+		t.Fatalf("zero key not matched: %+v", again)
+	}
+	// 浮点数键不丢失精度
+	w := run(false, record{Weight: 0.1 + 0.2, Name: "w"}, "weight")
+	if again := run(false, record{Weight: 0.1 + 0.2, Name: "w2"}, "weight"); again.Uid != w.Uid || again.Created {
+		t.Fatalf("float key not matched: %+v", again)
+	}
+
+	if _, err := dql.BuildUpsert(record{Name: "a"}, false); err == nil {
+		t.Fatal("upsert without key accepted")
+	}
+	if _, err := dql.BuildUpsert(record{Uid: "0x1"}, false, "name"); err == nil {
+		t.Fatal("upsert with uid accepted")
+	}
+	dqltest.SeqBlankNodes(t)
+	req, err := dql.BuildUpsert(record{Tenant: 1, ExternalId: `a"b`, Name: "n"}, false, "tenant", "external_id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dqltest.GoldenRequest(t, req)
+}
+
+// 未指定键时使用id标签的字段
+func TestUpsert_IdTag(t *testing.T) {
+	c := dqltest.NewClient(t)
+	var uid string
+	for i, name := range []string{"a", "b"} {
+		txn := c.Txn()
+		res, err := txn.Upsert(sku{Code: "c1", Name: name})
+		txn.CommitOrAbort(err)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Created != (i == 0) || (i > 0 && res.Uid != uid) {
+			t.Fatalf("upsert %d: %+v", i, res)
+		}
+		uid = res.Uid
+	}
+	txn := c.Txn()
+	res, err := txn.InsertIgnore(sku{Code: "c1", Name: "c"})
+	txn.CommitOrAbort(err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := sku{Uid: uid}
+	if err = c.Txn(true).Get(&got); err != nil {
+		t.Fatal(err)
+	}
+	if res.Created || res.Uid != uid || got.Name != "b" {
+		t.Fatalf("insert ignore %+v, node %+v", res, got)
+	}
+}
@@ -0,0 +1,205 @@
+/**
+ * @Author: daipengyuan
+ * @Description: 自定义的upsert请求,查询块加多个带条件的变更
+ * @File:  upsertreq
+ * @Version: 1.0.0
+ * @Date: 2026/10/20 05:00
+ */
+
+package dql
+
+import (
+	"errors"
+	"fmt"
+	"github.com/dgraph-io/dgo/v200/protos/api"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Ref 节点引用,作为Triple的宾语时写入ObjectId而不是值
+// 可以是0x1、_:name、uid(v)或val(x)
+type Ref string
+
+// UidVar 引用查询变量v中的节点
+func UidVar(v string) Ref {
+	return Ref("uid(" + v + ")")
+}
+
+// ValVar 引用值变量x在当前主语上的值
+func ValVar(x string) Ref {
+	return Ref("val(" + x + ")")
+}
+
+// Triple 变更模板中的一条nquad
+// Subject 为0x1、_:name或uid(v);Object 为Ref或值,Del中为nil时删除该谓词的所有值
+// Predicate 为*时删除主语的所有谓词;Type 为宾语的dgraph类型,为空时根据Go类型推断
+type Triple struct {
+	Subject   string
+	Predicate string
+	Object    interface{}
+	Type      string
+	Lang      string
+	Facets    []*Facet
+}
+
+// UpsertMutation 带条件的变更,Cond如eq(len(v),0),为空时无条件执行
+type UpsertMutation struct {
+	Cond string
+	Set  []Triple
+	Del  []Triple
+}
+
+// UpsertRequest 由Query生成查询块,查询块中定义的变量可以在Cond与Triple中引用
+type UpsertRequest struct {
+	Query     Query
+	Vars      map[string]string
+	Mutations []UpsertMutation
+}
+
+var varRefRegexp = regexp.MustCompile(`(?:uid|val|len)\(\s*([A-Za-z_][A-Za-z0-9_]*)\s*\)`)
+
+// Build 生成请求,检查引用的变量均在查询中定义
+func (u UpsertRequest) Build() (*api.Request, error) {
+	q, err := u.Query.Parse()
+	if err != nil {
+		return nil, err
+	}
+	if len(u.Mutations) == 0 {
+		return nil, errors.New("upsert need at least one mutation")
+	}
+	req := &api.Request{Query: q, Vars: u.Vars}
+	for i, um := range u.Mutations {
+		mu := &api.Mutation{}
+		if c := strings.TrimSpace(um.Cond); c != "" {
+			if !strings.HasPrefix(c, "@if") {
+				c = "@if(" + c + ")"
+			}
+			if err = checkVarRefs(q, c); err != nil {
+				return nil, err
+			}
+			mu.Cond = c
+		}
+		for _, t := range um.Set {
+			nq, err := t.nquad(q, false)
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("mutation %d: %s", i, err.Error()))
+			}
+			mu.Set = append(mu.Set, nq)
+		}
+		for _, t := range um.Del {
+			nq, err := t.nquad(q, true)
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("mutation %d: %s", i, err.Error()))
+			}
+			mu.Del = append(mu.Del, nq)
+		}
+		if len(mu.Set) == 0 && len(mu.Del) == 0 {
+			return nil, errors.New(fmt.Sprintf("mutation %d is empty", i))
+		}
+		req.Mutations = append(req.Mutations, mu)
+	}
+	return req, nil
+}
+
+// checkVarRefs s中通过uid()、val()、len()引用的变量必须在查询中以"x as"定义
+func checkVarRefs(q, s string) error {
+	for _, m := range varRefRegexp.FindAllStringSubmatch(s, -1) {
+		if !regexp.MustCompile(`\b` + m[1] + `\s+as\s`).MatchString(q) {
+			return errors.New("variable " + m[1] + " not defined in query")
+		}
+	}
+	return nil
+}
+
+func (t Triple) nquad(q string, del bool) (*api.NQuad, error) {
+	if t.Subject == "" || t.Predicate == "" {
+		return nil, errors.New("triple must have subject and predicate")
+	}
+	if err := checkVarRefs(q, t.Subject); err != nil {
+		return nil, err
+	}
+	nq := &api.NQuad{Subject: t.Subject, Predicate: t.Predicate, Lang: t.Lang}
+	if t.Predicate == "*" {
+		if !del || t.Object != nil {
+			return nil, errors.New("predicate * only used to delete node")
+		}
+		nq.Predicate, nq.ObjectValue = StarAll, starNqVal
+		return nq, nil
+	}
+	switch o := t.Object.(type) {
+	case nil:
+		if !del {
+			return nil, errors.New("set triple must have object")
+		}
+		nq.ObjectId, nq.ObjectValue = StarAll, starNqVal
+	case Ref:
+		if err := checkVarRefs(q, string(o)); err != nil {
+			return nil, err
+		}
+		nq.ObjectId = string(o)
+	default:
+		dt := t.Type
+		if dt == "" {
+			var err error
+			if dt, err = inferType(reflect.ValueOf(o)); err != nil {
+				return nil, err
+			}
+		}
+		v, id, ok, err := toNqValue(dt, reflect.ValueOf(o))
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, errors.New("triple object must not be nil pointer")
+		}
+		nq.ObjectValue, nq.ObjectId = v, id
+	}
+	for _, fc := range t.Facets {
+		if err := fc.Combine(nq); err != nil {
+			return nil, err
+		}
+	}
+	return nq, nil
+}
+
+// inferType 根据Go类型推断dgraph类型
+func inferType(v reflect.Value) (string, error) {
+	b, err := baseValue("", v)
+	if err != nil {
+		return "", err
+	}
+	if !b.IsValid() {
+		return "", errors.New("triple object must not be nil pointer")
+	}
+	if b.Type().Implements(geomType) {
+		return TypeGeo, nil
+	}
+	switch b.Interface().(type) {
+	case string:
+		return TypeString, nil
+	case int64:
+		return TypeInt, nil
+	case float64:
+		return TypeFloat, nil
+	case bool:
+		return TypeBool, nil
+	case time.Time:
+		return TypeDateTime, nil
+	}
+	return "", errors.New("cannot infer datatype of " + b.Type().String())
+}
+
+// DoUpsert 发送自定义的upsert请求,并将查询部分的结果解析到res,res为空时不解析
+func (d *Txn) DoUpsert(u UpsertRequest, res interface{}) (*api.Response, error) {
+	req, err := u.Build()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := d.do(req)
+	if err != nil || d.DryRun || res == nil {
+		return resp, err
+	}
+	return resp, Unmarshal(resp.Json, res)
+}
@@ -0,0 +1,111 @@
+/**
+ * @Author: daipengyuan
+ * @Description:
+ * @File:  upsertreq_test
+ * @Version: 1.0.0
+ * @Date: 2026/10/20 05:00
+ */
+
+package dql_test
+
+import (
+	"github.com/golang-common/dglib/dql"
+	"github.com/golang-common/dglib/dql/dqltest"
+	"sort"
+	"testing"
+)
+
+type item struct {
+	Uid      string   `json:"uid" db:"uid,string" dtype:"Item"`
+	Name     string   `json:"name" db:"name,string"`
+	Price    int      `json:"price" db:"price,int"`
+	OldPrice int      `json:"old_price" db:"old_price,int"`
+	Tags     []string `json:"tags" db:"tags,string"`
+}
+
+func TestUpsertRequest(t *testing.T) {
+	c := dqltest.NewClient(t)
+	for _, p := range []dql.Pred{
+		{Predicate: "name", Type: "string"},
+		{Predicate: "price", Type: "int", Index: true, Tokenizer: []string{"int"}},
+		{Predicate: "old_price", Type: "int"},
+		{Predicate: "tags", Type: "string", List: true},
+	} {
+		if err := c.SetPred(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	schema, err := c.Txn(true).GetSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dql.InitSchemMap(*schema)
+	for _, it := range []item{{Name: "a", Price: 5}, {Name: "b", Price: 8}, {Name: "c", Price: 20}} {
+		txn := c.Txn()
+		_, err := txn.Add(it)
+		txn.CommitOrAbort(err)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cheap := dql.Filter{Expr: "cheap", Funcs: map[string]dql.FFunc{"cheap": {Key: "price", Type: dql.FuncLt, Val: 10}}}
+	u := dql.UpsertRequest{
+		Query: dql.Query{
+			Q:          `{ v as var(func: type(Item)) @filter($rootfilter) { p as price } matched(func: uid(v)) { uid name } }`,
+			RootFilter: &cheap,
+		},
+		Mutations: []dql.UpsertMutation{
+			{
+				Cond: "gt(len(v),0)",
+				Set: []dql.Triple{
+					{Subject: "uid(v)", Predicate: "tags", Object: "sale"},
+					{Subject: "uid(v)", Predicate: "old_price", Object: dql.ValVar("p")},
+				},
+			},
+			{
+				Cond: "eq(len(v),0)",
+				Set:  []dql.Triple{{Subject: "_:none", Predicate: "name", Object: "nothing matched"}},
+			},
+		},
+	}
+	var res struct {
+		Matched []item `json:"matched"`
+	}
+	txn := c.Txn()
+	resp, err := txn.DoUpsert(u, &res)
+	txn.CommitOrAbort(err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, it := range res.Matched {
+		names = append(names, it.Name)
+	}
+	sort.Strings(names)
+	if len(names) != 2 || names[0] != "a" || names[1] != "b" || len(resp.Uids) != 0 {
+		t.Fatalf("unexpected matched %v, uids %v", names, resp.Uids)
+	}
+	var all []item
+	if err = c.Txn(true).List(&all, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, it := range all {
+		tagged := len(it.Tags) == 1 && it.Tags[0] == "sale" && it.OldPrice == it.Price
+		if tagged != (it.Price < 10) {
+			t.Fatalf("unexpected item %+v", it)
+		}
+	}
+
+	bad := dql.UpsertRequest{
+		Query:     dql.Query{Q: `{ v as var(func: type(Item)) }`},
+		Mutations: []dql.UpsertMutation{{Set: []dql.Triple{{Subject: "uid(w)", Predicate: "tags", Object: "x"}}}},
+	}
+	if _, err = bad.Build(); err == nil {
+		t.Fatal("undefined variable accepted")
+	}
+	bad.Mutations[0].Set[0] = dql.Triple{Subject: "uid(v)", Predicate: "*"}
+	if _, err = bad.Build(); err == nil {
+		t.Fatal("set with * accepted")
+	}
+}
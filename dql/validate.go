@@ -0,0 +1,317 @@
+/**
+ * @Author: daipengyuan
+ * @Description: 变更前的结构体校验
+ * @File:  validate
+ * @Version: 1.0.0
+ * @Date: 2026/10/20 02:30
+ */
+
+package dql
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TagValidate 校验规则,多个规则以逗号分隔,如validate:"required,min=0,max=150"
+//
+//	required        不能为零值(Update与Merge时只检查写入的字段)
+//	min=n,max=n     数值的范围,字符串与切片的长度范围
+//	len=n           字符串与切片的长度
+//	regex=expr      字符串匹配正则,表达式中不能包含逗号
+//	oneof=a b c     取值为其中之一,以空格分隔
+//	email           邮箱格式
+//	eqfield=F,nefield=F,gtfield=F,gtefield=F,ltfield=F,ltefield=F  与同结构体中字段F比较
+//
+// 只校验会写入的字段:Add与Merge不写入零值字段,Update不写入未列入掩码的nil指针,这些字段除required外不做校验
+const TagValidate = "validate"
+
+// Validator 结构体实现该接口时,在标签校验之后调用Validate做自定义校验
+type Validator interface {
+	Validate() error
+}
+
+// ValidationError 单个字段的校验错误,自定义校验的错误Field为空
+type ValidationError struct {
+	Field     string `json:"field"`
+	Predicate string `json:"predicate"`
+	Rule      string `json:"rule"`
+	Message   string `json:"message"`
+}
+
+func (e ValidationError) Error() string {
+	if e.Field == "" {
+		return e.Message
+	}
+	return fmt.Sprintf("%s(%s): %s", e.Field, e.Predicate, e.Message)
+}
+
+// ValidationErrors 所有校验失败的字段
+type ValidationErrors []ValidationError
+
+func (es ValidationErrors) Error() string {
+	var r []string
+	for _, e := range es {
+		r = append(r, e.Error())
+	}
+	return "validation failed: " + strings.Join(r, "; ")
+}
+
+var (
+	emailRegexp = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+	regexpMu    sync.Mutex
+	regexpCache = map[string]*regexp.Regexp{}
+)
+
+// writeMode 变更写入字段值的方式
+type writeMode int
+
+const (
+	writeNone   writeMode = iota // 删除等不写入字段值的操作,不校验
+	writeAdd                     // 写入非零字段
+	writeUpdate                  // 写入全部字段,未列入掩码的nil指针除外
+	writeMerge                   // 写入非零字段与掩码中的字段
+)
+
+// begin 生成写入字段值的请求前调用:填充auto字段、检查掩码并校验,变更的校验只在这里执行
+func (m *mutation) begin(mode writeMode) error {
+	m.mode = mode
+	if err := m.fillAuto(mode == writeAdd); err != nil {
+		return err
+	}
+	if mode != writeAdd {
+		if err := m.checkMask(); err != nil {
+			return err
+		}
+	}
+	return m.validate()
+}
+
+// written 字段的值是否会被写入
+func (m *mutation) written(f reflect.StructField, fv reflect.Value) bool {
+	switch m.mode {
+	case writeAdd:
+		return !fv.IsZero()
+	case writeMerge:
+		return !fv.IsZero() || m.masked(f)
+	}
+	return !isNilPtr(fv) || m.masked(f)
+}
+
+// validate 按m.mode校验会写入的字段的标签规则并调用Validator
+// required在Add时总是检查,Update与Merge时只检查写入的字段
+func (m *mutation) validate() error {
+	if m.mode == writeNone {
+		return nil
+	}
+	var errs ValidationErrors
+	for i := 0; i < m.Val.NumField(); i++ {
+		f := m.Val.Type().Field(i)
+		rules := f.Tag.Get(TagValidate)
+		if rules == "" || !m.inMask(f) {
+			continue
+		}
+		pred := strings.Split(f.Tag.Get(TagDb), ",")[0]
+		fv := m.Val.Field(i)
+		written := m.written(f, fv)
+		for _, rule := range strings.Split(rules, ",") {
+			name, param := rule, ""
+			if k := strings.Index(rule, "="); k >= 0 {
+				name, param = rule[:k], rule[k+1:]
+			}
+			if name == "required" && !written && m.mode != writeAdd {
+				continue
+			}
+			// 写入nil指针即删除该谓词,没有值可以校验
+			if name != "required" && (!written || isNilPtr(fv)) {
+				continue
+			}
+			cv := fv
+			// 指针字段校验其指向的值,required只要求指针非空
+			if name != "required" {
+				cv = reflect.Indirect(fv)
+			}
+			msg, err := m.check(cv, name, param)
+			if err != nil {
+				return errors.New(fmt.Sprintf("field %s: %s", f.Name, err.Error()))
+			}
+			if msg != "" {
+				errs = append(errs, ValidationError{Field: f.Name, Predicate: pred, Rule: rule, Message: msg})
+			}
+		}
+	}
+	if v, ok := m.Val.Addr().Interface().(Validator); ok {
+		if err := v.Validate(); err != nil {
+			var ves ValidationErrors
+			var ve ValidationError
+			switch {
+			case errors.As(err, &ves):
+				errs = append(errs, ves...)
+			case errors.As(err, &ve):
+				errs = append(errs, ve)
+			default:
+				errs = append(errs, ValidationError{Rule: "custom", Message: err.Error()})
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// check 执行单个规则,校验失败时返回错误说明,规则本身有误时返回error
+func (m *mutation) check(fv reflect.Value, name, param string) (string, error) {
+	switch name {
+	case "required":
+		if fv.IsZero() {
+			return "is required", nil
+		}
+	case "min", "max", "len":
+		n, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return "", errors.New("invalid " + name + " param " + param)
+		}
+		v, isLen, err := measure(fv)
+		if err != nil {
+			return "", err
+		}
+		what := "value"
+		if isLen {
+			what = "length"
+		}
+		switch {
+		case name == "min" && v < n:
+			return fmt.Sprintf("%s must be at least %s", what, param), nil
+		case name == "max" && v > n:
+			return fmt.Sprintf("%s must be at most %s", what, param), nil
+		case name == "len" && v != n:
+			return fmt.Sprintf("length must be %s", param), nil
+		}
+	case "regex":
+		re, err := compileRegexp(param)
+		if err != nil {
+			return "", err
+		}
+		if fv.Kind() != reflect.String {
+			return "", errors.New("regex only support string")
+		}
+		if !re.MatchString(fv.String()) {
+			return "must match " + param, nil
+		}
+	case "email":
+		if fv.Kind() != reflect.String {
+			return "", errors.New("email only support string")
+		}
+		if !emailRegexp.MatchString(fv.String()) {
+			return "must be a valid email", nil
+		}
+	case "oneof":
+		s := fmt.Sprintf("%v", fv.Interface())
+		for _, o := range strings.Fields(param) {
+			if s == o {
+				return "", nil
+			}
+		}
+		return "must be one of [" + param + "]", nil
+	case "eqfield", "nefield", "gtfield", "gtefield", "ltfield", "ltefield":
+		other := m.Val.FieldByName(param)
+		if !other.IsValid() {
+			return "", errors.New("field " + param + " not found")
+		}
+		if other.Kind() == reflect.Ptr {
+			if other.IsNil() {
+				return "", nil
+			}
+			other = other.Elem()
+		}
+		c, err := compareValue(fv, other)
+		if err != nil {
+			return "", err
+		}
+		ok := map[string]bool{
+			"eqfield": c == 0, "nefield": c != 0,
+			"gtfield": c > 0, "gtefield": c >= 0,
+			"ltfield": c < 0, "ltefield": c <= 0,
+		}[name]
+		if !ok {
+			op := strings.TrimSuffix(name, "field")
+			return fmt.Sprintf("must be %s %s", op, param), nil
+		}
+	default:
+		return "", errors.New("unrecognized validate rule " + name)
+	}
+	return "", nil
+}
+
+// measure 数值返回其值,字符串、切片与map返回长度
+func measure(fv reflect.Value) (float64, bool, error) {
+	switch fv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(fv.Int()), false, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(fv.Uint()), false, nil
+	case reflect.Float32, reflect.Float64:
+		return fv.Float(), false, nil
+	case reflect.String:
+		return float64(len([]rune(fv.String()))), true, nil
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return float64(fv.Len()), true, nil
+	}
+	return 0, false, errors.New("unsupport datatype " + fv.Type().String())
+}
+
+// compareValue 比较同类字段,a<b返回-1,相等返回0,a>b返回1
+func compareValue(a, b reflect.Value) (int, error) {
+	if ta, ok := a.Interface().(time.Time); ok {
+		tb, ok := b.Interface().(time.Time)
+		if !ok {
+			return 0, errors.New("cannot compare time with " + b.Type().String())
+		}
+		switch {
+		case ta.Before(tb):
+			return -1, nil
+		case ta.After(tb):
+			return 1, nil
+		}
+		return 0, nil
+	}
+	if a.Kind() == reflect.String && b.Kind() == reflect.String {
+		return strings.Compare(a.String(), b.String()), nil
+	}
+	va, la, err := measure(a)
+	if err != nil {
+		return 0, err
+	}
+	vb, lb, err := measure(b)
+	if err != nil || la || lb {
+		return 0, errors.New("cannot compare " + a.Type().String() + " with " + b.Type().String())
+	}
+	switch {
+	case va < vb:
+		return -1, nil
+	case va > vb:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func compileRegexp(expr string) (*regexp.Regexp, error) {
+	regexpMu.Lock()
+	defer regexpMu.Unlock()
+	if re, ok := regexpCache[expr]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	regexpCache[expr] = re
+	return re, nil
+}
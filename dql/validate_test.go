@@ -0,0 +1,85 @@
+/**
+ * @Author: daipengyuan
+ * @Description:
+ * @File:  validate_test
+ * @Version: 1.0.0
+ * @Date: 2026/10/20 02:30
+ */
+
+package dql_test
+
+import (
+	"errors"
+	"github.com/golang-common/dglib/dql"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type booking struct {
+	Uid    string    `json:"uid" db:"uid,string" dtype:"Booking"`
+	Name   string    `json:"name" db:"name,string" validate:"required,max=8"`
+	Age    int       `json:"age" db:"age,int" validate:"min=0,max=150"`
+	Code   string    `json:"code" db:"code,string" validate:"len=4,regex=^[A-Z]+$"`
+	Email  string    `json:"email" db:"email,string" validate:"email"`
+	Status string    `json:"status" db:"status,string" validate:"oneof=open closed"`
+	Start  time.Time `json:"start" db:"start,datetime"`
+	End    time.Time `json:"end" db:"end,datetime" validate:"gtfield=Start"`
+}
+
+func (b booking) Validate() error {
+	if b.Status == "closed" && b.End.IsZero() {
+		return errors.New("closed booking must have end")
+	}
+	return nil
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Now()
+	ok := booking{Name: "a", Age: 3, Code: "ABCD", Email: "a@b.cn", Status: "open", Start: now, End: now.Add(time.Hour)}
+	if _, err := dql.BuildAdd(ok); err != nil {
+		t.Fatal(err)
+	}
+
+	bad := booking{Name: "too long name", Age: 200, Code: "ab", Email: "x", Status: "closed", Start: now}
+	_, err := dql.BuildAdd(bad)
+	var ves dql.ValidationErrors
+	if !errors.As(err, &ves) {
+		t.Fatalf("expected ValidationErrors, got %v", err)
+	}
+	var got []string
+	for _, e := range ves {
+		got = append(got, e.Predicate+":"+e.Rule)
+	}
+	want := []string{"name:max=8", "age:max=150", "code:len=4", "code:regex=^[A-Z]+$", "email:email", ":custom"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	bad = ok
+	bad.End = now.Add(-time.Hour)
+	if _, err = dql.BuildUpdate(bad); !errors.As(err, &ves) || len(ves) != 1 || ves[0].Rule != "gtfield=Start" {
+		t.Fatalf("cross field rule not applied: %v", err)
+	}
+
+	// Merge只写入非零字段,不检查required
+	if _, err = dql.BuildMerge(booking{Uid: "0x1", Age: 5}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = dql.BuildUpdate(booking{Uid: "0x1", Age: 5}); !errors.As(err, &ves) {
+		t.Fatalf("required not checked on update: %v", err)
+	}
+
+	// Update写入零值,零值同样需要满足规则;Merge只校验写入的字段
+	zero := ok
+	zero.Uid, zero.Status = "0x1", ""
+	if _, err = dql.BuildUpdate(zero); !errors.As(err, &ves) || len(ves) != 1 || ves[0].Rule != "oneof=open closed" {
+		t.Fatalf("zero value not validated on update: %v", err)
+	}
+	if _, err = dql.BuildMerge(zero); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = dql.BuildMergeFields(booking{Uid: "0x1"}, dql.FieldMask{"Status"}); !errors.As(err, &ves) || len(ves) != 1 || ves[0].Rule != "oneof=open closed" {
+		t.Fatalf("masked zero value not validated on merge: %v", err)
+	}
+}
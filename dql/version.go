@@ -0,0 +1,143 @@
+/**
+ * @Author: daipengyuan
+ * @Description: 基于版本谓词的乐观并发控制
+ * @File:  version
+ * @Version: 1.0.0
+ * @Date: 2026/10/20 00:00
+ */
+
+package dql
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dgraph-io/dgo/v200/protos/api"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	tagVersion   = "version"
+	versionBlock = "version_check"
+)
+
+// ErrStaleObject Update/Merge时对象的版本与库中不一致,说明已被其他人修改
+var ErrStaleObject = errors.New("stale object: version has been changed")
+
+// versionField 查找db标签中带version选项的字段,返回字段值与谓词名
+// 版本字段必须是整数类型
+func versionField(val reflect.Value) (reflect.Value, string, error) {
+	var (
+		r    reflect.Value
+		pred string
+	)
+	for i := 0; i < val.NumField(); i++ {
+		tags := strings.Split(val.Type().Field(i).Tag.Get(TagDb), ",")
+		for _, tg := range tags[1:] {
+			if tg != tagVersion {
+				continue
+			}
+			if pred != "" {
+				return r, "", errors.New("version tag can only set once")
+			}
+			switch val.Field(i).Kind() {
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			default:
+				return r, "", errors.New("version field must be int type")
+			}
+			r, pred = val.Field(i), tags[0]
+		}
+	}
+	return r, pred, nil
+}
+
+// withVersion 在请求中增加读取当前版本的查询块、版本相等的条件以及版本递增
+// 库中没有版本谓词时视为版本0
+func (m *mutation) withVersion(req *api.Request) error {
+	fv, pred, err := versionField(m.Val)
+	if err != nil || pred == "" {
+		return err
+	}
+	expect := fv.Int()
+	filter := fmt.Sprintf("eq(%s, %d)", pred, expect)
+	if expect == 0 {
+		filter = fmt.Sprintf("(NOT has(%s) OR %s)", pred, filter)
+	}
+	block := fmt.Sprintf("v as %s(func: uid(%s)) @filter(%s) { uid }", versionBlock, m.Subject, filter)
+	req.Query = appendBlock(req.Query, block)
+	// 返回变更条件中其他变量的节点,用于判断变更是否执行
+	seen := map[string]bool{}
+	for _, mu := range req.Mutations {
+		for _, cv := range condLenRe.FindAllStringSubmatch(mu.Cond, -1) {
+			if !seen[cv[1]] {
+				seen[cv[1]] = true
+				req.Query = appendBlock(req.Query, fmt.Sprintf("%s_%s(func: uid(%s)) { uid }", versionBlock, cv[1], cv[1]))
+			}
+		}
+	}
+	for _, mu := range req.Mutations {
+		if mu.Cond == "" {
+			mu.Cond = "@if(eq(len(v),1))"
+		} else {
+			mu.Cond = "@if(" + mu.Cond[4:len(mu.Cond)-1] + " AND eq(len(v),1))"
+		}
+		mu.Set = append(mu.Set, &api.NQuad{
+			Subject:     m.Subject,
+			Predicate:   pred,
+			ObjectValue: &api.Value{Val: &api.Value_IntVal{IntVal: expect + 1}},
+		})
+	}
+	return nil
+}
+
+// condLenRe 匹配变更条件中的eq(len(var),n)
+var condLenRe = regexp.MustCompile(`eq\(len\((\w+)\),\s*(\d+)\)`)
+
+// checkVersion 根据响应中的版本查询块判断是否写入成功
+// 版本一致但id重复等其他条件使变更未执行时不返回错误,也不修改版本
+// 成功且obj为指针时将其版本字段加1,使obj可以继续用于下一次更新
+func checkVersion(obj interface{}, req *api.Request, resp *api.Response) error {
+	if !strings.Contains(req.Query, versionBlock) {
+		return nil
+	}
+	var res map[string][]json.RawMessage
+	if err := json.Unmarshal(resp.Json, &res); err != nil {
+		return err
+	}
+	if len(res[versionBlock]) == 0 {
+		return ErrStaleObject
+	}
+	if len(req.Mutations) == 0 || !condApplied(req.Mutations[0].Cond, res) {
+		return nil
+	}
+	val := reflect.ValueOf(obj)
+	if val.Kind() != reflect.Ptr {
+		return nil
+	}
+	fv, _, err := versionField(val.Elem())
+	if err != nil {
+		return err
+	}
+	if fv.CanSet() {
+		fv.SetInt(fv.Int() + 1)
+	}
+	return nil
+}
+
+// condApplied 根据查询块返回的变量节点数量计算withVersion生成的条件是否成立
+// 条件只由AND连接的eq(len(var),n)组成
+func condApplied(cond string, res map[string][]json.RawMessage) bool {
+	for _, cv := range condLenRe.FindAllStringSubmatch(cond, -1) {
+		block := versionBlock + "_" + cv[1]
+		if cv[1] == "v" {
+			block = versionBlock
+		}
+		if strconv.Itoa(len(res[block])) != cv[2] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,119 @@
+/**
+ * @Author: daipengyuan
+ * @Description:
+ * @File:  version_test
+ * @Version: 1.0.0
+ * @Date: 2026/10/20 00:00
+ */
+
+package dql_test
+
+import (
+	"errors"
+	"github.com/golang-common/dglib/dql"
+	"github.com/golang-common/dglib/dql/dqltest"
+	"testing"
+)
+
+type document struct {
+	Uid     string `json:"uid" db:"uid,string" dtype:"Document"`
+	Title   string `json:"title" db:"title,string"`
+	Version int    `json:"version" db:"version,int,version"`
+}
+
+type ticket struct {
+	Uid     string `json:"uid" db:"uid,string" dtype:"Ticket"`
+	Code    string `json:"code" db:"code,string,id"`
+	Version int    `json:"version" db:"version,int,version"`
+}
+
+func TestVersion(t *testing.T) {
+	c := dqltest.NewClient(t)
+	txn := c.Txn()
+	resp, err := txn.Add(document{Title: "draft"})
+	txn.CommitOrAbort(err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var uid string
+	for _, u := range resp.Uids {
+		uid = u
+	}
+
+	// 两个会话读到同一版本
+	a := &document{Uid: uid, Title: "from a"}
+	b := &document{Uid: uid, Title: "from b"}
+	txn = c.Txn()
+	_, err = txn.Update(a)
+	txn.CommitOrAbort(err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Version != 1 {
+		t.Fatalf("version not bumped, got %d", a.Version)
+	}
+	txn = c.Txn()
+	_, err = txn.Merge(b)
+	txn.CommitOrAbort(err)
+	if !errors.Is(err, dql.ErrStaleObject) {
+		t.Fatalf("expected ErrStaleObject, got %v", err)
+	}
+	a.Title = "from a again"
+	txn = c.Txn()
+	_, err = txn.Merge(a)
+	txn.CommitOrAbort(err)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var res struct {
+		Q []document `json:"q"`
+	}
+	if err = c.Txn(true).UnmashalQueryStr(`{ q(func: uid(`+uid+`)) { uid title version } }`, &res); err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Q) != 1 || res.Q[0].Title != "from a again" || res.Q[0].Version != 2 {
+		t.Fatalf("unexpected document %+v", res.Q)
+	}
+}
+
+// id重复使变更未执行时不修改版本
+func TestVersion_IdConflict(t *testing.T) {
+	c := dqltest.NewClient(t)
+	var uids []string
+	for _, code := range []string{"t1", "t2"} {
+		txn := c.Txn()
+		resp, err := txn.Add(ticket{Code: code})
+		txn.CommitOrAbort(err)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, u := range resp.Uids {
+			uids = append(uids, u)
+		}
+	}
+	tk := &ticket{Uid: uids[1], Code: "t1"}
+	txn := c.Txn()
+	_, err := txn.Update(tk)
+	txn.CommitOrAbort(err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tk.Version != 0 {
+		t.Fatalf("version bumped without write: %d", tk.Version)
+	}
+	tk.Code = "t3"
+	txn = c.Txn()
+	_, err = txn.Update(tk)
+	txn.CommitOrAbort(err)
+	if err != nil || tk.Version != 1 {
+		t.Fatalf("update after conflict: version %d, err %v", tk.Version, err)
+	}
+	got := ticket{Uid: uids[1]}
+	if err = c.Txn(true).Get(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Code != "t3" || got.Version != 1 {
+		t.Fatalf("ticket %+v", got)
+	}
+}
@@ -0,0 +1,251 @@
+/**
+ * @Author: daipengyuan
+ * @Description: dgraph admin接口,包括/health、/state以及/admin上的graphql运维操作
+ * @File:  admin
+ * @Version: 1.0.0
+ * @Date: 2026/10/19 14:30
+ */
+
+package gql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/golang-common/dglib/dql"
+	"net/http"
+)
+
+// AdminClient dgraph admin操作对象
+type AdminClient struct {
+	http *httpClient
+}
+
+// NewAdminClient 新建admin客户端,请求发往config.HttpTargets[0]
+// 配置了AdminToken时所有请求携带X-Dgraph-AuthToken头
+func NewAdminClient(config dql.Config) (*AdminClient, error) {
+	c, err := newHttpClient(config)
+	if err != nil {
+		return nil, err
+	}
+	return &AdminClient{http: c}, nil
+}
+
+// Health /health返回的单个节点状态
+type Health struct {
+	Instance    string   `json:"instance"`
+	Address     string   `json:"address"`
+	Status      string   `json:"status"`
+	Group       string   `json:"group"`
+	Version     string   `json:"version"`
+	Uptime      int64    `json:"uptime"`
+	LastEcho    int64    `json:"lastEcho"`
+	Ongoing     []string `json:"ongoing,omitempty"`
+	Indexing    []string `json:"indexing,omitempty"`
+	EeFeatures  []string `json:"ee_features,omitempty"`
+	MaxAssigned int64    `json:"max_assigned,omitempty"`
+}
+
+// Health 获取当前节点的健康状态,all为真时返回集群中所有节点
+func (a *AdminClient) Health(ctx context.Context, all ...bool) ([]Health, error) {
+	var (
+		r    []Health
+		path = "/health"
+	)
+	if len(all) > 0 && all[0] {
+		path += "?all"
+	}
+	err := a.http.do(ctx, http.MethodGet, path, nil, &r)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// State /state返回的集群状态
+type State struct {
+	Counter   string            `json:"counter"`
+	Groups    map[string]Group  `json:"groups"`
+	Zeros     map[string]Member `json:"zeros"`
+	MaxUID    string            `json:"maxUID"`
+	MaxTxnTs  string            `json:"maxTxnTs"`
+	MaxNsID   string            `json:"maxNsID"`
+	MaxRaftId string            `json:"maxRaftId"`
+	Removed   []Member          `json:"removed"`
+	Cid       string            `json:"cid"`
+	License   License           `json:"license"`
+}
+
+// Group alpha分组,key为成员或谓词名
+type Group struct {
+	Members    map[string]Member `json:"members"`
+	Tablets    map[string]Tablet `json:"tablets"`
+	SnapshotTs string            `json:"snapshotTs"`
+	Checksum   string            `json:"checksum"`
+}
+
+type Member struct {
+	Id           string `json:"id"`
+	GroupId      int    `json:"groupId"`
+	Addr         string `json:"addr"`
+	Leader       bool   `json:"leader"`
+	AmDead       bool   `json:"amDead"`
+	LastUpdate   string `json:"lastUpdate"`
+	ForceGroupId bool   `json:"forceGroupId"`
+}
+
+// Tablet 谓词在分组中的分布信息
+type Tablet struct {
+	GroupId           int    `json:"groupId"`
+	Predicate         string `json:"predicate"`
+	Force             bool   `json:"force"`
+	OnDiskBytes       string `json:"onDiskBytes"`
+	Remove            bool   `json:"remove"`
+	ReadOnly          bool   `json:"readOnly"`
+	MoveTs            string `json:"moveTs"`
+	UncompressedBytes string `json:"uncompressedBytes"`
+}
+
+type License struct {
+	User     string `json:"user"`
+	MaxNodes string `json:"maxNodes"`
+	ExpiryTs string `json:"expiryTs"`
+	Enabled  bool   `json:"enabled"`
+}
+
+// State 获取集群状态
+func (a *AdminClient) State(ctx context.Context) (*State, error) {
+	var r State
+	err := a.http.do(ctx, http.MethodGet, "/state", nil, &r)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// Response admin变更操作返回的结果
+type Response struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type payload struct {
+	Response Response `json:"response"`
+}
+
+// ExportInput 导出参数,Format为rdf或json,Destination为空时导出到alpha本地
+type ExportInput struct {
+	Format       string `json:"format,omitempty"`
+	Namespace    *int   `json:"namespace,omitempty"`
+	Destination  string `json:"destination,omitempty"`
+	AccessKey    string `json:"accessKey,omitempty"`
+	SecretKey    string `json:"secretKey,omitempty"`
+	SessionToken string `json:"sessionToken,omitempty"`
+	Anonymous    bool   `json:"anonymous,omitempty"`
+}
+
+// Export 导出数据
+func (a *AdminClient) Export(ctx context.Context, input ExportInput) (*Response, error) {
+	const q = `mutation($input: ExportInput!) { export(input: $input) { response { code message } } }`
+	return a.mutate(ctx, "export", q, map[string]interface{}{"input": input})
+}
+
+// BackupInput 备份参数,Destination为备份目标如s3://、minio://或本地目录
+type BackupInput struct {
+	Destination  string `json:"destination"`
+	AccessKey    string `json:"accessKey,omitempty"`
+	SecretKey    string `json:"secretKey,omitempty"`
+	SessionToken string `json:"sessionToken,omitempty"`
+	Anonymous    bool   `json:"anonymous,omitempty"`
+	ForceFull    bool   `json:"forceFull,omitempty"`
+}
+
+// Backup [企业版功能]备份数据
+func (a *AdminClient) Backup(ctx context.Context, input BackupInput) (*Response, error) {
+	if input.Destination == "" {
+		return nil, errors.New("backup destination must not empty")
+	}
+	const q = `mutation($input: BackupInput!) { backup(input: $input) { response { code message } } }`
+	return a.mutate(ctx, "backup", q, map[string]interface{}{"input": input})
+}
+
+// Draining 开启或关闭draining模式,开启后节点拒绝新的查询与变更
+func (a *AdminClient) Draining(ctx context.Context, enable bool) (*Response, error) {
+	const q = `mutation($enable: Boolean) { draining(enable: $enable) { response { code message } } }`
+	return a.mutate(ctx, "draining", q, map[string]interface{}{"enable": enable})
+}
+
+// Shutdown 关闭当前节点
+func (a *AdminClient) Shutdown(ctx context.Context) (*Response, error) {
+	const q = `mutation { shutdown { response { code message } } }`
+	return a.mutate(ctx, "shutdown", q, nil)
+}
+
+// ConfigInput 运行时配置更新,为nil的项不修改
+type ConfigInput struct {
+	CacheMb       *float64 `json:"cacheMb,omitempty"`
+	LogDQLRequest *bool    `json:"logDQLRequest,omitempty"`
+}
+
+// UpdateConfig 更新节点运行时配置
+func (a *AdminClient) UpdateConfig(ctx context.Context, input ConfigInput) (*Response, error) {
+	const q = `mutation($input: ConfigInput!) { config(input: $input) { response { code message } } }`
+	return a.mutate(ctx, "config", q, map[string]interface{}{"input": input})
+}
+
+// ListBackupsInput 列出备份的参数
+type ListBackupsInput struct {
+	Location     string `json:"location"`
+	AccessKey    string `json:"accessKey,omitempty"`
+	SecretKey    string `json:"secretKey,omitempty"`
+	SessionToken string `json:"sessionToken,omitempty"`
+	Anonymous    bool   `json:"anonymous,omitempty"`
+}
+
+// Manifest 一次备份的描述
+type Manifest struct {
+	BackupId  string        `json:"backupId"`
+	BackupNum int64         `json:"backupNum"`
+	Encrypted bool          `json:"encrypted"`
+	Groups    []BackupGroup `json:"groups"`
+	Path      string        `json:"path"`
+	Since     int64         `json:"since"`
+	Type      string        `json:"type"`
+}
+
+type BackupGroup struct {
+	GroupId    int      `json:"groupId"`
+	Predicates []string `json:"predicates"`
+}
+
+// ListBackups [企业版功能]列出备份位置中的所有备份
+func (a *AdminClient) ListBackups(ctx context.Context, input ListBackupsInput) ([]Manifest, error) {
+	const q = `query($input: ListBackupsInput!) {
+	listBackups(input: $input) { backupId backupNum encrypted groups { groupId predicates } path since type }
+}`
+	var r struct {
+		ListBackups []Manifest `json:"listBackups"`
+	}
+	if input.Location == "" {
+		return nil, errors.New("backup location must not empty")
+	}
+	err := a.http.graphql(ctx, "/admin", q, map[string]interface{}{"input": input}, &r)
+	if err != nil {
+		return nil, err
+	}
+	return r.ListBackups, nil
+}
+
+// mutate 执行/admin上的变更并取出name对应的response
+func (a *AdminClient) mutate(ctx context.Context, name, q string, vars map[string]interface{}) (*Response, error) {
+	var r map[string]json.RawMessage
+	err := a.http.graphql(ctx, "/admin", q, vars, &r)
+	if err != nil {
+		return nil, err
+	}
+	var p payload
+	if err = json.Unmarshal(r[name], &p); err != nil {
+		return nil, err
+	}
+	return &p.Response, nil
+}
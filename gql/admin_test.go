@@ -0,0 +1,76 @@
+/**
+ * @Author: daipengyuan
+ * @Description:
+ * @File:  admin_test
+ * @Version: 1.0.0
+ * @Date: 2026/10/19 15:10
+ */
+
+package gql
+
+import (
+	"context"
+	"errors"
+	"github.com/golang-common/dglib/dql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTestAdmin(t *testing.T, h http.HandlerFunc) *AdminClient {
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	c, err := NewAdminClient(dql.Config{
+		HttpTargets: []string{strings.TrimPrefix(srv.URL, "http://")},
+		AdminToken:  "secret",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestAdminClient_Draining(t *testing.T) {
+	c := newTestAdmin(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/admin" || r.Header.Get(dql.AdminTokenHeader) != "secret" {
+			w.Write([]byte(`{"errors":[{"message":"No Auth Token found. Token needed for Admin operations."}]}`))
+			return
+		}
+		w.Write([]byte(`{"data":{"draining":{"response":{"code":"Success","message":"draining mode has been set to true"}}}}`))
+	})
+	resp, err := c.Draining(context.Background(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Code != "Success" {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+}
+
+func TestAdminClient_TokenRejected(t *testing.T) {
+	c := newTestAdmin(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"errors":[{"message":"Provided auth token [secret] does not match. Permission denied."}]}`))
+	})
+	_, err := c.Shutdown(context.Background())
+	var tokenErr *dql.AdminTokenError
+	if !errors.As(err, &tokenErr) {
+		t.Fatalf("want AdminTokenError, got %v", err)
+	}
+}
+
+func TestAdminClient_Health(t *testing.T) {
+	c := newTestAdmin(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.RawQuery != "all" {
+			t.Errorf("want ?all, got %q", r.URL.RawQuery)
+		}
+		w.Write([]byte(`[{"instance":"alpha","address":"localhost:7080","status":"healthy","group":"1","version":"v21.03.0","uptime":10,"lastEcho":1629000000}]`))
+	})
+	hs, err := c.Health(context.Background(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hs) != 1 || hs[0].Status != "healthy" || hs[0].Group != "1" {
+		t.Fatalf("unexpected health %+v", hs)
+	}
+}
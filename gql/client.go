@@ -0,0 +1,92 @@
+/**
+ * @Author: daipengyuan
+ * @Description: dgraph graphql接口客户端,schema维护与/graphql查询变更
+ * @File:  client
+ * @Version: 1.0.0
+ * @Date: 2026/10/19 16:05
+ */
+
+package gql
+
+import (
+	"context"
+	"errors"
+	"github.com/golang-common/dglib/dql"
+	"time"
+)
+
+// Client graphql操作对象,与dql.Client共用dql.Config
+type Client struct {
+	http        *httpClient
+	readTimeout time.Duration
+}
+
+// NewClient 新建graphql客户端,请求发往config.HttpTargets[0]
+func NewClient(config dql.Config) (*Client, error) {
+	c, err := newHttpClient(config)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{http: c, readTimeout: defaultReadTimeout}, nil
+}
+
+// SetReadTimeout 设置订阅连接的读超时,超过该时间没有收到数据或ka时重连,对之后的Subscribe生效
+func (c *Client) SetReadTimeout(d time.Duration) {
+	c.readTimeout = d
+}
+
+// SetHeader 设置每个请求携带的头,如@auth中Dgraph.Authorization指定的jwt头
+func (c *Client) SetHeader(key, value string) {
+	c.http.setHeader(key, value)
+}
+
+// Schema graphql schema,Schema为上传的SDL,GeneratedSchema为dgraph生成的完整schema
+type Schema struct {
+	Id              string `json:"id"`
+	Schema          string `json:"schema"`
+	GeneratedSchema string `json:"generatedSchema"`
+}
+
+// UpdateSchema 通过/admin updateGQLSchema上传SDL
+func (c *Client) UpdateSchema(ctx context.Context, sdl string) (*Schema, error) {
+	const q = `mutation($sch: String!) {
+	updateGQLSchema(input: { set: { schema: $sch } }) { gqlSchema { id schema generatedSchema } }
+}`
+	var r struct {
+		UpdateGQLSchema struct {
+			GqlSchema Schema `json:"gqlSchema"`
+		} `json:"updateGQLSchema"`
+	}
+	if sdl == "" {
+		return nil, errors.New("empty graphql schema")
+	}
+	err := c.http.graphql(ctx, "/admin", q, map[string]interface{}{"sch": sdl}, &r)
+	if err != nil {
+		return nil, err
+	}
+	return &r.UpdateGQLSchema.GqlSchema, nil
+}
+
+// GetSchema 获取当前graphql schema,未设置schema时返回nil
+func (c *Client) GetSchema(ctx context.Context) (*Schema, error) {
+	const q = `query { getGQLSchema { id schema generatedSchema } }`
+	var r struct {
+		GetGQLSchema *Schema `json:"getGQLSchema"`
+	}
+	err := c.http.graphql(ctx, "/admin", q, nil, &r)
+	if err != nil {
+		return nil, err
+	}
+	return r.GetGQLSchema, nil
+}
+
+// Query 在/graphql上执行查询,并将data解码到out
+// 响应中存在errors时返回Errors,此时out中仍包含部分成功的结果
+func (c *Client) Query(ctx context.Context, q string, vars map[string]interface{}, out interface{}) error {
+	return c.http.graphql(ctx, "/graphql", q, vars, out)
+}
+
+// Mutate 在/graphql上执行变更,用法同Query
+func (c *Client) Mutate(ctx context.Context, m string, vars map[string]interface{}, out interface{}) error {
+	return c.http.graphql(ctx, "/graphql", m, vars, out)
+}
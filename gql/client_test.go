@@ -0,0 +1,56 @@
+/**
+ * @Author: daipengyuan
+ * @Description:
+ * @File:  client_test
+ * @Version: 1.0.0
+ * @Date: 2026/10/19 16:40
+ */
+
+package gql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/golang-common/dglib/dql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestClient_Query(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req gqlRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+			return
+		}
+		if r.URL.Path != "/graphql" || req.Variables["id"] != "0x1" || r.Header.Get("X-Auth") != "jwt" {
+			t.Errorf("unexpected request %s %+v", r.URL.Path, req)
+		}
+		w.Write([]byte(`{"data":{"getDevice":{"id":"0x1","state":"on"},"other":null},
+"errors":[{"message":"resolver failed","path":["other"],"extensions":{"code":"ErrResolve"}}]}`))
+	}))
+	defer srv.Close()
+	c, err := NewClient(dql.Config{HttpTargets: []string{strings.TrimPrefix(srv.URL, "http://")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetHeader("X-Auth", "jwt")
+	var out struct {
+		GetDevice struct {
+			Id    string `json:"id"`
+			State string `json:"state"`
+		} `json:"getDevice"`
+	}
+	err = c.Query(context.Background(), `query($id: ID!) { getDevice(id: $id) { id state } }`,
+		map[string]interface{}{"id": "0x1"}, &out)
+	var gerrs Errors
+	if !errors.As(err, &gerrs) || len(gerrs) != 1 || gerrs[0].Code() != "ErrResolve" {
+		t.Fatalf("want graphql errors, got %v", err)
+	}
+	if out.GetDevice.State != "on" {
+		t.Fatalf("partial data not decoded %+v", out)
+	}
+}
@@ -0,0 +1,256 @@
+/**
+ * @Author: daipengyuan
+ * @Description: dgraph http接口的基础请求,admin与graphql客户端共用
+ * @File:  http
+ * @Version: 1.0.0
+ * @Date: 2026/10/19 14:02
+ */
+
+package gql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang-common/dglib/dql"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const accessTokenHeader = "X-Dgraph-AccessToken"
+
+// Location 错误在graphql请求文本中的位置
+type Location struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// Error graphql响应errors数组中的单个错误
+type Error struct {
+	Message    string                 `json:"message"`
+	Locations  []Location             `json:"locations,omitempty"`
+	Path       []interface{}          `json:"path,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+func (e Error) Error() string {
+	if len(e.Path) == 0 {
+		return e.Message
+	}
+	var path []string
+	for _, p := range e.Path {
+		path = append(path, fmt.Sprintf("%v", p))
+	}
+	return fmt.Sprintf("%s (path: %s)", e.Message, strings.Join(path, "."))
+}
+
+// Code 返回extensions中的code,不存在时为空
+func (e Error) Code() string {
+	if v, ok := e.Extensions["code"].(string); ok {
+		return v
+	}
+	return ""
+}
+
+// Errors graphql响应中的errors数组,响应中存在errors时作为错误返回
+type Errors []Error
+
+func (e Errors) Error() string {
+	var msgs []string
+	for _, v := range e {
+		msgs = append(msgs, v.Error())
+	}
+	return "graphql: " + strings.Join(msgs, "; ")
+}
+
+// StatusError http请求返回非200状态码
+type StatusError struct {
+	Code int
+	Body string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("http status %d: %s", e.Code, e.Body)
+}
+
+type gqlRequest struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables,omitempty"`
+}
+
+type gqlResponse struct {
+	Data       json.RawMessage        `json:"data"`
+	Errors     Errors                 `json:"errors,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// httpClient 对dgraph alpha http端口的请求封装
+type httpClient struct {
+	base     string
+	token    string
+	username string
+	password string
+	client   *http.Client
+	header   http.Header // 附加到每个请求的头,如@auth使用的jwt
+	mu       sync.Mutex
+	jwt      string
+}
+
+// newHttpClient 使用dql.Config中的HttpTargets、AdminToken、账号与tls配置
+// 请求发往HttpTargets中的第一个地址
+func newHttpClient(config dql.Config) (*httpClient, error) {
+	if len(config.HttpTargets) == 0 {
+		return nil, errors.New("no http target given")
+	}
+	c := &httpClient{
+		base:     "http://" + config.HttpTargets[0],
+		token:    config.AdminToken,
+		username: config.Username,
+		password: config.Password,
+		client:   &http.Client{Timeout: config.OptTimeout},
+		header:   make(http.Header),
+	}
+	if config.Tls != (dql.Tls{}) {
+		conf, err := config.Tls.Config()
+		if err != nil {
+			return nil, err
+		}
+		c.client.Transport = &http.Transport{TLSClientConfig: conf}
+		c.base = "https://" + config.HttpTargets[0]
+	}
+	return c, nil
+}
+
+// do 发送http请求,body不为nil时编码为json,out不为nil时将响应解码到out
+func (c *httpClient) do(ctx context.Context, method, path string, body interface{}, out interface{}) error {
+	var reader *bytes.Reader
+	if body != nil {
+		bs, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(bs)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	req, err := http.NewRequest(method, c.base+path, reader)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	c.mu.Lock()
+	for k, v := range c.header {
+		req.Header[k] = v
+	}
+	if c.jwt != "" {
+		req.Header.Set(accessTokenHeader, c.jwt)
+	}
+	c.mu.Unlock()
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set(dql.AdminTokenHeader, c.token)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	bs, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return &StatusError{Code: resp.StatusCode, Body: string(bs)}
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(bs, out)
+}
+
+// graphql 向path发送graphql请求,并将data解码到out
+// 响应同时包含data与errors时仍解码data,并返回Errors
+// 配置了账号时在首次请求前登录获取accessJWT
+func (c *httpClient) graphql(ctx context.Context, path, query string, vars map[string]interface{}, out interface{}) error {
+	if err := c.login(ctx); err != nil {
+		return err
+	}
+	var resp gqlResponse
+	err := c.do(ctx, http.MethodPost, path, gqlRequest{Query: query, Variables: vars}, &resp)
+	if err != nil {
+		return err
+	}
+	for _, e := range resp.Errors {
+		if strings.Contains(strings.ToLower(e.Message), "auth token") {
+			return &dql.AdminTokenError{Err: resp.Errors}
+		}
+	}
+	if out != nil && len(resp.Data) > 0 && string(resp.Data) != "null" {
+		if err = json.Unmarshal(resp.Data, out); err != nil {
+			return err
+		}
+	}
+	if len(resp.Errors) > 0 {
+		return resp.Errors
+	}
+	return nil
+}
+
+func (c *httpClient) setHeader(key, value string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.header.Set(key, value)
+}
+
+const loginMutation = `mutation($userId: String!, $password: String!) {
+	login(userId: $userId, password: $password) {
+		response { accessJWT refreshJWT }
+	}
+}`
+
+func (c *httpClient) login(ctx context.Context) error {
+	if c.username == "" || c.password == "" {
+		return nil
+	}
+	c.mu.Lock()
+	logged := c.jwt != ""
+	c.mu.Unlock()
+	if logged {
+		return nil
+	}
+	var (
+		resp gqlResponse
+		data struct {
+			Login struct {
+				Response struct {
+					AccessJWT  string `json:"accessJWT"`
+					RefreshJWT string `json:"refreshJWT"`
+				} `json:"response"`
+			} `json:"login"`
+		}
+		vars = map[string]interface{}{"userId": c.username, "password": c.password}
+	)
+	err := c.do(ctx, http.MethodPost, "/admin", gqlRequest{Query: loginMutation, Variables: vars}, &resp)
+	if err != nil {
+		return err
+	}
+	if len(resp.Errors) > 0 {
+		return resp.Errors
+	}
+	if err = json.Unmarshal(resp.Data, &data); err != nil {
+		return err
+	}
+	if data.Login.Response.AccessJWT == "" {
+		return errors.New("login failed, empty access jwt")
+	}
+	c.mu.Lock()
+	c.jwt = data.Login.Response.AccessJWT
+	c.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,262 @@
+/**
+ * @Author: daipengyuan
+ * @Description: graphql订阅,基于websocket的graphql-ws协议
+ * @File:  subscribe
+ * @Version: 1.0.0
+ * @Date: 2026/10/19 17:20
+ */
+
+package gql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang-common/dglib/dql"
+	"github.com/gorilla/websocket"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	wsProtocol = "graphql-ws"
+	wsSubId    = "1"
+
+	wsConnInit      = "connection_init"
+	wsConnAck       = "connection_ack"
+	wsConnError     = "connection_error"
+	wsConnKeepAlive = "ka"
+	wsConnTerminate = "connection_terminate"
+	wsStart         = "start"
+	wsData          = "data"
+	wsError         = "error"
+	wsComplete      = "complete"
+	wsStop          = "stop"
+
+	minReconnectWait = time.Second
+	maxReconnectWait = 30 * time.Second
+	// defaultReadTimeout 超过该时间没有收到任何消息(包括ka)时视为连接已失效,断开后重连
+	defaultReadTimeout = time.Minute
+)
+
+// Result 订阅推送的一次结果,Err不为nil时Data可能为空
+type Result struct {
+	Data json.RawMessage
+	Err  error
+}
+
+// Decode 将结果中的data解码到out
+func (r Result) Decode(out interface{}) error {
+	if r.Err != nil {
+		return r.Err
+	}
+	return json.Unmarshal(r.Data, out)
+}
+
+type wsMessage struct {
+	Id      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// subscribeError 服务端拒绝订阅,重连也无法恢复
+type subscribeError struct {
+	err error
+}
+
+func (e *subscribeError) Error() string {
+	return e.err.Error()
+}
+
+// Subscribe 订阅q,每次数据变化从返回的通道收到一个Result
+// 连接断开时自动重连并重新订阅,ctx取消后停止订阅并关闭通道
+// 服务端拒绝订阅时通道收到带Err的Result后关闭
+func (c *Client) Subscribe(ctx context.Context, q string, vars map[string]interface{}) (<-chan Result, error) {
+	if err := c.http.login(ctx); err != nil {
+		return nil, err
+	}
+	start, err := json.Marshal(gqlRequest{Query: q, Variables: vars})
+	if err != nil {
+		return nil, err
+	}
+	conn, err := c.dialWs(ctx, start)
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan Result)
+	go c.subscribeLoop(ctx, conn, start, c.readTimeout, ch)
+	return ch, nil
+}
+
+func (c *Client) subscribeLoop(ctx context.Context, conn *websocket.Conn, start json.RawMessage, timeout time.Duration, ch chan<- Result) {
+	defer close(ch)
+	wait := minReconnectWait
+	for {
+		err := readWs(ctx, conn, timeout, ch)
+		conn.Close()
+		if err == nil || ctx.Err() != nil {
+			return
+		}
+		var se *subscribeError
+		if errors.As(err, &se) {
+			select {
+			case ch <- Result{Err: se.err}:
+			case <-ctx.Done():
+			}
+			return
+		}
+		// 连接断开,退避后重连
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(wait):
+			}
+			conn, err = c.dialWs(ctx, start)
+			if err == nil {
+				wait = minReconnectWait
+				break
+			}
+			if errors.As(err, &se) {
+				select {
+				case ch <- Result{Err: se.err}:
+				case <-ctx.Done():
+				}
+				return
+			}
+			if wait *= 2; wait > maxReconnectWait {
+				wait = maxReconnectWait
+			}
+		}
+	}
+}
+
+// dialWs 建立连接,完成connection_init握手并发送start
+func (c *Client) dialWs(ctx context.Context, start json.RawMessage) (*websocket.Conn, error) {
+	var (
+		header = make(http.Header)
+		init   = make(map[string]string)
+		dialer = &websocket.Dialer{
+			Subprotocols:     []string{wsProtocol},
+			HandshakeTimeout: 10 * time.Second,
+		}
+		url = "ws" + strings.TrimPrefix(c.http.base, "http") + "/graphql"
+	)
+	if tr, ok := c.http.client.Transport.(*http.Transport); ok {
+		dialer.TLSClientConfig = tr.TLSClientConfig
+	}
+	c.http.mu.Lock()
+	for k, v := range c.http.header {
+		header[k] = v
+		init[k] = strings.Join(v, ",")
+	}
+	if c.http.jwt != "" {
+		header.Set(accessTokenHeader, c.http.jwt)
+		init[accessTokenHeader] = c.http.jwt
+	}
+	c.http.mu.Unlock()
+	if c.http.token != "" {
+		header.Set(dql.AdminTokenHeader, c.http.token)
+	}
+	conn, _, err := dialer.DialContext(ctx, url, header)
+	if err != nil {
+		return nil, err
+	}
+	// dgraph从connection_init的payload中读取鉴权头
+	payload, _ := json.Marshal(init)
+	if err = conn.WriteJSON(wsMessage{Type: wsConnInit, Payload: payload}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	// 服务端一直不回复connection_ack时,ctx取消后关闭连接结束等待
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+	for {
+		var msg wsMessage
+		if err = conn.ReadJSON(&msg); err != nil {
+			conn.Close()
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, err
+		}
+		if msg.Type == wsConnAck {
+			break
+		}
+		if msg.Type == wsConnError {
+			conn.Close()
+			return nil, &subscribeError{err: errors.New(fmt.Sprintf("connection rejected: %s", msg.Payload))}
+		}
+	}
+	if err = conn.WriteJSON(wsMessage{Id: wsSubId, Type: wsStart, Payload: start}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// readWs 读取推送直到连接断开或订阅结束,ctx取消或订阅结束时返回nil
+// 每收到一条消息后重置读超时,半开的连接在超时后返回错误并触发重连
+func readWs(ctx context.Context, conn *websocket.Conn, timeout time.Duration, ch chan<- Result) error {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.WriteJSON(wsMessage{Id: wsSubId, Type: wsStop})
+			conn.WriteJSON(wsMessage{Type: wsConnTerminate})
+			conn.Close()
+		case <-done:
+		}
+	}()
+	for {
+		var msg wsMessage
+		if err := conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+			return err
+		}
+		if err := conn.ReadJSON(&msg); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		switch msg.Type {
+		case wsData:
+			var (
+				resp gqlResponse
+				r    Result
+			)
+			if err := json.Unmarshal(msg.Payload, &resp); err != nil {
+				r.Err = err
+			} else {
+				r.Data = resp.Data
+				if len(resp.Errors) > 0 {
+					r.Err = resp.Errors
+				}
+			}
+			select {
+			case ch <- r:
+			case <-ctx.Done():
+				return nil
+			}
+		case wsError:
+			var errs Errors
+			if err := json.Unmarshal(msg.Payload, &errs); err != nil || len(errs) == 0 {
+				return &subscribeError{err: errors.New(fmt.Sprintf("subscription rejected: %s", msg.Payload))}
+			}
+			return &subscribeError{err: errs}
+		case wsComplete:
+			return nil
+		case wsConnKeepAlive:
+		}
+	}
+}
@@ -0,0 +1,168 @@
+/**
+ * @Author: daipengyuan
+ * @Description:
+ * @File:  subscribe_test
+ * @Version: 1.0.0
+ * @Date: 2026/10/19 18:05
+ */
+
+package gql
+
+import (
+	"context"
+	"fmt"
+	"github.com/golang-common/dglib/dql"
+	"github.com/gorilla/websocket"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// wsServer 模拟dgraph的graphql-ws服务,每个连接推送两次数据,第一个连接随后断开
+func wsServer(t *testing.T, conns *int32) *httptest.Server {
+	upgrader := websocket.Upgrader{Subprotocols: []string{wsProtocol}}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		n := atomic.AddInt32(conns, 1)
+		var msg wsMessage
+		if err = conn.ReadJSON(&msg); err != nil || msg.Type != wsConnInit {
+			t.Errorf("want connection_init, got %+v %v", msg, err)
+			return
+		}
+		if !strings.Contains(string(msg.Payload), "jwt") {
+			t.Errorf("auth header not in init payload %s", msg.Payload)
+		}
+		conn.WriteJSON(wsMessage{Type: wsConnAck})
+		if err = conn.ReadJSON(&msg); err != nil || msg.Type != wsStart {
+			t.Errorf("want start, got %+v %v", msg, err)
+			return
+		}
+		for i := 0; i < 2; i++ {
+			payload := fmt.Sprintf(`{"data":{"queryDevice":[{"state":"s%d-%d"}]}}`, n, i)
+			conn.WriteJSON(wsMessage{Id: msg.Id, Type: wsData, Payload: []byte(payload)})
+		}
+		if n > 1 {
+			// 第二个连接保持到客户端取消
+			conn.ReadJSON(&msg)
+		}
+	}))
+}
+
+func TestClient_Subscribe(t *testing.T) {
+	var conns int32
+	srv := wsServer(t, &conns)
+	defer srv.Close()
+	c, err := NewClient(dql.Config{HttpTargets: []string{strings.TrimPrefix(srv.URL, "http://")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetHeader("X-Auth", "jwt")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch, err := c.Subscribe(ctx, `subscription { queryDevice { state } }`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var states []string
+	for r := range ch {
+		var out struct {
+			QueryDevice []struct {
+				State string `json:"state"`
+			} `json:"queryDevice"`
+		}
+		if err = r.Decode(&out); err != nil {
+			t.Fatal(err)
+		}
+		states = append(states, out.QueryDevice[0].State)
+		if len(states) == 4 {
+			cancel()
+		}
+	}
+	if strings.Join(states, ",") != "s1-0,s1-1,s2-0,s2-1" {
+		t.Fatalf("unexpected results %v", states)
+	}
+	if atomic.LoadInt32(&conns) != 2 {
+		t.Fatalf("want one reconnect, got %d connections", conns)
+	}
+}
+
+// 服务端不回复connection_ack时,ctx超时后结束等待
+func TestClient_SubscribeNoAck(t *testing.T) {
+	upgrader := websocket.Upgrader{Subprotocols: []string{wsProtocol}}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var msg wsMessage
+		for conn.ReadJSON(&msg) == nil {
+		}
+	}))
+	defer srv.Close()
+	c, err := NewClient(dql.Config{HttpTargets: []string{strings.TrimPrefix(srv.URL, "http://")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err = c.Subscribe(ctx, `subscription { queryDevice { state } }`, nil); err != context.DeadlineExceeded {
+		t.Fatalf("want deadline exceeded, got %v", err)
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Fatal("waiting for connection_ack ignored ctx")
+	}
+}
+
+// 连接半开时没有任何消息,读超时后重连
+func TestClient_SubscribeReadTimeout(t *testing.T) {
+	var conns int32
+	upgrader := websocket.Upgrader{Subprotocols: []string{wsProtocol}}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		n := atomic.AddInt32(&conns, 1)
+		var msg wsMessage
+		conn.ReadJSON(&msg)
+		conn.WriteJSON(wsMessage{Type: wsConnAck})
+		conn.ReadJSON(&msg)
+		if n == 1 {
+			// 第一个连接不再发送任何消息,也不关闭
+			time.Sleep(3 * time.Second)
+			return
+		}
+		conn.WriteJSON(wsMessage{Id: msg.Id, Type: wsData, Payload: []byte(`{"data":{"n":2}}`)})
+		conn.ReadJSON(&msg)
+	}))
+	defer srv.Close()
+	c, err := NewClient(dql.Config{HttpTargets: []string{strings.TrimPrefix(srv.URL, "http://")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetReadTimeout(200 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 2500*time.Millisecond)
+	defer cancel()
+	ch, err := c.Subscribe(ctx, `subscription { n }`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, ok := <-ch
+	if !ok || r.Err != nil || string(r.Data) != `{"n":2}` {
+		t.Fatalf("expected data after reconnect, got %+v %v", r, ok)
+	}
+	if atomic.LoadInt32(&conns) != 2 {
+		t.Fatalf("want one reconnect, got %d connections", conns)
+	}
+}